@@ -8,7 +8,10 @@ import (
 )
 
 func main() {
-	termwin.CreateLog("out.log")
+	if err := termwin.CreateLog("out.log", termwin.LevelInfo); err != nil {
+		panic(err)
+	}
+	defer termwin.SetLogger(nil)
 
 	err := termwin.Init()
 	if err != nil {
@@ -0,0 +1,98 @@
+package termwin
+
+import (
+	"unicode/utf8"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+// An ansiDecoder converts text containing ANSI escape sequences into cells.
+// SGR (select graphic rendition) sequences set the colors and attributes
+// of the cells that follow, while all other escape sequences are removed.
+// Control characters are passed through as cells for the caller to
+// interpret. Escape sequences and UTF-8 sequences may be split across
+// calls to decode.
+type ansiDecoder struct {
+	state   int          // parser state, one of the vt parser states
+	seq     []byte       // parameters of the current control sequence
+	partial []byte       // incomplete UTF-8 sequence from the last call
+	fg, bg  tb.Attribute // attributes of decoded cells
+}
+
+// decode returns the cells for a chunk of text.
+func (d *ansiDecoder) decode(p []byte) []tb.Cell {
+	if len(d.partial) > 0 {
+		p = append(d.partial, p...)
+		d.partial = nil
+	}
+	cells := make([]tb.Cell, 0, len(p))
+	for len(p) > 0 {
+		r, n := rune(p[0]), 1
+		if r >= utf8.RuneSelf {
+			if !utf8.FullRune(p) {
+				d.partial = append([]byte(nil), p...)
+				break
+			}
+			r, n = utf8.DecodeRune(p)
+		}
+		p = p[n:]
+		if d.put(r) {
+			cells = append(cells, tb.Cell{Ch: r, Fg: d.fg, Bg: d.bg})
+		}
+	}
+	return cells
+}
+
+// put advances the parser by one rune. It returns true if the rune is
+// text or a control character rather than part of an escape sequence.
+func (d *ansiDecoder) put(r rune) bool {
+	switch d.state {
+	case vtGround:
+		if r == 0x1b {
+			d.state = vtEscape
+			return false
+		}
+		return true
+	case vtEscape:
+		switch r {
+		case '[':
+			d.state, d.seq = vtCSI, d.seq[:0]
+		case ']':
+			d.state = vtOSC
+		case '(', ')', '*', '+':
+			d.state = vtCharset
+		default:
+			d.state = vtGround
+		}
+	case vtCharset:
+		d.state = vtGround
+	case vtCSI:
+		switch {
+		case r >= 0x40 && r <= 0x7e:
+			d.state = vtGround
+			if r == 'm' && (len(d.seq) == 0 || d.seq[0] < '<') {
+				d.fg, d.bg = applySGR(parseParams(d.seq), d.fg, d.bg)
+			}
+		case r == 0x1b:
+			d.state = vtEscape
+		default:
+			d.seq = append(d.seq, byte(r))
+		}
+	case vtOSC:
+		switch r {
+		case 0x07:
+			d.state = vtGround
+		case 0x1b:
+			d.state = vtOSCEsc
+		}
+	case vtOSCEsc:
+		// ST ends the command; any other sequence starts a new one.
+		if r == '\\' {
+			d.state = vtGround
+		} else {
+			d.state = vtEscape
+			return d.put(r)
+		}
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+package termwin
+
+import (
+	"unicode/utf8"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+// A Button is a push button. The button is pressed by clicking it or by
+// pressing Enter or Space while it has the focus.
+type Button struct {
+	corner  coord  // screen coordinate of left edge
+	label   string // text displayed on the button
+	onPress func() // called when the button is pressed
+	focused bool   // button had the focus when last drawn
+	dirty   bool   // button needs to be redrawn
+}
+
+// NewButton creates a new Button control at the specified screen position.
+func NewButton(x, y int, label string) *Button {
+	b := &Button{
+		corner: coord{x, y},
+		label:  sanitizeLine(label),
+		dirty:  true,
+	}
+	addWindow(b)
+	return b
+}
+
+// Label returns the text displayed on the button.
+func (b *Button) Label() string {
+	return b.label
+}
+
+// SetLabel changes the text displayed on the button.
+func (b *Button) SetLabel(label string) {
+	damage(b.bounds())
+	b.label = sanitizeLine(label)
+	b.dirty = true
+}
+
+// OnPress sets the function called when the button is pressed.
+func (b *Button) OnPress(f func()) {
+	b.onPress = f
+}
+
+// Press presses the button, calling its press function.
+func (b *Button) Press() {
+	if b.onPress != nil {
+		b.onPress()
+	}
+}
+
+func (b *Button) bounds() rect {
+	return newRect(b.corner.x, b.corner.y, utf8.RuneCountInString(b.label)+4, 1)
+}
+
+func (b *Button) dirtyRect() rect {
+	if !b.dirty && b.focused == isFocused(b) {
+		return emptyRect
+	}
+	return b.bounds()
+}
+
+func (b *Button) invalidate() {
+	b.dirty = true
+}
+
+func (b *Button) move(x, y int) {
+	damage(b.bounds())
+	b.corner = coord{x, y}
+	b.dirty = true
+}
+
+// resize does nothing, since a button sizes itself to fit its label.
+func (b *Button) resize(width, height int) {
+}
+
+func (b *Button) getCursor() (x, y int, show bool) {
+	return 0, 0, false
+}
+
+func (b *Button) onKey(ev tb.Event) error {
+	if ev.Key == tb.KeyEnter || ev.Key == tb.KeySpace {
+		b.Press()
+	}
+	return nil
+}
+
+func (b *Button) onMouse(ev tb.Event) error {
+	if ev.Key == tb.MouseLeft && (ev.Mod&tb.ModMotion) == 0 {
+		b.Press()
+	}
+	return nil
+}
+
+func (b *Button) onDraw(cv *Canvas) {
+	if b.dirtyRect().empty() {
+		return
+	}
+	b.dirty = false
+	b.focused = isFocused(b)
+
+	fg, bg := focusColors(b.focused)
+	cv.DrawText(0, 0, maxValue, "[ "+b.label+" ]", fg, bg)
+}
+
+// A CheckBox is a control that toggles a setting on and off. It is toggled
+// by clicking it or by pressing Space or Enter while it has the focus.
+type CheckBox struct {
+	corner   coord              // screen coordinate of left edge
+	label    string             // text displayed to the right of the box
+	checked  bool               // box is checked
+	onChange func(checked bool) // called when the user toggles the box
+	focused  bool               // check box had the focus when last drawn
+	dirty    bool               // check box needs to be redrawn
+}
+
+// NewCheckBox creates a new CheckBox control at the specified screen
+// position.
+func NewCheckBox(x, y int, label string, checked bool) *CheckBox {
+	b := &CheckBox{
+		corner:  coord{x, y},
+		label:   sanitizeLine(label),
+		checked: checked,
+		dirty:   true,
+	}
+	addWindow(b)
+	return b
+}
+
+// Checked returns true if the box is checked.
+func (b *CheckBox) Checked() bool {
+	return b.checked
+}
+
+// SetChecked checks or unchecks the box without calling the change
+// function.
+func (b *CheckBox) SetChecked(checked bool) {
+	if checked != b.checked {
+		b.checked = checked
+		b.dirty = true
+	}
+}
+
+// Toggle toggles the box and calls the change function.
+func (b *CheckBox) Toggle() {
+	b.SetChecked(!b.checked)
+	if b.onChange != nil {
+		b.onChange(b.checked)
+	}
+}
+
+// OnChange sets the function called when the user toggles the box.
+func (b *CheckBox) OnChange(f func(checked bool)) {
+	b.onChange = f
+}
+
+func (b *CheckBox) bounds() rect {
+	return newRect(b.corner.x, b.corner.y, utf8.RuneCountInString(b.label)+4, 1)
+}
+
+func (b *CheckBox) dirtyRect() rect {
+	if !b.dirty && b.focused == isFocused(b) {
+		return emptyRect
+	}
+	return b.bounds()
+}
+
+func (b *CheckBox) invalidate() {
+	b.dirty = true
+}
+
+func (b *CheckBox) move(x, y int) {
+	damage(b.bounds())
+	b.corner = coord{x, y}
+	b.dirty = true
+}
+
+// resize does nothing, since a check box sizes itself to fit its label.
+func (b *CheckBox) resize(width, height int) {
+}
+
+func (b *CheckBox) getCursor() (x, y int, show bool) {
+	return b.corner.x + 1, b.corner.y, isFocused(b)
+}
+
+func (b *CheckBox) onKey(ev tb.Event) error {
+	if ev.Key == tb.KeyEnter || ev.Key == tb.KeySpace {
+		b.Toggle()
+	}
+	return nil
+}
+
+func (b *CheckBox) onMouse(ev tb.Event) error {
+	if ev.Key == tb.MouseLeft && (ev.Mod&tb.ModMotion) == 0 {
+		b.Toggle()
+	}
+	return nil
+}
+
+func (b *CheckBox) onDraw(cv *Canvas) {
+	if b.dirtyRect().empty() {
+		return
+	}
+	b.dirty = false
+	b.focused = isFocused(b)
+
+	box := "[ ] "
+	if b.checked {
+		box = "[x] "
+	}
+	fg, bg := focusColors(b.focused)
+	n := cv.DrawText(0, 0, maxValue, box, theme.Fg, theme.Bg)
+	cv.DrawText(n, 0, maxValue, b.label, fg, bg)
+}
+
+// focusColors returns the theme colors used to draw a control, depending on
+// whether it has the focus.
+func focusColors(focused bool) (fg, bg tb.Attribute) {
+	if focused {
+		return theme.FocusFg, theme.FocusBg
+	}
+	return theme.Fg, theme.Bg
+}
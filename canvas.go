@@ -0,0 +1,255 @@
+package termwin
+
+import (
+	runewidth "github.com/mattn/go-runewidth"
+	tb "github.com/nsf/termbox-go"
+)
+
+const charEllipsis = '…'
+
+// Arms of a box-drawing character, combined into a mask describing which
+// edges of the cell its lines reach.
+const (
+	lineUp = 1 << iota
+	lineDown
+	lineLeft
+	lineRight
+)
+
+// boxRunes maps a mask of line arms to the box-drawing character with
+// those arms.
+var boxRunes = [16]rune{
+	0:                                        charSpace,
+	lineUp:                                   '╵',
+	lineDown:                                 '╷',
+	lineLeft:                                 '╴',
+	lineRight:                                '╶',
+	lineUp | lineDown:                        boxVertical,
+	lineLeft | lineRight:                     boxHorizontal,
+	lineDown | lineRight:                     boxTopLeft,
+	lineDown | lineLeft:                      boxTopRight,
+	lineUp | lineRight:                       boxBottomLeft,
+	lineUp | lineLeft:                        boxBottomRight,
+	lineUp | lineDown | lineRight:            '├',
+	lineUp | lineDown | lineLeft:             '┤',
+	lineDown | lineLeft | lineRight:          '┬',
+	lineUp | lineLeft | lineRight:            '┴',
+	lineUp | lineDown | lineLeft | lineRight: '┼',
+}
+
+// boxMask returns the mask of line arms of a box-drawing character. It
+// returns false if the rune is not one of the characters in boxRunes.
+func boxMask(ch rune) (int, bool) {
+	for m, r := range boxRunes {
+		if m != 0 && r == ch {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+// A Canvas is the drawing surface handed to a window when it is drawn.
+// Canvas coordinates are relative to the window's top-left corner, and all
+// drawing is clipped to the window's bounds and the screen, so a window
+// cannot draw outside its own rectangle.
+//
+// Lines and boxes join with box-drawing characters already on the canvas,
+// producing junctions such as '┬' and '┼'. A window that should not join
+// with the contents beneath it fills its area before drawing lines.
+type Canvas struct {
+	origin coord // screen coordinate of the canvas origin
+	size   coord // dimensions of the canvas
+	clip   rect  // screen area the canvas may modify
+}
+
+// newCanvas returns a canvas covering a screen rectangle, clipped to the
+// screen.
+func newCanvas(r rect) *Canvas {
+	sw, sh := tb.Size()
+	return &Canvas{
+		origin: coord{r.x0, r.y0},
+		size:   coord{r.x1 - r.x0, r.y1 - r.y0},
+		clip:   intersection(r, newRect(0, 0, sw, sh)),
+	}
+}
+
+// sub returns a canvas covering a screen rectangle, clipped to this
+// canvas. Containers use it to draw their child windows.
+func (cv *Canvas) sub(r rect) *Canvas {
+	return &Canvas{
+		origin: coord{r.x0, r.y0},
+		size:   coord{r.x1 - r.x0, r.y1 - r.y0},
+		clip:   intersection(r, cv.clip),
+	}
+}
+
+// Size returns the dimensions of the canvas.
+func (cv *Canvas) Size() (width, height int) {
+	return cv.size.x, cv.size.y
+}
+
+// visible returns true if a canvas position lies within the clip
+// rectangle.
+func (cv *Canvas) visible(x, y int) bool {
+	return contains(cv.clip, cv.origin.x+x, cv.origin.y+y)
+}
+
+// SetCell sets the character and colors of the cell at a canvas position.
+// A double-width character that would straddle the clip edge is replaced by
+// a space.
+func (cv *Canvas) SetCell(x, y int, ch rune, fg, bg tb.Attribute) {
+	if !cv.visible(x, y) {
+		return
+	}
+	if runewidth.RuneWidth(ch) > 1 && !cv.visible(x+1, y) {
+		ch = charSpace
+	}
+	tb.SetCell(cv.origin.x+x, cv.origin.y+y, ch, fg, bg)
+}
+
+// SetCells copies a row of cells to the canvas, starting at a canvas
+// position.
+func (cv *Canvas) SetCells(x, y int, cells []tb.Cell) {
+	for i, c := range cells {
+		cv.SetCell(x+i, y, c.Ch, c.Fg, c.Bg)
+	}
+}
+
+// cell returns the cell at a canvas position. It returns false if the
+// position is clipped.
+func (cv *Canvas) cell(x, y int) (tb.Cell, bool) {
+	if !cv.visible(x, y) {
+		return tb.Cell{}, false
+	}
+	sw, _ := tb.Size()
+	return tb.CellBuffer()[(cv.origin.y+y)*sw+cv.origin.x+x], true
+}
+
+// setAttr adds attributes to the foreground of the cell at a canvas
+// position.
+func (cv *Canvas) setAttr(x, y int, attr tb.Attribute) {
+	if c, ok := cv.cell(x, y); ok {
+		tb.SetCell(cv.origin.x+x, cv.origin.y+y, c.Ch, c.Fg|attr, c.Bg)
+	}
+}
+
+// Fill sets every cell of a canvas rectangle to a character and colors.
+func (cv *Canvas) Fill(x, y, width, height int, ch rune, fg, bg tb.Attribute) {
+	for j := y; j < y+height; j++ {
+		for i := x; i < x+width; i++ {
+			cv.SetCell(i, j, ch, fg, bg)
+		}
+	}
+}
+
+// Clear fills the entire canvas with spaces.
+func (cv *Canvas) Clear(fg, bg tb.Attribute) {
+	cv.Fill(0, 0, cv.size.x, cv.size.y, charSpace, fg, bg)
+}
+
+// DrawText draws a string at a canvas position, using at most width
+// columns, and returns the number of columns used. Double-width characters
+// occupy two columns and zero-width characters are skipped. A string too
+// wide to fit is truncated and ends with an ellipsis.
+func (cv *Canvas) DrawText(x, y, width int, s string, fg, bg tb.Attribute) int {
+	if width <= 0 {
+		return 0
+	}
+	if textWidth(s) > width {
+		n := cv.drawRunes(x, y, width-1, s, fg, bg)
+		cv.SetCell(x+n, y, charEllipsis, fg, bg)
+		return n + 1
+	}
+	return cv.drawRunes(x, y, width, s, fg, bg)
+}
+
+// drawRunes draws the characters of a string that fit within width columns
+// and returns the number of columns used.
+func (cv *Canvas) drawRunes(x, y, width int, s string, fg, bg tb.Attribute) int {
+	n := 0
+	for _, ch := range s {
+		w := runewidth.RuneWidth(ch)
+		if w == 0 {
+			continue
+		}
+		if n+w > width {
+			break
+		}
+		cv.SetCell(x+n, y, ch, fg, bg)
+		for i := 1; i < w; i++ {
+			cv.SetCell(x+n+i, y, charSpace, fg, bg)
+		}
+		n += w
+	}
+	return n
+}
+
+// textWidth returns the number of columns occupied by a string.
+func textWidth(s string) int {
+	return runewidth.StringWidth(s)
+}
+
+// HLine draws a horizontal line of the given length, starting at a canvas
+// position and extending to the right.
+func (cv *Canvas) HLine(x, y, length int, fg, bg tb.Attribute) {
+	for i := 0; i < length; i++ {
+		outer := 0
+		if i == 0 {
+			outer |= lineLeft
+		}
+		if i == length-1 {
+			outer |= lineRight
+		}
+		cv.joinLine(x+i, y, lineLeft|lineRight, outer, fg, bg)
+	}
+}
+
+// VLine draws a vertical line of the given length, starting at a canvas
+// position and extending downward.
+func (cv *Canvas) VLine(x, y, length int, fg, bg tb.Attribute) {
+	for i := 0; i < length; i++ {
+		outer := 0
+		if i == 0 {
+			outer |= lineUp
+		}
+		if i == length-1 {
+			outer |= lineDown
+		}
+		cv.joinLine(x, y+i, lineUp|lineDown, outer, fg, bg)
+	}
+}
+
+// Box draws a single-line box around the edge of a canvas rectangle.
+func (cv *Canvas) Box(x, y, width, height int, fg, bg tb.Attribute) {
+	if width < 2 || height < 2 {
+		return
+	}
+	x1, y1 := x+width-1, y+height-1
+	for i := x + 1; i < x1; i++ {
+		cv.joinLine(i, y, lineLeft|lineRight, 0, fg, bg)
+		cv.joinLine(i, y1, lineLeft|lineRight, 0, fg, bg)
+	}
+	for j := y + 1; j < y1; j++ {
+		cv.joinLine(x, j, lineUp|lineDown, 0, fg, bg)
+		cv.joinLine(x1, j, lineUp|lineDown, 0, fg, bg)
+	}
+	cv.joinLine(x, y, lineDown|lineRight, 0, fg, bg)
+	cv.joinLine(x1, y, lineDown|lineLeft, 0, fg, bg)
+	cv.joinLine(x, y1, lineUp|lineRight, 0, fg, bg)
+	cv.joinLine(x1, y1, lineUp|lineLeft, 0, fg, bg)
+}
+
+// joinLine draws the arms of a line through a cell, merging them with a
+// box-drawing character already in the cell. The outer arms are those
+// reaching past the end of the line; they are dropped when merging, so a
+// line ending on another line forms a tee rather than a cross.
+func (cv *Canvas) joinLine(x, y, arms, outer int, fg, bg tb.Attribute) {
+	c, ok := cv.cell(x, y)
+	if !ok {
+		return
+	}
+	if m, ok := boxMask(c.Ch); ok {
+		arms = arms&^outer | m
+	}
+	cv.SetCell(x, y, boxRunes[arms], fg, bg)
+}
@@ -0,0 +1,383 @@
+package termwin
+
+import (
+	"sort"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+// A caret holds the state of a secondary cursor within a screenBox. The
+// primary cursor's state is stored directly in the screenBox.
+type caret struct {
+	cursor    coord  // cursor position
+	lastX     int    // cursor X position after last horz move
+	selecting bool   // cursor in selecting mode
+	selection crange // current selection range
+}
+
+// A trackedCaret records the buffer offsets of a caret while an operation
+// is applied to every cursor.
+type trackedCaret struct {
+	caret
+	primary bool // caret is the primary cursor
+	cur     int  // buffer offset of the cursor
+	sel0    int  // buffer offset of the selection anchor
+	sel1    int  // buffer offset of the selection end
+}
+
+// extent returns the lowest and highest buffer offsets covered by the
+// caret's cursor and selection.
+func (t *trackedCaret) extent() (lo, hi int) {
+	lo, hi = t.cur, t.cur
+	if t.selecting {
+		lo, hi = min(lo, min(t.sel0, t.sel1)), max(hi, max(t.sel0, t.sel1))
+	}
+	return
+}
+
+// AddCursorAbove adds a cursor on the row above the top-most cursor. The new
+// cursor becomes the primary cursor.
+func (b *screenBox) AddCursorAbove() {
+	b.addCaretRow(-1)
+}
+
+// AddCursorBelow adds a cursor on the row below the bottom-most cursor. The
+// new cursor becomes the primary cursor.
+func (b *screenBox) AddCursorBelow() {
+	b.addCaretRow(+1)
+}
+
+// AddCursorAtNextMatch adds a cursor selecting the next occurrence of the
+// currently selected text, searching forward from the last cursor and
+// wrapping around at the end of the buffer. If no text is selected, the word
+// under the cursor is selected instead. The new cursor becomes the primary
+// cursor.
+func (b *screenBox) AddCursorAtNextMatch() {
+	if !b.selecting || b.block {
+		b.selectWord()
+		return
+	}
+
+	text := []rune(b.Selection())
+	contents := []rune(b.Contents())
+
+	from := 0
+	taken := make(map[int]bool)
+	for _, t := range b.trackCarets() {
+		lo, hi := t.extent()
+		from = max(from, hi)
+		taken[lo] = true
+	}
+
+	p := indexRunes(contents, text, from)
+	if p < 0 {
+		p = indexRunes(contents, text, 0)
+	}
+	if p < 0 || taken[p] {
+		return
+	}
+
+	b.carets = append(b.carets, b.saveCaret())
+	b.selection = crange{b.coordOf(p), b.coordOf(p + len(text))}
+	b.highlight(b.selection)
+	b.cursor = b.selection.c1
+	b.lastX = b.cursor.x
+	b.updateView()
+	b.updateDirtyRect(b.view)
+}
+
+// ClearCursors removes all secondary cursors, leaving only the primary
+// cursor.
+func (b *screenBox) ClearCursors() {
+	for _, c := range b.carets {
+		if c.selecting {
+			b.unhighlight(c.selection.ordered())
+		}
+	}
+	b.carets = nil
+	b.updateDirtyRect(b.view)
+}
+
+// CursorCount returns the number of cursors in the edit buffer, including
+// the primary cursor.
+func (b *screenBox) CursorCount() int {
+	return len(b.carets) + 1
+}
+
+// addCaretRow adds a cursor dy rows away from the top-most (dy < 0) or
+// bottom-most (dy > 0) cursor.
+func (b *screenBox) addCaretRow(dy int) {
+	b.endBlockSelection()
+
+	edge := b.saveCaret()
+	for _, c := range b.carets {
+		if (dy < 0 && c.cursor.y < edge.cursor.y) || (dy > 0 && c.cursor.y > edge.cursor.y) {
+			edge = c
+		}
+	}
+
+	y := edge.cursor.y + dy
+	if y < 0 || y >= len(b.rows) {
+		return
+	}
+
+	b.carets = append(b.carets, b.saveCaret())
+	b.cursor = coord{min(edge.lastX, b.rowLen(y)), y}
+	b.lastX = edge.lastX
+	b.selecting = false
+	b.updateView()
+	b.updateDirtyRect(b.view)
+}
+
+// selectWord selects the word under the primary cursor.
+func (b *screenBox) selectWord() {
+	b.endBlockSelection()
+
+	c0 := b.cursor
+	for {
+		p, r, err := b.prevThenGet(c0)
+		if err != nil || isWhitespace(r) {
+			break
+		}
+		c0 = p
+	}
+	c1 := b.cursor
+	for {
+		n, r, err := b.getThenNext(c1)
+		if err != nil || isWhitespace(r) {
+			break
+		}
+		c1 = n
+	}
+	if c0.equals(c1) {
+		return
+	}
+
+	if b.selecting {
+		b.unhighlight(b.selection.ordered())
+	}
+	b.selection = crange{c0, c1}
+	b.selecting = true
+	b.highlight(b.selection)
+	b.cursor = c1
+	b.lastX = b.cursor.x
+	b.updateView()
+}
+
+// endBlockSelection ends the current selection if it is rectangular. Block
+// selections cannot be combined with multiple cursors.
+func (b *screenBox) endBlockSelection() {
+	if b.selecting && b.block {
+		b.unhighlightSelection()
+		b.selecting = false
+	}
+}
+
+// forEachCaret calls f once for every cursor in the edit buffer, with the
+// cursor's state loaded into the screenBox. Cursors are processed from the
+// end of the buffer to the start, so edits made at one cursor never move the
+// cursors still waiting to be processed. Cursors that were already processed
+// are shifted by the number of characters inserted or deleted. Cursors that
+// end up overlapping are collapsed into one.
+func (b *screenBox) forEachCaret(f func()) {
+	if b.inCarets || len(b.carets) == 0 {
+		f()
+		return
+	}
+
+	b.inCarets = true
+	defer func() { b.inCarets = false }()
+
+	all := b.trackCarets()
+	sort.Slice(all, func(i, j int) bool {
+		_, hi := all[i].extent()
+		_, hj := all[j].extent()
+		return hi > hj
+	})
+
+	view := b.view
+	for i := range all {
+		t := &all[i]
+		b.loadCaret(t)
+
+		n := b.textLen()
+		f()
+		delta := b.textLen() - n
+
+		t.caret = b.saveCaret()
+		b.trackOffsets(t)
+		if delta == 0 {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			p := &all[j]
+			p.cur = max(p.cur+delta, t.cur)
+			p.sel0 = max(p.sel0+delta, t.cur)
+			p.sel1 = max(p.sel1+delta, t.cur)
+		}
+	}
+
+	b.collapseCarets(all)
+	b.view = view
+	b.updateView()
+	b.updateDirtyRect(b.view)
+}
+
+// trackCarets returns the state of every cursor, including the primary
+// cursor, along with their buffer offsets.
+func (b *screenBox) trackCarets() []trackedCaret {
+	all := make([]trackedCaret, 0, len(b.carets)+1)
+	all = append(all, trackedCaret{caret: b.saveCaret(), primary: true})
+	for _, c := range b.carets {
+		all = append(all, trackedCaret{caret: c})
+	}
+	for i := range all {
+		b.trackOffsets(&all[i])
+	}
+	return all
+}
+
+// trackOffsets updates a tracked caret's buffer offsets from its
+// coordinates.
+func (b *screenBox) trackOffsets(t *trackedCaret) {
+	t.cur = b.offsetOf(t.cursor)
+	t.sel0 = b.offsetOf(t.selection.c0)
+	t.sel1 = b.offsetOf(t.selection.c1)
+}
+
+// collapseCarets removes cursors that overlap other cursors and stores the
+// survivors back into the screenBox. The primary cursor always survives.
+func (b *screenBox) collapseCarets(all []trackedCaret) {
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].cur < all[j].cur
+	})
+
+	keep := all[:0]
+	for _, t := range all {
+		if n := len(keep); n > 0 {
+			prev := &keep[n-1]
+			_, hi := prev.extent()
+			lo, _ := t.extent()
+			if lo < hi || t.cur == prev.cur {
+				if t.primary {
+					*prev, t = t, *prev
+				}
+				if t.selecting {
+					b.unhighlight(crange{b.coordOf(t.sel0), b.coordOf(t.sel1)}.ordered())
+				}
+				if prev.selecting {
+					b.highlight(crange{b.coordOf(prev.sel0), b.coordOf(prev.sel1)}.ordered())
+				}
+				continue
+			}
+		}
+		keep = append(keep, t)
+	}
+
+	b.carets = b.carets[:0]
+	for i := range keep {
+		if keep[i].primary {
+			b.loadCaret(&keep[i])
+		} else {
+			b.carets = append(b.carets, b.untrack(&keep[i]))
+		}
+	}
+}
+
+// saveCaret returns the state of the primary cursor.
+func (b *screenBox) saveCaret() caret {
+	return caret{
+		cursor:    b.cursor,
+		lastX:     b.lastX,
+		selecting: b.selecting,
+		selection: b.selection,
+	}
+}
+
+// loadCaret makes a tracked caret the primary cursor.
+func (b *screenBox) loadCaret(t *trackedCaret) {
+	c := b.untrack(t)
+	b.cursor = c.cursor
+	b.lastX = c.lastX
+	b.selecting = c.selecting
+	b.selection = c.selection
+	b.block = false
+}
+
+// untrack returns the state of a tracked caret, converting its buffer
+// offsets back into coordinates.
+func (b *screenBox) untrack(t *trackedCaret) caret {
+	return caret{
+		cursor:    b.coordOf(t.cur),
+		lastX:     t.lastX,
+		selecting: t.selecting,
+		selection: crange{b.coordOf(t.sel0), b.coordOf(t.sel1)},
+	}
+}
+
+// offsetOf returns the number of characters in the edit buffer preceding
+// a buffer coordinate, counting the newline that ends each row.
+func (b *screenBox) offsetOf(c coord) int {
+	o := 0
+	for y := 0; y < c.y && y < len(b.rows); y++ {
+		o += len(b.rows[y].cells)
+	}
+	return o + c.x
+}
+
+// coordOf returns the buffer coordinate of the character at a buffer
+// offset. Offsets past the end of the buffer map to the end of the last row.
+func (b *screenBox) coordOf(o int) coord {
+	last := len(b.rows) - 1
+	for y := 0; y < last; y++ {
+		n := len(b.rows[y].cells)
+		if o < n {
+			return coord{o, y}
+		}
+		o -= n
+	}
+	return coord{min(max(o, 0), len(b.rows[last].cells)), last}
+}
+
+// textLen returns the total number of characters in the edit buffer,
+// including newlines.
+func (b *screenBox) textLen() int {
+	n := 0
+	for i := range b.rows {
+		n += len(b.rows[i].cells)
+	}
+	return n
+}
+
+// drawCarets draws the secondary cursors visible within the view as
+// reversed cells.
+func (b *screenBox) drawCarets(cv *Canvas) {
+	for _, c := range b.carets {
+		if c.cursor.x < b.view.x0 || c.cursor.x >= b.view.x1 ||
+			c.cursor.y < b.view.y0 || c.cursor.y >= b.view.y1 {
+			continue
+		}
+		cv.setAttr(c.cursor.x-b.view.x0, c.cursor.y-b.view.y0, tb.AttrReverse)
+	}
+}
+
+// indexRunes returns the index of the first occurrence of needle in hay at
+// or after position from, or -1 if there is none.
+func indexRunes(hay, needle []rune, from int) int {
+	if len(needle) == 0 {
+		return -1
+	}
+	for i := from; i+len(needle) <= len(hay); i++ {
+		match := true
+		for j, r := range needle {
+			if hay[i+j] != r {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
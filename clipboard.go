@@ -1,18 +1,344 @@
 package termwin
 
-var clipboard string
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+)
 
-// ClipboardSet sets the contents of the clipboard.
-func ClipboardSet(s string) {
-	clipboard = s
+// A Clipboard is a provider of clipboard storage. The termwin clipboard
+// functions and the copy/cut/paste operations of all controls use the
+// provider selected when termwin is initialized.
+type Clipboard interface {
+	// Get returns the current contents of the clipboard.
+	Get() (string, error)
+
+	// Set replaces the contents of the clipboard.
+	Set(s string) error
+}
+
+// clipboard is the active clipboard provider. Every string set on the
+// clipboard is also pushed onto the kill ring, which keeps the clipboard
+// working within the application even when the provider fails.
+var (
+	clipboard Clipboard = new(MemoryClipboard)
+	ring                = killRing{size: 60}
+)
+
+// ClipboardSet sets the contents of the clipboard. The previous contents
+// remain available in the clipboard's kill ring.
+func ClipboardSet(s string) error {
+	ring.push(s)
+	return clipboard.Set(s)
+}
+
+// ClipboardAppend appends a string to the most recent clipboard entry
+// instead of creating a new kill ring entry. This is used to merge
+// consecutive kill commands into a single entry.
+func ClipboardAppend(s string) error {
+	if ring.len() == 0 {
+		return ClipboardSet(s)
+	}
+	e := &ring.entries[0]
+	e.text, e.block = e.text+s, false
+	ring.yank = 0
+	return clipboard.Set(e.text)
 }
 
 // ClipboardClear clears the current contents of the clipboard.
-func ClipboardClear() {
-	clipboard = ""
+func ClipboardClear() error {
+	return clipboard.Set("")
 }
 
-// ClipboardGet returns the current contents of the clipboard
+// ClipboardGet returns the current contents of the clipboard. If the
+// clipboard was changed by another application, its contents are pushed onto
+// the kill ring.
 func ClipboardGet() string {
+	s, err := clipboard.Get()
+	if err != nil {
+		logger.Warn("clipboard read failed", "err", err)
+		return ring.top()
+	}
+	if s != "" && s != ring.top() {
+		ring.push(s)
+	}
+	return s
+}
+
+// clipboardPaste returns the clipboard contents for a paste. Pasting the
+// most recent kill ring entry restarts yank-pop cycling from that entry.
+func clipboardPaste() string {
+	s := ClipboardGet()
+	if s == ring.top() {
+		ring.yank = 0
+	}
+	return s
+}
+
+// ClipboardRing returns the contents of the kill ring, ordered from most to
+// least recent.
+func ClipboardRing() []string {
+	s := make([]string, len(ring.entries))
+	for i, e := range ring.entries {
+		s[i] = e.text
+	}
+	return s
+}
+
+// ClipboardRingSize returns the maximum number of entries in the kill ring.
+func ClipboardRingSize() int {
+	return ring.size
+}
+
+// SetClipboardRingSize sets the maximum number of entries retained by the
+// kill ring. The oldest entries are discarded when the ring is full. The
+// size is never less than one.
+func SetClipboardRingSize(n int) {
+	ring.resize(max(n, 1))
+}
+
+// ClipboardYankPop advances the kill ring's yank pointer to the next older
+// entry and returns it. After the oldest entry, the pointer wraps around to
+// the most recent entry.
+func ClipboardYankPop() string {
+	return ring.rotate()
+}
+
+// clipboardSetBlock sets the contents of the clipboard to a block of text
+// copied from a rectangular selection.
+func clipboardSetBlock(s string) error {
+	ring.pushBlock(s)
+	return clipboard.Set(s)
+}
+
+// clipboardIsBlock returns true if s is the most recent clipboard entry and
+// was copied from a rectangular selection.
+func clipboardIsBlock(s string) bool {
+	return len(ring.entries) > 0 && ring.entries[0].block && ring.entries[0].text == s
+}
+
+// ClipboardProvider returns the clipboard provider currently in use.
+func ClipboardProvider() Clipboard {
 	return clipboard
 }
+
+// SetClipboardProvider replaces the clipboard provider. Passing nil restores
+// the default in-memory provider.
+func SetClipboardProvider(cb Clipboard) {
+	if cb == nil {
+		cb = new(MemoryClipboard)
+	}
+	clipboard = cb
+}
+
+//
+// killRing
+//
+
+// A killRing holds a bounded history of clipboard entries, most recent
+// first, along with a yank pointer used to cycle through older entries.
+type killRing struct {
+	entries []ringEntry // ring entries, most recent first
+	size    int         // maximum number of entries
+	yank    int         // index of the entry most recently yanked
+}
+
+// A ringEntry is a single entry in the kill ring.
+type ringEntry struct {
+	text  string // clipboard text
+	block bool   // text was copied from a rectangular selection
+}
+
+func (k *killRing) len() int {
+	return len(k.entries)
+}
+
+func (k *killRing) top() string {
+	if len(k.entries) == 0 {
+		return ""
+	}
+	return k.entries[0].text
+}
+
+func (k *killRing) push(s string) {
+	k.pushEntry(ringEntry{text: s})
+}
+
+func (k *killRing) pushBlock(s string) {
+	k.pushEntry(ringEntry{text: s, block: true})
+}
+
+func (k *killRing) pushEntry(e ringEntry) {
+	k.yank = 0
+	if e.text == "" {
+		return
+	}
+	if len(k.entries) < k.size {
+		k.entries = append(k.entries, ringEntry{})
+	}
+	copy(k.entries[1:], k.entries)
+	k.entries[0] = e
+}
+
+func (k *killRing) resize(n int) {
+	k.size = n
+	if len(k.entries) > n {
+		k.entries = k.entries[:n]
+	}
+	if k.yank >= n {
+		k.yank = 0
+	}
+}
+
+func (k *killRing) rotate() string {
+	if len(k.entries) == 0 {
+		return ""
+	}
+	k.yank = (k.yank + 1) % len(k.entries)
+	return k.entries[k.yank].text
+}
+
+//
+// MemoryClipboard
+//
+
+// A MemoryClipboard stores clipboard contents in process memory. It is the
+// default clipboard provider. Its contents are not visible to other
+// applications.
+type MemoryClipboard struct {
+	text string
+}
+
+// Get returns the current contents of the clipboard.
+func (m *MemoryClipboard) Get() (string, error) {
+	return m.text, nil
+}
+
+// Set replaces the contents of the clipboard.
+func (m *MemoryClipboard) Set(s string) error {
+	m.text = s
+	return nil
+}
+
+//
+// OSC52Clipboard
+//
+
+// An OSC52Clipboard copies text to the host terminal's clipboard using the
+// OSC 52 escape sequence. Because the sequence travels through the terminal
+// stream, it works over SSH and inside most terminal multiplexers.
+//
+// Few terminals allow applications to read the clipboard, so Get returns
+// the text most recently set through this provider.
+type OSC52Clipboard struct {
+	w    io.Writer
+	text string
+}
+
+// NewOSC52Clipboard creates a clipboard provider that writes OSC 52 escape
+// sequences to w. If w is nil, the sequences are written to the controlling
+// terminal.
+func NewOSC52Clipboard(w io.Writer) *OSC52Clipboard {
+	return &OSC52Clipboard{w: w}
+}
+
+// Get returns the text most recently copied to the clipboard.
+func (o *OSC52Clipboard) Get() (string, error) {
+	return o.text, nil
+}
+
+// Set copies text to the terminal's clipboard.
+func (o *OSC52Clipboard) Set(s string) error {
+	o.text = s
+
+	w := o.w
+	if w == nil {
+		tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer tty.Close()
+		w = tty
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("\x1b]52;c;")
+	buf.WriteString(base64.StdEncoding.EncodeToString([]byte(s)))
+	buf.WriteString("\x07")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+//
+// CommandClipboard
+//
+
+// A CommandClipboard exchanges text with the system clipboard by running
+// external commands such as xclip, xsel or wl-copy. Text is written to the
+// standard input of the copy command and read from the standard output of
+// the paste command.
+type CommandClipboard struct {
+	CopyCmd  []string // command and arguments used to set the clipboard
+	PasteCmd []string // command and arguments used to read the clipboard
+}
+
+// NewCommandClipboard creates a clipboard provider that runs the specified
+// copy and paste commands.
+func NewCommandClipboard(copyCmd, pasteCmd []string) *CommandClipboard {
+	return &CommandClipboard{
+		CopyCmd:  copyCmd,
+		PasteCmd: pasteCmd,
+	}
+}
+
+// commandClipboards lists well-known clipboard commands in order of
+// preference.
+var commandClipboards = []CommandClipboard{
+	{[]string{"wl-copy"}, []string{"wl-paste", "--no-newline"}},
+	{[]string{"xclip", "-selection", "clipboard", "-in"}, []string{"xclip", "-selection", "clipboard", "-out"}},
+	{[]string{"xsel", "--clipboard", "--input"}, []string{"xsel", "--clipboard", "--output"}},
+	{[]string{"pbcopy"}, []string{"pbpaste"}},
+}
+
+// FindCommandClipboard searches the path for a well-known clipboard command
+// (wl-copy, xclip, xsel or pbcopy) and returns a provider that uses it. It
+// returns nil if no such command is installed.
+func FindCommandClipboard() *CommandClipboard {
+	for _, cc := range commandClipboards {
+		if _, err := exec.LookPath(cc.CopyCmd[0]); err != nil {
+			continue
+		}
+		if _, err := exec.LookPath(cc.PasteCmd[0]); err != nil {
+			continue
+		}
+		return NewCommandClipboard(cc.CopyCmd, cc.PasteCmd)
+	}
+	return nil
+}
+
+// Get runs the paste command and returns its output.
+func (cc *CommandClipboard) Get() (string, error) {
+	if len(cc.PasteCmd) == 0 {
+		return "", errors.New("termwin: no clipboard paste command")
+	}
+
+	out, err := exec.Command(cc.PasteCmd[0], cc.PasteCmd[1:]...).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// Set runs the copy command with the text as its input.
+func (cc *CommandClipboard) Set(s string) error {
+	if len(cc.CopyCmd) == 0 {
+		return errors.New("termwin: no clipboard copy command")
+	}
+
+	cmd := exec.Command(cc.CopyCmd[0], cc.CopyCmd[1:]...)
+	cmd.Stdin = bytes.NewBufferString(s)
+	return cmd.Run()
+}
@@ -0,0 +1,120 @@
+package termwin
+
+import (
+	tb "github.com/nsf/termbox-go"
+)
+
+// A CustomWindow is a window whose contents are drawn by the application.
+// Its draw function receives a Canvas covering the window, and key and
+// mouse events are passed to the functions set with OnKey and OnMouse.
+// The window is drawn when it is first displayed, when it is uncovered and
+// after Invalidate is called.
+type CustomWindow struct {
+	corner    coord                             // screen coordinate of top-left corner
+	size      coord                             // screen dimensions of the window
+	cursor    coord                             // cursor position within the window
+	show      bool                              // cursor is displayed
+	dirty     bool                              // window needs to be redrawn
+	draw      func(cv *Canvas)                  // draws the window's contents
+	keyFunc   func(ev tb.Event) error           // called for key events
+	mouseFunc func(x, y int, ev tb.Event) error // called for mouse events
+}
+
+// NewCustomWindow creates a new CustomWindow with the specified screen
+// position and size, drawn by the function draw.
+func NewCustomWindow(x, y, width, height int, draw func(cv *Canvas)) *CustomWindow {
+	w := &CustomWindow{
+		corner: coord{x, y},
+		size:   coord{width, height},
+		dirty:  true,
+		draw:   draw,
+	}
+	addWindow(w)
+	return w
+}
+
+// OnKey sets the function called for key events while the window has the
+// focus. An error it returns is returned by Poll.
+func (w *CustomWindow) OnKey(f func(ev tb.Event) error) {
+	w.keyFunc = f
+}
+
+// OnMouse sets the function called for mouse events on the window. It
+// receives the position of the mouse relative to the window's top-left
+// corner. An error it returns is returned by Poll.
+func (w *CustomWindow) OnMouse(f func(x, y int, ev tb.Event) error) {
+	w.mouseFunc = f
+}
+
+// SetCursor sets the position of the cursor relative to the window's
+// top-left corner, and whether it is displayed while the window has the
+// focus.
+func (w *CustomWindow) SetCursor(x, y int, show bool) {
+	w.cursor, w.show = coord{x, y}, show
+}
+
+// Invalidate causes the window to be redrawn by the next call to Flush.
+// Call it from another goroutine only through Post.
+func (w *CustomWindow) Invalidate() {
+	w.dirty = true
+}
+
+// Bounds returns the screen position and size of the window.
+func (w *CustomWindow) Bounds() (x, y, width, height int) {
+	return w.corner.x, w.corner.y, w.size.x, w.size.y
+}
+
+func (w *CustomWindow) bounds() rect {
+	return newRect(w.corner.x, w.corner.y, w.size.x, w.size.y)
+}
+
+func (w *CustomWindow) dirtyRect() rect {
+	if !w.dirty {
+		return emptyRect
+	}
+	return w.bounds()
+}
+
+func (w *CustomWindow) invalidate() {
+	w.dirty = true
+}
+
+func (w *CustomWindow) move(x, y int) {
+	damage(w.bounds())
+	w.corner = coord{x, y}
+	w.dirty = true
+}
+
+func (w *CustomWindow) resize(width, height int) {
+	damage(w.bounds())
+	w.size = coord{width, height}
+	w.dirty = true
+}
+
+func (w *CustomWindow) getCursor() (x, y int, show bool) {
+	return w.corner.x + w.cursor.x, w.corner.y + w.cursor.y, w.show
+}
+
+func (w *CustomWindow) onKey(ev tb.Event) error {
+	if w.keyFunc == nil {
+		return nil
+	}
+	return w.keyFunc(ev)
+}
+
+func (w *CustomWindow) onMouse(ev tb.Event) error {
+	if w.mouseFunc == nil {
+		return nil
+	}
+	return w.mouseFunc(ev.MouseX-w.corner.x, ev.MouseY-w.corner.y, ev)
+}
+
+func (w *CustomWindow) onDraw(cv *Canvas) {
+	if !w.dirty {
+		return
+	}
+	w.dirty = false
+	if w.draw != nil {
+		w.draw(cv)
+	}
+}
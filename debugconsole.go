@@ -0,0 +1,362 @@
+package termwin
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+const (
+	// debugConsoleLines is the number of lines kept by a debug console.
+	debugConsoleLines = 500
+
+	// debugHighlightBg is the background color of the cells highlighted
+	// by a debug console when they are redrawn.
+	debugHighlightBg = tb.ColorMagenta
+)
+
+// keyNames maps termbox keys to the names displayed by the debug console.
+var keyNames = map[tb.Key]string{
+	tb.KeyF1: "F1", tb.KeyF2: "F2", tb.KeyF3: "F3", tb.KeyF4: "F4",
+	tb.KeyF5: "F5", tb.KeyF6: "F6", tb.KeyF7: "F7", tb.KeyF8: "F8",
+	tb.KeyF9: "F9", tb.KeyF10: "F10", tb.KeyF11: "F11", tb.KeyF12: "F12",
+	tb.KeyInsert: "Insert", tb.KeyDelete: "Delete",
+	tb.KeyHome: "Home", tb.KeyEnd: "End", tb.KeyPgup: "PgUp", tb.KeyPgdn: "PgDn",
+	tb.KeyArrowUp: "Up", tb.KeyArrowDown: "Down",
+	tb.KeyArrowLeft: "Left", tb.KeyArrowRight: "Right",
+	tb.KeyTab: "Tab", tb.KeyEnter: "Enter", tb.KeyEsc: "Esc",
+	tb.KeySpace: "Space", tb.KeyBackspace: "Backspace", tb.KeyBackspace2: "Backspace2",
+}
+
+// mouseNames maps termbox mouse keys to the names displayed by the debug
+// console.
+var mouseNames = map[tb.Key]string{
+	tb.MouseLeft: "left", tb.MouseMiddle: "middle", tb.MouseRight: "right",
+	tb.MouseRelease: "release", tb.MouseWheelUp: "wheel-up", tb.MouseWheelDown: "wheel-down",
+}
+
+// A savedCell is a screen cell overwritten by a redraw highlight.
+type savedCell struct {
+	x, y int
+	cell tb.Cell
+}
+
+// A DebugConsole is an overlay window for diagnosing termwin applications
+// from inside the application. It shows recent log messages, a live stream
+// of decoded input events, including the raw bytes of escape sequences, and
+// the window with the focus. It can also highlight the area redrawn by each
+// window on every flush.
+//
+// The console is hidden when created and is toggled by pressing F12. It
+// never takes the focus, and the mouse wheel scrolls its history. Input
+// events are kept only in memory and are never written to the log.
+type DebugConsole struct {
+	mu        sync.Mutex // guards lines, which log messages may append from any goroutine
+	lines     []string   // recent log messages and events, oldest first
+	corner    coord      // screen coordinate of top-left corner
+	size      coord      // screen dimensions of the console
+	key       tb.Key     // key toggling the console
+	level     slog.LevelVar
+	visible   bool        // console is displayed
+	highlight bool        // redrawn areas are highlighted
+	saved     []savedCell // cells overwritten by the last highlight
+	scroll    int         // lines scrolled back from the newest
+	dirty     bool        // console needs to be redrawn
+}
+
+// NewDebugConsole creates the application's debug console with the
+// specified screen position and size. The console receives log messages at
+// the info level and above, in addition to any log output selected with
+// SetLogger. Creating a new console replaces the previous one.
+func NewDebugConsole(x, y, width, height int) *DebugConsole {
+	if c.console != nil {
+		c.console.Close()
+	}
+	d := &DebugConsole{
+		corner: coord{x, y},
+		size:   coord{width, height},
+		key:    tb.KeyF12,
+	}
+	d.level.Set(LevelInfo)
+	setLogTap(slog.NewTextHandler(d, &slog.HandlerOptions{
+		Level:       &d.level,
+		ReplaceAttr: shortTime,
+	}))
+	c.console = d
+	return d
+}
+
+// shortTime replaces the time of a log record with the time of day, to
+// save space in the console.
+func shortTime(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.TimeKey && len(groups) == 0 {
+		return slog.String(a.Key, a.Value.Time().Format("15:04:05.000"))
+	}
+	return a
+}
+
+// Close hides the console and stops it from receiving log messages and
+// input events.
+func (d *DebugConsole) Close() {
+	d.Hide()
+	if c.console == d {
+		c.console = nil
+		setLogTap(nil)
+	}
+}
+
+// Show displays the console on top of all other windows.
+func (d *DebugConsole) Show() {
+	if !d.visible {
+		d.visible = true
+		d.scroll = 0
+		addWindow(d)
+		d.dirty = true
+	}
+}
+
+// Hide removes the console from the screen.
+func (d *DebugConsole) Hide() {
+	if d.visible {
+		d.visible = false
+		RemoveWindow(d)
+	}
+}
+
+// Toggle shows the console if it is hidden and hides it otherwise.
+func (d *DebugConsole) Toggle() {
+	if d.visible {
+		d.Hide()
+	} else {
+		d.Show()
+	}
+}
+
+// Visible returns true if the console is displayed.
+func (d *DebugConsole) Visible() bool {
+	return d.visible
+}
+
+// SetToggleKey changes the key that shows and hides the console.
+func (d *DebugConsole) SetToggleKey(k tb.Key) {
+	d.key = k
+}
+
+// SetLogLevel sets the minimum level of the log messages shown by the
+// console.
+func (d *DebugConsole) SetLogLevel(l slog.Level) {
+	d.level.Set(l)
+}
+
+// SetHighlight enables or disables highlighting of the screen areas
+// redrawn by each flush. A highlight lasts until the next flush.
+func (d *DebugConsole) SetHighlight(on bool) {
+	d.highlight = on
+}
+
+// Clear removes all lines from the console.
+func (d *DebugConsole) Clear() {
+	d.mu.Lock()
+	d.lines = nil
+	d.mu.Unlock()
+	d.scroll = 0
+	d.dirty = true
+}
+
+// Write adds the lines written by the console's log handler. It may be
+// called from any goroutine; the console is redrawn through Post.
+func (d *DebugConsole) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		d.addLine(line)
+	}
+	Post(d.invalidate)
+	return len(p), nil
+}
+
+// addLine appends a line to the console, discarding the oldest line if the
+// console is full. The caller arranges for the console to be redrawn.
+func (d *DebugConsole) addLine(s string) {
+	d.mu.Lock()
+	if len(d.lines) >= debugConsoleLines {
+		d.lines = append(d.lines[:0], d.lines[1:]...)
+	}
+	d.lines = append(d.lines, sanitizeLine(s))
+	d.mu.Unlock()
+}
+
+// isToggle returns true if a key event toggles the console.
+func (d *DebugConsole) isToggle(ev tb.Event) bool {
+	return ev.Ch == 0 && ev.Key == d.key && ev.Mod == 0
+}
+
+// recordEvent adds a decoded input event to the console. The seq argument
+// holds the raw bytes of the escape sequence decoded into the event, if
+// any.
+func (d *DebugConsole) recordEvent(ev tb.Event, seq string) {
+	var b strings.Builder
+	b.WriteString(time.Now().Format("15:04:05.000"))
+	switch ev.Type {
+	case tb.EventKey:
+		b.WriteString(" key")
+		if ev.Ch != 0 {
+			fmt.Fprintf(&b, " ch=%q (U+%04X)", ev.Ch, ev.Ch)
+		} else if name, ok := keyNames[ev.Key]; ok {
+			fmt.Fprintf(&b, " key=%s", name)
+		} else {
+			fmt.Fprintf(&b, " key=0x%04X", uint16(ev.Key))
+		}
+	case tb.EventMouse:
+		name, ok := mouseNames[ev.Key]
+		if !ok {
+			name = fmt.Sprintf("0x%04X", uint16(ev.Key))
+		}
+		fmt.Fprintf(&b, " mouse %s (%d,%d)", name, ev.MouseX, ev.MouseY)
+	case tb.EventResize:
+		fmt.Fprintf(&b, " resize %dx%d", ev.Width, ev.Height)
+	default:
+		return
+	}
+	if mods := modNames(ev.Mod); mods != "" {
+		b.WriteString(" mod=" + mods)
+	}
+	if seq != "" {
+		fmt.Fprintf(&b, " seq=%q", "\x1b["+seq)
+	}
+	d.addLine(b.String())
+	d.dirty = true
+}
+
+// modNames returns the names of the modifiers in a mask, separated by '+'.
+func modNames(m tb.Modifier) string {
+	var names []string
+	if (m & tb.ModCtrl) != 0 {
+		names = append(names, "ctrl")
+	}
+	if (m & tb.ModAlt) != 0 {
+		names = append(names, "alt")
+	}
+	if (m & tb.ModShift) != 0 {
+		names = append(names, "shift")
+	}
+	if (m & tb.ModMotion) != 0 {
+		names = append(names, "motion")
+	}
+	return strings.Join(names, "+")
+}
+
+// restoreHighlights restores the cells changed by the last highlight.
+func (d *DebugConsole) restoreHighlights() {
+	sw, sh := tb.Size()
+	for _, s := range d.saved {
+		if s.x < sw && s.y < sh {
+			tb.SetCell(s.x, s.y, s.cell.Ch, s.cell.Fg, s.cell.Bg)
+		}
+	}
+	d.saved = d.saved[:0]
+}
+
+// highlightRects changes the background of the cells in the redrawn screen
+// rectangles, saving them so they can be restored on the next flush.
+func (d *DebugConsole) highlightRects(rs []rect) {
+	sw, sh := tb.Size()
+	buf := tb.CellBuffer()
+	done := make(map[coord]bool)
+	for _, r := range rs {
+		r = intersection(r, newRect(0, 0, sw, sh))
+		for y := r.y0; y < r.y1; y++ {
+			for x := r.x0; x < r.x1; x++ {
+				if done[coord{x, y}] || (d.visible && contains(d.bounds(), x, y)) {
+					continue
+				}
+				done[coord{x, y}] = true
+				cell := buf[y*sw+x]
+				d.saved = append(d.saved, savedCell{x, y, cell})
+				tb.SetCell(x, y, cell.Ch, cell.Fg, debugHighlightBg)
+			}
+		}
+	}
+}
+
+func (d *DebugConsole) canFocus() bool {
+	return false
+}
+
+func (d *DebugConsole) bounds() rect {
+	return newRect(d.corner.x, d.corner.y, d.size.x, d.size.y)
+}
+
+func (d *DebugConsole) dirtyRect() rect {
+	if !d.dirty {
+		return emptyRect
+	}
+	return d.bounds()
+}
+
+func (d *DebugConsole) invalidate() {
+	d.dirty = true
+}
+
+func (d *DebugConsole) move(x, y int) {
+	damage(d.bounds())
+	d.corner = coord{x, y}
+	d.dirty = true
+}
+
+func (d *DebugConsole) resize(width, height int) {
+	damage(d.bounds())
+	d.size = coord{width, height}
+	d.dirty = true
+}
+
+func (d *DebugConsole) getCursor() (x, y int, show bool) {
+	return 0, 0, false
+}
+
+func (d *DebugConsole) onKey(ev tb.Event) error {
+	return nil
+}
+
+func (d *DebugConsole) onMouse(ev tb.Event) error {
+	switch ev.Key {
+	case tb.MouseWheelUp:
+		d.scroll += mouseWheelRows
+	case tb.MouseWheelDown:
+		d.scroll = max(d.scroll-mouseWheelRows, 0)
+	default:
+		return nil
+	}
+	d.dirty = true
+	return nil
+}
+
+func (d *DebugConsole) onDraw(cv *Canvas) {
+	if !d.dirty {
+		return
+	}
+	d.dirty = false
+
+	fg, bg := tb.ColorWhite, tb.ColorBlack
+	cv.Clear(fg, bg)
+	cv.Box(0, 0, d.size.x, d.size.y, fg, bg)
+	cv.DrawText(2, 0, d.size.x-4, " Debug ", fg|tb.AttrBold, bg)
+
+	focus := "focus: none"
+	if c.focus != nil {
+		b := c.focus.bounds()
+		focus = fmt.Sprintf("focus: %T (%d,%d %dx%d)", c.focus, b.x0, b.y0, b.x1-b.x0, b.y1-b.y0)
+	}
+	cv.DrawText(1, 1, d.size.x-2, focus, tb.ColorYellow, bg)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	rows := max(d.size.y-3, 0)
+	d.scroll = min(d.scroll, max(len(d.lines)-rows, 0))
+	end := len(d.lines) - d.scroll
+	for i, line := range d.lines[max(end-rows, 0):end] {
+		cv.DrawText(1, 2+i, d.size.x-2, line, fg, bg)
+	}
+}
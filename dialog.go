@@ -0,0 +1,341 @@
+package termwin
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+const (
+	boxTopLeft     = '┌'
+	boxTopRight    = '┐'
+	boxBottomLeft  = '└'
+	boxBottomRight = '┘'
+	boxHorizontal  = '─'
+	boxVertical    = '│'
+)
+
+// A Dialog is a modal window displaying a message, an optional text input
+// and a row of buttons. The dialog captures all input until the user
+// presses one of its buttons or the dialog is closed.
+type Dialog struct {
+	corner  coord            // screen coordinate of top-left corner
+	size    coord            // screen dimensions of the dialog
+	title   string           // text displayed in the top border
+	message string           // message displayed in the body
+	lines   []string         // message wrapped to the dialog width
+	buttons []string         // button labels
+	buttonX []int            // column of each button within the dialog
+	input   *TextInput       // text input, or nil
+	focus   int              // focused control: -1 for input, or button
+	dirty   bool             // dialog needs to be redrawn
+	onClose func(button int) // called when the dialog is closed
+}
+
+// NewDialog creates a new dialog with a title, message and buttons. The
+// dialog is not displayed until Show is called.
+func NewDialog(title, message string, buttons ...string) *Dialog {
+	return &Dialog{
+		title:   title,
+		message: message,
+		buttons: buttons,
+		dirty:   true,
+	}
+}
+
+// AddInput adds a single-line text input below the dialog's message,
+// initialized with text. The input has the focus when the dialog is shown,
+// and pressing Enter in the input presses the first button.
+func (d *Dialog) AddInput(text string) *TextInput {
+	d.input = newTextInput(0, 0, 1)
+	d.input.SetText(text)
+	d.focus = -1
+	return d.input
+}
+
+// InputText returns the contents of the dialog's text input.
+func (d *Dialog) InputText() string {
+	if d.input == nil {
+		return ""
+	}
+	return d.input.Text()
+}
+
+// OnClose sets the function called when the dialog is closed. It receives
+// the index of the button pressed, or -1 if the dialog was cancelled with
+// the Escape key.
+func (d *Dialog) OnClose(f func(button int)) {
+	d.onClose = f
+}
+
+// Show centers the dialog on the screen and displays it modally.
+func (d *Dialog) Show() {
+	d.layout()
+	ShowModal(d)
+}
+
+// Close removes the dialog from the screen and calls its close function
+// with the index of the button pressed.
+func (d *Dialog) Close(button int) {
+	EndModal(d)
+	if d.onClose != nil {
+		d.onClose(button)
+	}
+}
+
+// layout computes the size and position of the dialog and its controls.
+func (d *Dialog) layout() {
+	sw, sh := tb.Size()
+	maxInner := max(sw-6, 10)
+
+	bw := 0
+	for _, b := range d.buttons {
+		bw += utf8.RuneCountInString(b) + 6
+	}
+
+	inner := max(utf8.RuneCountInString(d.title)+2, bw)
+	for _, line := range strings.Split(d.message, "\n") {
+		inner = max(inner, utf8.RuneCountInString(line))
+	}
+	if d.input != nil {
+		inner = max(inner, 40)
+	}
+	inner = min(inner, maxInner)
+
+	d.lines = wrapText(d.message, inner)
+	height := len(d.lines) + 5
+	if d.input != nil {
+		height += 2
+	}
+
+	d.size = coord{inner + 4, height}
+	d.corner = coord{(sw - d.size.x) / 2, max((sh-d.size.y)/2, 0)}
+
+	d.buttonX = d.buttonX[:0]
+	x := (d.size.x - bw) / 2
+	for _, b := range d.buttons {
+		d.buttonX = append(d.buttonX, x+1)
+		x += utf8.RuneCountInString(b) + 6
+	}
+
+	if d.input != nil {
+		text := d.input.Text()
+		d.input.screenBox = newScreenBox(d.corner.x+2, d.corner.y+len(d.lines)+3, inner, 1)
+		d.input.SetText(text)
+	}
+	d.dirty = true
+}
+
+func (d *Dialog) bounds() rect {
+	return newRect(d.corner.x, d.corner.y, d.size.x, d.size.y)
+}
+
+func (d *Dialog) dirtyRect() rect {
+	switch {
+	case d.dirty:
+		return d.bounds()
+	case d.input != nil:
+		return d.input.dirtyRect()
+	default:
+		return emptyRect
+	}
+}
+
+func (d *Dialog) invalidate() {
+	d.dirty = true
+	if d.input != nil {
+		d.input.invalidate()
+	}
+}
+
+func (d *Dialog) move(x, y int) {
+	damage(d.bounds())
+	d.corner = coord{x, y}
+	if d.input != nil {
+		d.input.move(x+2, y+len(d.lines)+3)
+	}
+	d.dirty = true
+}
+
+// resize does nothing, since a dialog sizes itself to fit its contents.
+func (d *Dialog) resize(width, height int) {
+}
+
+func (d *Dialog) getCursor() (x, y int, show bool) {
+	if d.focus < 0 && d.input != nil {
+		return d.input.getCursor()
+	}
+	return 0, 0, false
+}
+
+func (d *Dialog) onKey(ev tb.Event) error {
+	switch {
+	case ev.Key == tb.KeyEsc:
+		d.Close(-1)
+	case ev.Key == tb.KeyTab && (ev.Mod&tb.ModShift) != 0:
+		d.moveFocus(-1)
+	case ev.Key == tb.KeyTab:
+		d.moveFocus(+1)
+	case d.focus < 0 && ev.Key == tb.KeyEnter:
+		d.Close(0)
+	case d.focus < 0:
+		return d.input.onKey(ev)
+	case ev.Key == tb.KeyArrowLeft:
+		d.setFocus(max(d.focus-1, 0))
+	case ev.Key == tb.KeyArrowRight:
+		d.setFocus(min(d.focus+1, len(d.buttons)-1))
+	case ev.Key == tb.KeyEnter || ev.Key == tb.KeySpace:
+		d.Close(d.focus)
+	}
+	return nil
+}
+
+func (d *Dialog) onMouse(ev tb.Event) error {
+	if d.input != nil && contains(d.input.bounds(), ev.MouseX, ev.MouseY) {
+		d.setFocus(-1)
+		return d.input.onMouse(ev)
+	}
+	if ev.Key != tb.MouseLeft || (ev.Mod&tb.ModMotion) != 0 {
+		return nil
+	}
+
+	x, y := ev.MouseX-d.corner.x, ev.MouseY-d.corner.y
+	if y != d.size.y-2 {
+		return nil
+	}
+	for i, b := range d.buttons {
+		if x >= d.buttonX[i] && x < d.buttonX[i]+utf8.RuneCountInString(b)+4 {
+			d.Close(i)
+			break
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) onDraw(cv *Canvas) {
+	if d.dirty {
+		d.dirty = false
+		d.draw(cv)
+	}
+	if d.input != nil {
+		d.input.onDraw(cv.sub(d.input.bounds()))
+	}
+}
+
+// draw draws the dialog's frame, message and buttons.
+func (d *Dialog) draw(cv *Canvas) {
+	cv.Clear(theme.DialogFg, theme.DialogBg)
+	cv.Box(0, 0, d.size.x, d.size.y, theme.DialogFg, theme.DialogBg)
+
+	if d.title != "" {
+		title := " " + d.title + " "
+		tw := textWidth(title)
+		cv.DrawText(max((d.size.x-tw)/2, 1), 0, d.size.x-2, title, theme.DialogFg|tb.AttrBold, theme.DialogBg)
+	}
+
+	for i, line := range d.lines {
+		cv.DrawText(2, 2+i, d.size.x-4, line, theme.DialogFg, theme.DialogBg)
+	}
+
+	for i, b := range d.buttons {
+		fg, bg := theme.DialogFg, theme.DialogBg
+		if i == d.focus {
+			fg, bg = bg, fg
+		}
+		cv.DrawText(d.buttonX[i], d.size.y-2, d.size.x, "[ "+b+" ]", fg, bg)
+	}
+}
+
+// moveFocus moves the focus forward or backward through the dialog's input
+// and buttons.
+func (d *Dialog) moveFocus(dir int) {
+	first := 0
+	if d.input != nil {
+		first = -1
+	}
+	n := len(d.buttons) - first
+	if n == 0 {
+		return
+	}
+	d.setFocus((d.focus-first+dir+n)%n + first)
+}
+
+func (d *Dialog) setFocus(i int) {
+	if i != d.focus {
+		d.focus = i
+		d.dirty = true
+	}
+}
+
+// MessageBox displays a modal dialog with a message and an OK button. The
+// function onClose, if not nil, is called when the dialog is dismissed.
+func MessageBox(title, message string, onClose func()) *Dialog {
+	d := NewDialog(title, message, "OK")
+	d.OnClose(func(int) {
+		if onClose != nil {
+			onClose()
+		}
+	})
+	d.Show()
+	return d
+}
+
+// Confirm displays a modal dialog with a message and OK and Cancel buttons.
+// The function onClose is called with true if the user pressed OK.
+func Confirm(title, message string, onClose func(ok bool)) *Dialog {
+	d := NewDialog(title, message, "OK", "Cancel")
+	d.OnClose(func(button int) {
+		if onClose != nil {
+			onClose(button == 0)
+		}
+	})
+	d.Show()
+	return d
+}
+
+// InputPrompt displays a modal dialog asking the user to enter a line of
+// text. The function onClose is called with the text entered and true if the
+// user pressed OK or Enter.
+func InputPrompt(title, prompt, text string, onClose func(text string, ok bool)) *Dialog {
+	d := NewDialog(title, prompt, "OK", "Cancel")
+	d.AddInput(text)
+	d.OnClose(func(button int) {
+		if onClose != nil {
+			onClose(d.InputText(), button == 0)
+		}
+	})
+	d.Show()
+	return d
+}
+
+// wrapText breaks text into lines no longer than width characters,
+// breaking lines at spaces where possible.
+func wrapText(s string, width int) []string {
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		line := []rune(nil)
+		for _, word := range strings.Fields(para) {
+			w := []rune(word)
+			for len(w) > width {
+				if len(line) > 0 {
+					lines = append(lines, string(line))
+					line = nil
+				}
+				lines = append(lines, string(w[:width]))
+				w = w[width:]
+			}
+			switch {
+			case len(line) == 0:
+				line = w
+			case len(line)+1+len(w) <= width:
+				line = append(append(line, charSpace), w...)
+			default:
+				lines = append(lines, string(line))
+				line = w
+			}
+		}
+		lines = append(lines, string(line))
+	}
+	return lines
+}
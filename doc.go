@@ -0,0 +1,25 @@
+// Package termwin implements windows and controls for terminal user
+// interfaces on top of termbox.
+//
+// An application calls Init, creates windows such as EditBox, ListBox or
+// Dialog, and then calls Flush and Poll in a loop until Poll returns an
+// error. Windows are stacked in the order they are created; Raise, Lower,
+// MoveWindow, ResizeWindow and RemoveWindow rearrange them, and ShowModal
+// displays a window that captures all input until EndModal is called.
+// Background goroutines update windows through Post.
+//
+// # Custom windows
+//
+// Applications draw their own windows with NewCustomWindow, passing a
+// function that draws the window's contents on a Canvas:
+//
+//	w := termwin.NewCustomWindow(0, 0, 20, 5, func(cv *termwin.Canvas) {
+//		cv.Box(0, 0, 20, 5, termbox.ColorDefault, termbox.ColorDefault)
+//		cv.DrawText(2, 2, 16, "Hello", termbox.ColorGreen, termbox.ColorDefault)
+//	})
+//	w.OnKey(func(ev termbox.Event) error { ... })
+//
+// Canvas coordinates are relative to the window, and drawing is clipped to
+// it. The draw function is called when the window is first displayed,
+// when it is uncovered, and after Invalidate.
+package termwin
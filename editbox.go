@@ -2,6 +2,8 @@ package termwin
 
 import (
 	"errors"
+	"io/fs"
+	"strings"
 
 	tb "github.com/nsf/termbox-go"
 )
@@ -13,14 +15,33 @@ const (
 	// EditBoxWordWrap causes the edit box to word-wrap a line of text when
 	// its length reaches the right edge of the screen.
 	EditBoxWordWrap EditBoxFlags = 1 << iota
+
+	// EditBoxReadOnly prevents the user from modifying the contents of the
+	// edit box. Navigation, selection and copying to the clipboard are still
+	// allowed, and the contents may still be changed programmatically.
+	EditBoxReadOnly
+
+	// EditBoxSingleLine restricts user input to a single line. Pressing
+	// Enter calls the submit function instead of inserting a newline, and
+	// newlines in pasted text are replaced with spaces.
+	EditBoxSingleLine
 )
 
+// An InputFilter is called for each character the user types or pastes into
+// an EditBox. It returns the character to insert, which may differ from the
+// one typed, or false to reject the character.
+type InputFilter func(ch rune) (rune, bool)
+
 // An EditBox represents a editable area of text on the screen. Many common text
 // editing controls are allowed within an EditBox: cursor movement, character
 // insertion and deleteion, text selection, copy/cut/paste, etc.
 type EditBox struct {
 	screenBox
-	flags EditBoxFlags
+	flags     EditBoxFlags
+	maxLength int               // maximum characters allowed, or 0
+	filter    InputFilter       // filter applied to user input
+	onSubmit  func(text string) // called when Enter pressed in single-line mode
+	overwrite bool              // typed characters replace existing ones
 }
 
 // NewEditBox creates a new EditBox control with the specified screen
@@ -34,13 +55,211 @@ func NewEditBox(x, y, width, height int, flags EditBoxFlags) *EditBox {
 	return e
 }
 
+// Flags returns the settings of the edit box.
+func (e *EditBox) Flags() EditBoxFlags {
+	return e.flags
+}
+
+// SetFlags changes the settings of the edit box.
+func (e *EditBox) SetFlags(flags EditBoxFlags) {
+	e.flags = flags
+}
+
+// MaxLength returns the maximum number of characters, including newlines,
+// the user may enter into the edit box. A value of 0 means there is no
+// limit.
+func (e *EditBox) MaxLength() int {
+	return e.maxLength
+}
+
+// SetMaxLength sets the maximum number of characters, including newlines,
+// the user may enter into the edit box. Typed characters are rejected and
+// pasted text is truncated once the limit is reached. A value of 0 removes
+// the limit.
+func (e *EditBox) SetMaxLength(n int) {
+	e.maxLength = max(n, 0)
+}
+
+// SetInputFilter installs a filter that is applied to every character the
+// user types or pastes. Passing nil removes the filter.
+func (e *EditBox) SetInputFilter(f InputFilter) {
+	e.filter = f
+}
+
+// OnSubmit sets the function called with the contents of the edit box when
+// the user presses Enter in single-line mode.
+func (e *EditBox) OnSubmit(f func(text string)) {
+	e.onSubmit = f
+}
+
+// Overwrite returns true if the edit box is in overwrite mode, where typed
+// characters replace the characters under the cursor instead of being
+// inserted.
+func (e *EditBox) Overwrite() bool {
+	return e.overwrite
+}
+
+// SetOverwrite switches the edit box between insert and overwrite mode. The
+// user toggles the mode with the Insert key.
+func (e *EditBox) SetOverwrite(overwrite bool) {
+	e.overwrite = overwrite
+}
+
+// SetText replaces the contents of the edit box, moves the cursor to the
+// start of the text and clears the modified flag. Tabs are expanded to
+// spaces up to the next tab stop, carriage returns ending lines are
+// removed and other control characters are dropped. A single-line edit box
+// replaces newlines with spaces, and text beyond the maximum length is
+// discarded.
+func (e *EditBox) SetText(s string) {
+	s = expandTabs(strings.ReplaceAll(s, "\r\n", "\n"))
+	if (e.flags & EditBoxSingleLine) != 0 {
+		s = sanitizeLine(s)
+	}
+	lines := strings.Split(s, "\n")
+	room := maxValue
+	if e.maxLength > 0 {
+		room = e.maxLength
+	}
+
+	e.ClearCursors()
+	e.rows = make([]row, 0, len(lines))
+	for i, line := range lines {
+		r := newRow(len(line) + 1)
+		for _, ch := range line {
+			if ch >= charSpace && room > 0 {
+				r.cells = append(r.cells, tb.Cell{Ch: ch})
+				room--
+			}
+		}
+		last := i == len(lines)-1 || room == 0
+		if !last {
+			r.cells = append(r.cells, emptyCell)
+			room--
+		}
+		e.rows = append(e.rows, r)
+		if last {
+			break
+		}
+	}
+	e.modifiers = 0
+	e.selecting = false
+	e.lastCmd = cmdNone
+	e.cursor, e.lastX = coord{0, 0}, 0
+	e.SetView(0, 0)
+	e.updateDirtyRect(rect{0, 0, maxValue, maxValue})
+	e.modified = false
+}
+
+// LoadFile replaces the contents of the edit box with the contents of the
+// named file of fsys, as SetText does. Because tabs are expanded and control
+// characters dropped, saving the file again does not always reproduce it
+// exactly.
+func (e *EditBox) LoadFile(fsys fs.FS, name string) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	e.SetText(string(data))
+	return nil
+}
+
+// SaveFile writes the contents of the edit box to the named file of fsys
+// and clears the modified flag. The file holds the text as displayed, with
+// lines separated by newlines; tabs expanded by LoadFile are not restored.
+func (e *EditBox) SaveFile(fsys WriteFileFS, name string) error {
+	if err := fsys.WriteFile(name, []byte(e.Contents()), 0666); err != nil {
+		return err
+	}
+	e.modified = false
+	return nil
+}
+
+// ShowOpenDialog displays a dialog for choosing a file of fsys, starting in
+// the directory dir, and loads the chosen file into the edit box. The
+// function done, if not nil, is called with the file's name and the
+// result of loading it; it is not called if the dialog is cancelled.
+func (e *EditBox) ShowOpenDialog(fsys fs.FS, dir string, done func(name string, err error)) *FileDialog {
+	d := NewOpenDialog(fsys, dir)
+	d.OnAccept(func(name string) {
+		err := e.LoadFile(fsys, name)
+		if done != nil {
+			done(name, err)
+		}
+	})
+	d.Show()
+	return d
+}
+
+// ShowSaveDialog displays a dialog for choosing a file name of fsys,
+// starting in the directory dir with name in the dialog's input, and saves
+// the contents of the edit box to the chosen file. The function done, if
+// not nil, is called with the file's name and the result of saving it; it
+// is not called if the dialog is cancelled.
+func (e *EditBox) ShowSaveDialog(fsys WriteFileFS, dir, name string, done func(name string, err error)) *FileDialog {
+	d := NewSaveDialog(fsys, dir, name)
+	d.OnAccept(func(name string) {
+		err := e.SaveFile(fsys, name)
+		if done != nil {
+			done(name, err)
+		}
+	})
+	d.Show()
+	return d
+}
+
 // getCursor returns the absolute screen position of the cursor.
 func (e *EditBox) getCursor() (x, y int, show bool) {
 	return e.screenBox.getCursor()
 }
 
-func (e *EditBox) onDraw() {
-	e.Draw()
+func (e *EditBox) onDraw(cv *Canvas) {
+	e.draw(cv)
+}
+
+// onMouse opens the edit box's context menu when the right mouse button is
+// clicked.
+func (e *EditBox) onMouse(ev tb.Event) error {
+	if ev.Key == tb.MouseRight && (ev.Mod&tb.ModMotion) == 0 {
+		e.contextMenu().Show(ev.MouseX, ev.MouseY)
+		return nil
+	}
+	return e.screenBox.onMouse(ev)
+}
+
+// contextMenu returns a menu of clipboard commands that apply to the edit
+// box's current state.
+func (e *EditBox) contextMenu() *Menu {
+	return NewMenu("",
+		&MenuItem{
+			Label:    "Cu&t",
+			Shortcut: "Ctrl+X",
+			Action:   e.CutToClipboard,
+			Disabled: !e.editable() || !e.selecting,
+		},
+		&MenuItem{
+			Label:    "&Copy",
+			Shortcut: "Ctrl+C",
+			Action:   e.CopyToClipboard,
+			Disabled: !e.selecting,
+		},
+		&MenuItem{
+			Label:    "&Paste",
+			Shortcut: "Ctrl+V",
+			Action:   e.pasteInput,
+			Disabled: !e.editable(),
+		},
+		MenuSeparator(),
+		&MenuItem{
+			Label:  "Select &All",
+			Action: e.SelectAll,
+		},
+	)
+}
+
+// claimsKey claims the Alt+y and Alt+n commands from menu accelerators.
+func (e *EditBox) claimsKey(ev tb.Event) bool {
+	return (ev.Mod&tb.ModAlt) != 0 && (ev.Ch == 'y' || ev.Ch == 'n')
 }
 
 func (e *EditBox) onKey(ev tb.Event) error {
@@ -63,9 +282,21 @@ func (e *EditBox) onKey(ev tb.Event) error {
 		}
 	case tb.KeyCtrlF:
 		e.CursorRight()
-	case tb.KeyArrowUp, tb.KeyCtrlP:
+	case tb.KeyArrowUp:
+		if (ev.Mod & (tb.ModCtrl | tb.ModAlt)) == tb.ModCtrl|tb.ModAlt {
+			e.AddCursorAbove()
+		} else {
+			e.CursorUp()
+		}
+	case tb.KeyCtrlP:
 		e.CursorUp()
-	case tb.KeyArrowDown, tb.KeyCtrlN:
+	case tb.KeyArrowDown:
+		if (ev.Mod & (tb.ModCtrl | tb.ModAlt)) == tb.ModCtrl|tb.ModAlt {
+			e.AddCursorBelow()
+		} else {
+			e.CursorDown()
+		}
+	case tb.KeyCtrlN:
 		e.CursorDown()
 	case tb.KeyHome:
 		if (ev.Mod & tb.ModCtrl) != 0 {
@@ -88,27 +319,145 @@ func (e *EditBox) onKey(ev tb.Event) error {
 	case tb.KeyPgup:
 		e.CursorPageUp()
 	case tb.KeyDelete, tb.KeyCtrlD:
-		e.DeleteChar()
+		if e.editable() {
+			e.DeleteChar()
+		}
 	case tb.KeyBackspace, tb.KeyBackspace2:
-		e.DeleteCharLeft()
+		if e.editable() {
+			e.DeleteCharLeft()
+		}
 	case tb.KeyCtrlC:
 		e.CopyToClipboard()
-	case tb.KeyCtrlV:
-		e.PasteFromClipboard()
+	case tb.KeyCtrlV, tb.KeyCtrlY:
+		e.pasteInput()
 	case tb.KeyCtrlX:
-		e.CutToClipboard()
+		if e.editable() {
+			e.CutToClipboard()
+		}
+	case tb.KeyCtrlK:
+		if e.editable() {
+			e.KillToEndOfLine()
+		}
+	case tb.KeyInsert:
+		e.overwrite = !e.overwrite
+	case tb.KeyEsc:
+		e.ClearCursors()
 	case tb.KeySpace:
-		e.InsertChar(charSpace)
+		e.insertInput(charSpace)
 	case tb.KeyEnter:
-		e.InsertChar(charNewline)
+		if (e.flags & EditBoxSingleLine) != 0 {
+			if e.onSubmit != nil {
+				e.onSubmit(e.Contents())
+			}
+		} else {
+			e.insertInput(charNewline)
+		}
 	default:
 		if ev.Ch == '`' {
 			return errors.New("exit")
 		}
+		if ev.Ch == 'y' && (ev.Mod&tb.ModAlt) != 0 {
+			if e.editable() {
+				e.YankPop()
+			}
+			break
+		}
+		if ev.Ch == 'n' && (ev.Mod&tb.ModAlt) != 0 {
+			e.AddCursorAtNextMatch()
+			break
+		}
 		if ev.Ch != 0 {
-			e.InsertChar(ev.Ch)
+			e.insertInput(ev.Ch)
 		}
 	}
 
 	return nil
 }
+
+// editable returns true if the user is allowed to modify the contents of
+// the edit box.
+func (e *EditBox) editable() bool {
+	return (e.flags & EditBoxReadOnly) == 0
+}
+
+// insertInput inserts a character typed by the user, applying the input
+// filter and length limit.
+func (e *EditBox) insertInput(ch rune) {
+	if !e.editable() {
+		return
+	}
+
+	ch, ok := e.filterChar(ch)
+	if !ok {
+		return
+	}
+
+	// Replacing a character doesn't count against the length limit.
+	replacing := e.overwrite && !e.selecting && ch != charNewline &&
+		e.cursor.x < e.rowLen(e.cursor.y)
+	if !replacing && e.room() < e.CursorCount() {
+		return
+	}
+
+	if e.overwrite {
+		e.OverwriteChar(ch)
+	} else {
+		e.InsertChar(ch)
+	}
+}
+
+// pasteInput pastes the clipboard contents at the user's request, applying
+// the input filter and length limit.
+func (e *EditBox) pasteInput() {
+	if !e.editable() {
+		return
+	}
+
+	s := clipboardPaste()
+	if e.filter == nil && e.maxLength == 0 && (e.flags&EditBoxSingleLine) == 0 {
+		e.paste(s)
+		return
+	}
+
+	room := e.room() / e.CursorCount()
+	var buf strings.Builder
+	for _, ch := range s {
+		if room <= 0 {
+			break
+		}
+		if ch, ok := e.filterChar(ch); ok {
+			buf.WriteRune(ch)
+			room--
+		}
+	}
+	e.paste(buf.String())
+}
+
+// filterChar applies single-line restrictions and the input filter to a
+// character entered by the user. A single-line edit box turns newlines
+// into spaces and drops other control characters, as TextInput does.
+func (e *EditBox) filterChar(ch rune) (rune, bool) {
+	if (e.flags & EditBoxSingleLine) != 0 {
+		switch {
+		case ch == charNewline:
+			ch = charSpace
+		case ch < 32:
+			return 0, false
+		}
+	}
+	if e.filter != nil {
+		return e.filter(ch)
+	}
+	return ch, true
+}
+
+// room returns the number of characters that may still be entered by the
+// user before reaching the length limit, counting the selected text as
+// available space.
+func (e *EditBox) room() int {
+	if e.maxLength == 0 {
+		return maxValue
+	}
+	n := e.textLen() - len([]rune(e.Selection()))
+	return max(e.maxLength-n, 0)
+}
@@ -0,0 +1,36 @@
+package termwin
+
+import "testing"
+
+func TestEditBoxSetText(t *testing.T) {
+	tests := []struct {
+		flags     EditBoxFlags
+		maxLength int
+		in, want  string
+	}{
+		{0, 0, "a\tb", "a       b"},
+		{0, 0, "abcdefgh\tb\n\tc", "abcdefgh        b\n        c"},
+		{0, 0, "ab\x01\tc", "ab      c"},
+		{0, 0, "one\r\ntwo\r\n", "one\ntwo\n"},
+		{EditBoxSingleLine, 0, "one\r\ntwo\tx", "one two     x"},
+		{0, 5, "ab\ncdef", "ab\ncd"},
+		{0, 3, "ab\ncd", "ab\n"},
+		{0, 2, "ab\ncd", "ab"},
+		{EditBoxSingleLine, 4, "ab\ncd", "ab c"},
+	}
+
+	for i, test := range tests {
+		e := &EditBox{
+			screenBox: newScreenBox(0, 0, 20, 5),
+			flags:     test.flags,
+			maxLength: test.maxLength,
+		}
+		e.SetText(test.in)
+		if got := e.Contents(); got != test.want {
+			t.Errorf("%d: got %q, want %q", i, got, test.want)
+		}
+		if e.Modified() {
+			t.Errorf("%d: SetText set the modified flag", i)
+		}
+	}
+}
@@ -0,0 +1,733 @@
+package termwin
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+const (
+	// fileNamePrompt is the label displayed before a file dialog's input.
+	fileNamePrompt = "Name: "
+
+	// fileTimeLayout is the format of modification times in a file dialog.
+	fileTimeLayout = "2006-01-02 15:04"
+
+	// fileInfoMinWidth is the narrowest list in which a file dialog shows
+	// the sizes and modification times of files.
+	fileInfoMinWidth = 48
+)
+
+// A WriteFileFS is a file system that can write files as well as read
+// them, so that files chosen in a save dialog can be written.
+type WriteFileFS interface {
+	fs.FS
+
+	// WriteFile writes data to the named file, creating it with the
+	// permissions perm if it does not exist, or truncating it otherwise.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// DirFS returns a WriteFileFS for the tree of operating system files
+// rooted at dir, like os.DirFS.
+func DirFS(dir string) WriteFileFS {
+	return dirFS{os.DirFS(dir), dir}
+}
+
+// A dirFS is a WriteFileFS for a directory of the operating system.
+type dirFS struct {
+	fs.FS
+	dir string // root directory
+}
+
+// WriteFile writes the named file within the root directory.
+func (d dirFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	return os.WriteFile(filepath.Join(d.dir, filepath.FromSlash(name)), data, perm)
+}
+
+// A FileDialogMode determines whether a FileDialog chooses an existing
+// file to open or a name to save a file under.
+type FileDialogMode byte
+
+const (
+	// FileDialogOpen chooses an existing file.
+	FileDialogOpen FileDialogMode = iota
+
+	// FileDialogSave chooses a file name in an existing directory, asking
+	// for confirmation before accepting an existing file.
+	FileDialogSave
+)
+
+// A FileSort determines the order of the files listed by a FileDialog.
+// Directories are always listed before files.
+type FileSort byte
+
+const (
+	// FileSortName sorts files by name, ignoring case.
+	FileSortName FileSort = iota
+
+	// FileSortSize sorts files by size, largest first.
+	FileSortSize
+
+	// FileSortTime sorts files by modification time, newest first.
+	FileSortTime
+)
+
+// fileSortNames are the names of the sort orders shown in the status line.
+var fileSortNames = [...]string{"name", "size", "date"}
+
+// A fileEntry is a directory entry listed by a FileDialog.
+type fileEntry struct {
+	name    string    // name of the entry within its directory
+	dir     bool      // entry is a directory, or a link to one
+	size    int64     // size of a file in bytes
+	modTime time.Time // modification time
+}
+
+// A FileDialog is a modal window for choosing a file to open or save. It
+// lists the entries of a directory of a file system, with a text input for
+// typing a file name or path. Paths are displayed as absolute paths
+// within the file system, and the chosen file is reported as a name valid
+// for the file system's Open method, such as "src/main.go".
+//
+// Pressing Enter in the input opens a directory, chooses a file, or sets
+// the filter if the input contains glob patterns such as "*.go *.md".
+// Pressing Enter with an empty input chooses the entry under the cursor.
+// Tab completes the name in the input. Other keys are:
+//
+//	Up Down, PgUp PgDn      move the cursor, copying the entry's name to
+//	                        the input
+//	Backspace               go to the parent directory when the input is
+//	                        empty
+//	F2                      cycle the sort order through name, size and date
+//	F3                      show or hide hidden files
+//	Esc                     cancel
+type FileDialog struct {
+	corner   coord             // screen coordinate of top-left corner
+	size     coord             // screen dimensions of the dialog
+	mode     FileDialogMode    // open or save
+	title    string            // text displayed in the top border
+	fsys     fs.FS             // file system browsed
+	dir      string            // directory listed, as a name in fsys
+	input    *TextInput        // file name input
+	entries  []fileEntry       // listed entries of the directory
+	current  int               // index of the entry under the cursor
+	top      int               // index of the first visible entry
+	sortBy   FileSort          // order of listed files
+	hidden   bool              // hidden files are listed
+	filter   []string          // glob patterns of listed files, or nil
+	err      string            // error displayed in the status line
+	clicked  time.Time         // time of the last mouse click
+	dirty    bool              // dialog needs to be redrawn
+	onAccept func(name string) // called when a file is chosen
+	onCancel func()            // called when the dialog is cancelled
+}
+
+// NewOpenDialog creates a new dialog for choosing an existing file of fsys,
+// starting in the directory dir. The dialog is not displayed until Show is
+// called.
+func NewOpenDialog(fsys fs.FS, dir string) *FileDialog {
+	return newFileDialog(FileDialogOpen, "Open File", fsys, dir)
+}
+
+// NewSaveDialog creates a new dialog for choosing a file name of fsys to
+// save a file under, starting in the directory dir with name in the input.
+// The dialog is not displayed until Show is called.
+func NewSaveDialog(fsys fs.FS, dir, name string) *FileDialog {
+	d := newFileDialog(FileDialogSave, "Save File", fsys, dir)
+	d.input.SetText(name)
+	return d
+}
+
+func newFileDialog(mode FileDialogMode, title string, fsys fs.FS, dir string) *FileDialog {
+	d := &FileDialog{
+		mode:  mode,
+		title: title,
+		fsys:  fsys,
+		input: newTextInput(0, 0, 1),
+		dirty: true,
+	}
+	d.dir = d.resolve(dir)
+	d.input.SetCompleter(CompleterFunc(d.complete), CompletionInline)
+	return d
+}
+
+// Show centers the dialog on the screen, lists its directory and displays
+// it modally.
+func (d *FileDialog) Show() {
+	sw, sh := tb.Size()
+	width := min(max(sw*3/4, 50), sw)
+	height := min(max(sh*3/4, 12), sh)
+	d.corner = coord{(sw - width) / 2, (sh - height) / 2}
+	d.resize(width, height)
+	d.refresh()
+	ShowModal(d)
+}
+
+// OnAccept sets the function called with the name of the chosen file when
+// the user accepts it.
+func (d *FileDialog) OnAccept(f func(name string)) {
+	d.onAccept = f
+}
+
+// OnCancel sets the function called when the user cancels the dialog.
+func (d *FileDialog) OnCancel(f func()) {
+	d.onCancel = f
+}
+
+// Dir returns the name of the directory listed.
+func (d *FileDialog) Dir() string {
+	return d.dir
+}
+
+// SetDir lists the directory dir, which is a name in the file system or
+// an absolute path within it. If the directory cannot be read, the
+// dialog keeps its current directory and the error is returned.
+func (d *FileDialog) SetDir(dir string) error {
+	return d.chdir(d.resolve(dir))
+}
+
+// Filter returns the glob patterns of the files listed, separated by
+// spaces.
+func (d *FileDialog) Filter() string {
+	return strings.Join(d.filter, " ")
+}
+
+// SetFilter lists only the files whose names match one of the
+// space-separated glob patterns, in the syntax of path.Match. An empty
+// string lists all files. Directories are always listed.
+func (d *FileDialog) SetFilter(patterns string) error {
+	filter := strings.Fields(patterns)
+	for _, p := range filter {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("%w: %s", err, p)
+		}
+	}
+	d.filter = filter
+	d.refresh()
+	return nil
+}
+
+// SetSort sets the order of the files listed.
+func (d *FileDialog) SetSort(s FileSort) {
+	d.sortBy = s
+	d.refresh()
+}
+
+// ShowHidden lists or hides the hidden files and directories, whose names
+// begin with a dot.
+func (d *FileDialog) ShowHidden(on bool) {
+	d.hidden = on
+	d.refresh()
+}
+
+// accept closes the dialog and reports the chosen file.
+func (d *FileDialog) accept(name string) {
+	EndModal(d)
+	if d.onAccept != nil {
+		d.onAccept(name)
+	}
+}
+
+// cancel closes the dialog and reports the cancellation.
+func (d *FileDialog) cancel() {
+	EndModal(d)
+	if d.onCancel != nil {
+		d.onCancel()
+	}
+}
+
+// submit acts on text entered in the input, or on the entry under the
+// cursor if the text is empty.
+func (d *FileDialog) submit(s string) {
+	if s == "" {
+		if d.current >= len(d.entries) {
+			return
+		}
+		e := d.entries[d.current]
+		s = e.name
+		if e.dir {
+			s += "/"
+		}
+	}
+
+	dir, base := path.Split(s)
+	if strings.ContainsAny(base, "*?[") {
+		if dir != "" {
+			if err := d.SetDir(dir); err != nil {
+				return
+			}
+		}
+		if err := d.SetFilter(base); err != nil {
+			d.setError(err.Error())
+			return
+		}
+		d.input.SetText("")
+		return
+	}
+
+	name := d.resolve(s)
+	info, err := fs.Stat(d.fsys, name)
+	switch {
+	case err == nil && info.IsDir():
+		d.chdir(name)
+	case err == nil && d.mode == FileDialogOpen:
+		d.accept(name)
+	case err == nil:
+		d.confirmOverwrite(name)
+	case !errors.Is(err, fs.ErrNotExist):
+		d.setError(err.Error())
+	case d.mode == FileDialogOpen:
+		d.setError("no such file: " + displayPath(name))
+	default:
+		if info, err := fs.Stat(d.fsys, path.Dir(name)); err != nil || !info.IsDir() {
+			d.setError("no such directory: " + displayPath(path.Dir(name)))
+			return
+		}
+		d.accept(name)
+	}
+}
+
+// confirmOverwrite asks the user whether to replace an existing file, and
+// accepts it if they agree.
+func (d *FileDialog) confirmOverwrite(name string) {
+	msg := fmt.Sprintf("%s already exists.\nDo you want to replace it?", path.Base(name))
+	c := NewDialog("Confirm Save", msg, "Replace", "Cancel")
+	c.OnClose(func(button int) {
+		if button == 0 {
+			d.accept(name)
+		}
+	})
+	c.Show()
+}
+
+// resolve returns the name in the file system of a path, which is either
+// absolute within the file system or relative to the listed directory.
+// Parent directories above the root resolve to the root.
+func (d *FileDialog) resolve(p string) string {
+	if !strings.HasPrefix(p, "/") {
+		p = path.Join(d.dir, p)
+	}
+	p = path.Clean("/" + p)[1:]
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+// displayPath returns the absolute path displayed for a name in a file
+// system.
+func displayPath(name string) string {
+	if name == "." {
+		return "/"
+	}
+	return "/" + name
+}
+
+// chdir lists the directory with a name in the file system, clearing the
+// input. If the directory cannot be read, the error is displayed and
+// returned.
+func (d *FileDialog) chdir(dir string) error {
+	entries, err := d.list(dir)
+	if err != nil {
+		d.setError(err.Error())
+		return err
+	}
+	d.dir, d.entries = dir, entries
+	d.current, d.top, d.err = 0, 0, ""
+	d.input.SetText("")
+	d.dirty = true
+	return nil
+}
+
+// refresh lists the directory again, keeping the cursor on the same entry
+// if it is still listed.
+func (d *FileDialog) refresh() {
+	entries, err := d.list(d.dir)
+	if err != nil {
+		d.setError(err.Error())
+		return
+	}
+	name := ""
+	if d.current < len(d.entries) {
+		name = d.entries[d.current].name
+	}
+	d.entries, d.current = entries, 0
+	for i, e := range entries {
+		if e.name == name {
+			d.current = i
+			break
+		}
+	}
+	d.scrollTo(d.current)
+}
+
+// list returns the entries of a directory that pass the hidden-file
+// setting and the filter, in the sort order, preceded by the parent
+// directory if dir is not the root.
+func (d *FileDialog) list(dir string) ([]fileEntry, error) {
+	des, err := fs.ReadDir(d.fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []fileEntry
+	if dir != "." {
+		entries = append(entries, fileEntry{name: "..", dir: true})
+	}
+	for _, de := range des {
+		e := fileEntry{name: de.Name(), dir: de.IsDir()}
+		if !d.hidden && strings.HasPrefix(e.name, ".") {
+			continue
+		}
+		info, err := de.Info()
+		if de.Type()&fs.ModeSymlink != 0 {
+			info, err = fs.Stat(d.fsys, path.Join(dir, e.name))
+		}
+		if err == nil {
+			e.dir = info.IsDir()
+			e.size, e.modTime = info.Size(), info.ModTime()
+		}
+		if !e.dir && !d.matchFilter(e.name) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return d.less(&entries[i], &entries[j])
+	})
+	return entries, nil
+}
+
+// matchFilter returns true if a file name matches the filter.
+func (d *FileDialog) matchFilter(name string) bool {
+	if len(d.filter) == 0 {
+		return true
+	}
+	for _, p := range d.filter {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// less returns true if entry a is listed before entry b: the parent
+// directory first, then directories by name, or by date when sorting by
+// date, then files in the sort order.
+func (d *FileDialog) less(a, b *fileEntry) bool {
+	switch {
+	case a.name == ".." || b.name == "..":
+		return a.name == ".."
+	case a.dir != b.dir:
+		return a.dir
+	case d.sortBy == FileSortSize && !a.dir && a.size != b.size:
+		return a.size > b.size
+	case d.sortBy == FileSortTime && !a.modTime.Equal(b.modTime):
+		return a.modTime.After(b.modTime)
+	}
+	la, lb := strings.ToLower(a.name), strings.ToLower(b.name)
+	if la != lb {
+		return la < lb
+	}
+	return a.name < b.name
+}
+
+// complete returns the completion candidates for the name being typed at
+// the cursor: the entries of its directory that begin with it.
+// Directories end with a slash, so completion can continue inside them.
+func (d *FileDialog) complete(text string, pos int) (start int, candidates []string) {
+	prefix := string([]rune(text)[:pos])
+	i := strings.LastIndex(prefix, "/") + 1
+	dir, part := d.dir, prefix[i:]
+	if i > 0 {
+		dir = d.resolve(prefix[:i])
+	}
+
+	des, err := fs.ReadDir(d.fsys, dir)
+	if err != nil {
+		return 0, nil
+	}
+	for _, de := range des {
+		name := de.Name()
+		if !strings.HasPrefix(name, part) {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !d.hidden && !strings.HasPrefix(part, ".") {
+			continue
+		}
+		if de.IsDir() {
+			name += "/"
+		}
+		candidates = append(candidates, name)
+	}
+	return utf8.RuneCountInString(prefix[:i]), candidates
+}
+
+// seek moves the cursor to the first entry beginning with the name typed
+// in the input, ignoring case.
+func (d *FileDialog) seek(s string) {
+	if s == "" || strings.Contains(s, "/") {
+		return
+	}
+	s = strings.ToLower(s)
+	for i, e := range d.entries {
+		if strings.HasPrefix(strings.ToLower(e.name), s) {
+			d.scrollTo(i)
+			return
+		}
+	}
+}
+
+// moveTo moves the cursor to an entry and copies its name to the input.
+func (d *FileDialog) moveTo(i int) {
+	d.scrollTo(i)
+	if d.current < len(d.entries) {
+		e := d.entries[d.current]
+		name := e.name
+		if e.dir {
+			name += "/"
+		}
+		d.input.SetText(name)
+	}
+}
+
+// scrollTo moves the cursor to an entry, scrolling the list if necessary.
+func (d *FileDialog) scrollTo(i int) {
+	d.current = min(max(i, 0), max(len(d.entries)-1, 0))
+	n := d.visibleRows()
+	switch {
+	case d.current < d.top:
+		d.top = d.current
+	case d.current >= d.top+n:
+		d.top = d.current - n + 1
+	}
+	d.top = max(min(d.top, len(d.entries)-n), 0)
+	d.dirty = true
+}
+
+// setError displays an error message in the status line until the next
+// key is pressed.
+func (d *FileDialog) setError(msg string) {
+	d.err = msg
+	d.dirty = true
+}
+
+// visibleRows returns the number of rows listing entries, between the
+// input and the status line.
+func (d *FileDialog) visibleRows() int {
+	return max(d.size.y-7, 1)
+}
+
+func (d *FileDialog) focusedChild() Window {
+	return d.input
+}
+
+func (d *FileDialog) bounds() rect {
+	return newRect(d.corner.x, d.corner.y, d.size.x, d.size.y)
+}
+
+func (d *FileDialog) dirtyRect() rect {
+	if d.dirty {
+		return d.bounds()
+	}
+	return d.input.dirtyRect()
+}
+
+func (d *FileDialog) invalidate() {
+	d.dirty = true
+	d.input.invalidate()
+}
+
+func (d *FileDialog) move(x, y int) {
+	damage(d.bounds())
+	d.corner = coord{x, y}
+	d.input.move(x+2+len(fileNamePrompt), y+2)
+	d.dirty = true
+}
+
+func (d *FileDialog) resize(width, height int) {
+	damage(d.bounds())
+	d.size = coord{width, height}
+	d.input.move(d.corner.x+2+len(fileNamePrompt), d.corner.y+2)
+	d.input.resize(max(width-4-len(fileNamePrompt), 0), 1)
+	d.scrollTo(d.current)
+}
+
+func (d *FileDialog) getCursor() (x, y int, show bool) {
+	return d.input.getCursor()
+}
+
+func (d *FileDialog) onKey(ev tb.Event) error {
+	if d.err != "" {
+		d.err = ""
+		d.dirty = true
+	}
+	if d.input.completing && (ev.Key == tb.KeyEnter || ev.Key == tb.KeyEsc) {
+		return d.input.onKey(ev)
+	}
+
+	page := d.visibleRows()
+	switch {
+	case ev.Key == tb.KeyArrowUp:
+		d.moveTo(d.current - 1)
+	case ev.Key == tb.KeyArrowDown:
+		d.moveTo(d.current + 1)
+	case ev.Key == tb.KeyPgup:
+		d.moveTo(d.current - page)
+	case ev.Key == tb.KeyPgdn:
+		d.moveTo(d.current + page)
+	case ev.Key == tb.KeyEnter:
+		d.submit(d.input.Text())
+	case ev.Key == tb.KeyEsc:
+		d.cancel()
+	case ev.Key == tb.KeyF2:
+		d.SetSort((d.sortBy + 1) % FileSort(len(fileSortNames)))
+	case ev.Key == tb.KeyF3:
+		d.ShowHidden(!d.hidden)
+	case (ev.Key == tb.KeyBackspace || ev.Key == tb.KeyBackspace2) && d.input.Text() == "":
+		d.chdir(path.Dir(d.dir))
+	default:
+		text := d.input.Text()
+		err := d.input.onKey(ev)
+		if s := d.input.Text(); s != text {
+			d.seek(s)
+		}
+		return err
+	}
+	return nil
+}
+
+func (d *FileDialog) onMouse(ev tb.Event) error {
+	switch ev.Key {
+	case tb.MouseWheelUp:
+		d.scrollTo(d.current - mouseWheelRows)
+	case tb.MouseWheelDown:
+		d.scrollTo(d.current + mouseWheelRows)
+	case tb.MouseLeft:
+		if contains(d.input.bounds(), ev.MouseX, ev.MouseY) {
+			return d.input.onMouse(ev)
+		}
+		i := d.top + ev.MouseY - (d.corner.y + 4)
+		if i < d.top || i >= min(len(d.entries), d.top+d.visibleRows()) {
+			return nil
+		}
+		d.moveTo(i)
+		if (ev.Mod & tb.ModMotion) != 0 {
+			return nil
+		}
+		now := time.Now()
+		double := now.Sub(d.clicked) < doubleClickTime
+		d.clicked = now
+		if double {
+			d.submit("")
+		}
+	}
+	return nil
+}
+
+func (d *FileDialog) onDraw(cv *Canvas) {
+	if d.dirty {
+		d.dirty = false
+		d.draw(cv)
+		d.input.invalidate()
+	}
+	d.input.onDraw(cv.sub(d.input.bounds()))
+}
+
+// draw draws the dialog's frame, directory, entries and status line.
+func (d *FileDialog) draw(cv *Canvas) {
+	fg, bg := theme.DialogFg, theme.DialogBg
+	w, h := d.size.x, d.size.y
+	cv.Clear(fg, bg)
+	cv.Box(0, 0, w, h, fg, bg)
+	title := " " + d.title + " "
+	cv.DrawText(max((w-textWidth(title))/2, 1), 0, w-2, title, fg|tb.AttrBold, bg)
+
+	dir := displayPath(d.dir)
+	if over := textWidth(dir) - (w - 4); over > 0 {
+		r := []rune(dir)
+		dir = string(charEllipsis) + string(r[min(over+1, len(r)):])
+	}
+	cv.DrawText(2, 1, w-4, dir, fg|tb.AttrBold, bg)
+	cv.DrawText(2, 2, w-4, fileNamePrompt, fg, bg)
+	cv.HLine(0, 3, w, fg, bg)
+
+	for y := 0; y < d.visibleRows() && d.top+y < len(d.entries); y++ {
+		d.drawEntry(cv, 4+y, d.top+y)
+	}
+
+	cv.HLine(0, h-3, w, fg, bg)
+	if d.err != "" {
+		cv.DrawText(2, h-2, w-4, d.err, theme.ErrorFg, bg)
+		return
+	}
+	hidden := "off"
+	if d.hidden {
+		hidden = "on"
+	}
+	status := fmt.Sprintf("F2 sort: %s  F3 hidden: %s", fileSortNames[d.sortBy], hidden)
+	if len(d.filter) > 0 {
+		status += "  filter: " + d.Filter()
+	}
+	cv.DrawText(2, h-2, w-4, status, fg, bg)
+}
+
+// drawEntry draws a listed entry, with the size and modification time of
+// files if the list is wide enough.
+func (d *FileDialog) drawEntry(cv *Canvas, y, i int) {
+	fg, bg := theme.DialogFg, theme.DialogBg
+	if i == d.current {
+		fg, bg = theme.FocusFg, theme.FocusBg
+	}
+	width := d.size.x - 2
+	cv.Fill(1, y, width, 1, charSpace, fg, bg)
+
+	e := d.entries[i]
+	name := e.name
+	if e.dir {
+		name += "/"
+		fg |= tb.AttrBold
+	}
+	info := ""
+	if width >= fileInfoMinWidth && e.name != ".." {
+		size, mod := "", ""
+		if !e.dir {
+			size = formatSize(e.size)
+		}
+		if !e.modTime.IsZero() {
+			mod = e.modTime.Format(fileTimeLayout)
+		}
+		info = fmt.Sprintf("%7s  %-16s", size, mod)
+		cv.DrawText(width-len(info), y, len(info), info, fg, bg)
+	}
+	cv.DrawText(2, y, width-len(info)-3, name, fg, bg)
+}
+
+// formatSize returns a file size in bytes, or in kilobytes, megabytes and
+// so on with one decimal place.
+func formatSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d", n)
+	}
+	f := float64(n) / 1024
+	unit := 0
+	for f >= 1024 && unit < 4 {
+		f /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f%c", f, "KMGTP"[unit])
+}
@@ -0,0 +1,200 @@
+package termwin
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// A memFS is an in-memory WriteFileFS.
+type memFS struct {
+	fstest.MapFS
+}
+
+func (m memFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	m.MapFS[name] = &fstest.MapFile{
+		Data:    append([]byte(nil), data...),
+		Mode:    perm,
+		ModTime: time.Now(),
+	}
+	return nil
+}
+
+func newTestFS() memFS {
+	t0 := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	return memFS{fstest.MapFS{
+		"notes.txt":       {Data: []byte("notes"), ModTime: t0},
+		"src/a.go":        {Data: make([]byte, 10), ModTime: t0.Add(3 * time.Hour)},
+		"src/B.go":        {Data: make([]byte, 300), ModTime: t0.Add(2 * time.Hour)},
+		"src/c.md":        {Data: make([]byte, 50), ModTime: t0.Add(1 * time.Hour)},
+		"src/.hidden":     {Data: []byte("h"), ModTime: t0},
+		"src/.git/config": {Data: []byte("g"), ModTime: t0},
+		"src/sub/x.txt":   {Data: []byte("x"), ModTime: t0},
+	}}
+}
+
+func TestFileDialogList(t *testing.T) {
+	tests := []struct {
+		dir    string
+		sortBy FileSort
+		hidden bool
+		filter string
+		want   []string
+	}{
+		{"src", FileSortName, false, "", []string{"..", "sub", "a.go", "B.go", "c.md"}},
+		{"src", FileSortSize, false, "", []string{"..", "sub", "B.go", "c.md", "a.go"}},
+		{"src", FileSortTime, false, "", []string{"..", "sub", "a.go", "B.go", "c.md"}},
+		{"src", FileSortName, true, "", []string{"..", ".git", "sub", ".hidden", "a.go", "B.go", "c.md"}},
+		{"src", FileSortName, false, "*.go", []string{"..", "sub", "a.go", "B.go"}},
+		{"src", FileSortName, false, "*.md *.txt", []string{"..", "sub", "c.md"}},
+		{".", FileSortName, false, "", []string{"src", "notes.txt"}},
+	}
+
+	for i, test := range tests {
+		d := NewOpenDialog(newTestFS(), test.dir)
+		d.sortBy, d.hidden = test.sortBy, test.hidden
+		d.filter = strings.Fields(test.filter)
+		entries, err := d.list(d.dir)
+		if err != nil {
+			t.Errorf("%d: list: %v", i, err)
+			continue
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.name)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%d: got %q, want %q", i, got, test.want)
+		}
+	}
+}
+
+func TestFileDialogSetFilter(t *testing.T) {
+	d := NewOpenDialog(newTestFS(), "src")
+	if err := d.SetFilter("[a"); err == nil {
+		t.Error("SetFilter accepted an invalid pattern")
+	}
+	if err := d.SetFilter("*.go  *.md"); err != nil {
+		t.Errorf("SetFilter: %v", err)
+	}
+	if got := d.Filter(); got != "*.go *.md" {
+		t.Errorf("Filter = %q", got)
+	}
+}
+
+func TestFileDialogResolve(t *testing.T) {
+	d := NewOpenDialog(newTestFS(), "src/sub")
+	tests := []struct {
+		path, want string
+	}{
+		{"", "src/sub"},
+		{"x.txt", "src/sub/x.txt"},
+		{"../a.go", "src/a.go"},
+		{"/notes.txt", "notes.txt"},
+		{"/", "."},
+		{"../..", "."},
+		{"../../../..", "."},
+		{"/../../notes.txt", "notes.txt"},
+	}
+	for _, test := range tests {
+		if got := d.resolve(test.path); got != test.want {
+			t.Errorf("resolve(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestFileDialogSubmitOpen(t *testing.T) {
+	d := NewOpenDialog(newTestFS(), "src")
+	accepted := ""
+	d.OnAccept(func(name string) { accepted = name })
+
+	d.submit("missing.go")
+	if accepted != "" || !strings.Contains(d.err, "no such file") {
+		t.Errorf("missing file: accepted %q, err %q", accepted, d.err)
+	}
+
+	d.submit("sub/")
+	if d.Dir() != "src/sub" {
+		t.Errorf("Dir = %q after opening a directory", d.Dir())
+	}
+
+	d.submit("../a.go")
+	if accepted != "src/a.go" {
+		t.Errorf("accepted %q, want %q", accepted, "src/a.go")
+	}
+}
+
+func TestFileDialogSubmitSave(t *testing.T) {
+	d := NewSaveDialog(newTestFS(), "src", "")
+	accepted := ""
+	d.OnAccept(func(name string) { accepted = name })
+
+	d.submit("nodir/x.go")
+	if accepted != "" || !strings.Contains(d.err, "no such directory") {
+		t.Errorf("missing directory: accepted %q, err %q", accepted, d.err)
+	}
+
+	d.submit("new.go")
+	if accepted != "src/new.go" {
+		t.Errorf("accepted %q, want %q", accepted, "src/new.go")
+	}
+
+	accepted = ""
+	d.submit("a.go")
+	confirm, ok := topModal().(*Dialog)
+	if !ok || accepted != "" {
+		t.Fatalf("existing file: accepted %q without confirmation", accepted)
+	}
+	confirm.Close(0)
+	if accepted != "src/a.go" {
+		t.Errorf("accepted %q after confirmation, want %q", accepted, "src/a.go")
+	}
+	if topModal() != nil {
+		t.Error("confirmation dialog still displayed")
+	}
+}
+
+func TestEditBoxLoadSave(t *testing.T) {
+	fsys := newTestFS()
+	fsys.MapFS["doc.txt"] = &fstest.MapFile{Data: []byte("one\r\n\ttwo\nthree")}
+
+	e := &EditBox{screenBox: newScreenBox(0, 0, 20, 5)}
+	if err := e.LoadFile(fsys, "doc.txt"); err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	want := "one\n" + strings.Repeat(" ", tabWidth) + "two\nthree"
+	if got := e.Contents(); got != want || e.Modified() {
+		t.Errorf("loaded %q, modified %v; want %q", got, e.Modified(), want)
+	}
+
+	e.CursorEndOfBuffer()
+	e.InsertString("!\n")
+	if !e.Modified() {
+		t.Error("edit did not set the modified flag")
+	}
+	if err := e.SaveFile(fsys, "out.txt"); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	if e.Modified() {
+		t.Error("SaveFile did not clear the modified flag")
+	}
+	if got := string(fsys.MapFS["out.txt"].Data); got != want+"!\n" {
+		t.Errorf("saved %q, want %q", got, want+"!\n")
+	}
+
+	e2 := &EditBox{screenBox: newScreenBox(0, 0, 20, 5)}
+	if err := e2.LoadFile(fsys, "out.txt"); err != nil || e2.Contents() != e.Contents() {
+		t.Errorf("reloaded %q, %v; want %q", e2.Contents(), err, e.Contents())
+	}
+
+	if err := e.LoadFile(fsys, "missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LoadFile of a missing file: %v", err)
+	}
+}
@@ -0,0 +1,303 @@
+package termwin
+
+import (
+	"unicode/utf8"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+// A Validator checks the value of a form field. It returns an error
+// describing the problem if the value is invalid.
+type Validator func() error
+
+// A formRow is a row of windows in a form, with an optional label and
+// validator.
+type formRow struct {
+	label    string    // text displayed in the label column
+	ws       []Window  // windows in the row, left to right
+	validate Validator // validates the row's value, or nil
+	err      string    // message from the last failed validation
+	y        int       // row's offset from the top of the form
+}
+
+// A Form is a container that lays out controls in rows, with labels
+// aligned in a column to the left of the controls. Tab and Shift+Tab move
+// the focus between the form's controls, and all other keys are passed to
+// the control with the focus.
+type Form struct {
+	corner     coord      // screen coordinate of top-left corner
+	size       coord      // screen dimensions of the form
+	rows       []*formRow // rows of the form, top to bottom
+	labelAlign Alignment  // alignment of labels in the label column
+	focus      Window     // control with the focus, or nil
+	pressed    Window     // control receiving mouse events until release
+	dirty      bool       // labels and errors need to be redrawn
+}
+
+// NewForm creates a new Form container with the specified screen position
+// and width. The form grows downward as rows are added.
+func NewForm(x, y, width int) *Form {
+	f := &Form{
+		corner: coord{x, y},
+		size:   coord{width, 0},
+		dirty:  true,
+	}
+	addWindow(f)
+	return f
+}
+
+// AddField adds a row containing a labeled control to the bottom of the
+// form. If validate is not nil, it is called by Validate to check the
+// control's value. The control is removed from the screen's window stack
+// and is drawn and positioned by the form.
+func (f *Form) AddField(label string, w Window, validate Validator) {
+	f.addRow(&formRow{label: sanitizeLine(label), ws: []Window{w}, validate: validate})
+}
+
+// AddRow adds a row of unlabeled controls, such as a group of buttons, to
+// the bottom of the form. The controls are placed side by side in the
+// control column.
+func (f *Form) AddRow(ws ...Window) {
+	f.addRow(&formRow{ws: ws})
+}
+
+func (f *Form) addRow(r *formRow) {
+	for _, w := range r.ws {
+		RemoveWindow(w)
+		if f.focus == nil && focusable(w) {
+			f.focus = w
+		}
+	}
+	f.rows = append(f.rows, r)
+	f.layout()
+}
+
+// SetLabelAlign sets the alignment of labels within the label column.
+func (f *Form) SetLabelAlign(a Alignment) {
+	f.labelAlign = a
+	f.dirty = true
+}
+
+// SetFocus gives the focus to one of the form's controls.
+func (f *Form) SetFocus(w Window) {
+	if f.rowOf(w) != nil && focusable(w) {
+		f.focus = w
+	}
+}
+
+// Validate calls the validator of every row and displays the error
+// messages of invalid rows below them. The focus moves to the first
+// invalid control. Validate returns true if all rows are valid.
+func (f *Form) Validate() bool {
+	var first Window
+	for _, r := range f.rows {
+		r.err = ""
+		if r.validate == nil {
+			continue
+		}
+		if err := r.validate(); err != nil {
+			r.err = sanitizeLine(err.Error())
+			if first == nil {
+				first = r.ws[0]
+			}
+		}
+	}
+
+	f.layout()
+	if first != nil {
+		f.SetFocus(first)
+	}
+	return first == nil
+}
+
+// layout positions the form's controls and computes the form's height.
+func (f *Form) layout() {
+	damage(f.bounds())
+
+	x := f.corner.x + f.labelWidth()
+	y := 0
+	for _, r := range f.rows {
+		r.y = y
+		h, wx := 1, x
+		for _, w := range r.ws {
+			w.move(wx, f.corner.y+y)
+			b := w.bounds()
+			wx = b.x1 + 1
+			h = max(h, b.y1-b.y0)
+		}
+		y += h
+		if r.err != "" {
+			y++
+		}
+	}
+	f.size.y = y
+	f.dirty = true
+}
+
+// labelWidth returns the width of the label column, including the space
+// separating it from the controls.
+func (f *Form) labelWidth() int {
+	width := 0
+	for _, r := range f.rows {
+		width = max(width, utf8.RuneCountInString(r.label))
+	}
+	if width > 0 {
+		width++
+	}
+	return width
+}
+
+// rowOf returns the row containing a window, or nil.
+func (f *Form) rowOf(w Window) *formRow {
+	for _, r := range f.rows {
+		for _, ww := range r.ws {
+			if ww == w {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// windows returns the form's controls in focus order.
+func (f *Form) windows() []Window {
+	var ws []Window
+	for _, r := range f.rows {
+		ws = append(ws, r.ws...)
+	}
+	return ws
+}
+
+// moveFocus moves the focus to the next (dir > 0) or previous (dir < 0)
+// control that accepts it.
+func (f *Form) moveFocus(dir int) {
+	ws := f.windows()
+	n := len(ws)
+	i := 0
+	for j, w := range ws {
+		if w == f.focus {
+			i = j
+		}
+	}
+	for j := 1; j <= n; j++ {
+		w := ws[((i+dir*j)%n+n)%n]
+		if focusable(w) {
+			f.focus = w
+			return
+		}
+	}
+}
+
+func (f *Form) focusedChild() Window {
+	return f.focus
+}
+
+func (f *Form) bounds() rect {
+	return newRect(f.corner.x, f.corner.y, f.size.x, f.size.y)
+}
+
+func (f *Form) dirtyRect() rect {
+	if f.dirty {
+		return f.bounds()
+	}
+	r := emptyRect
+	for _, w := range f.windows() {
+		r = union(r, w.dirtyRect())
+	}
+	return r
+}
+
+func (f *Form) invalidate() {
+	f.dirty = true
+}
+
+func (f *Form) move(x, y int) {
+	damage(f.bounds())
+	f.corner = coord{x, y}
+	f.layout()
+}
+
+// resize changes the width of the form. The form's height is determined by
+// its rows.
+func (f *Form) resize(width, height int) {
+	damage(f.bounds())
+	f.size.x = width
+	f.layout()
+}
+
+func (f *Form) getCursor() (x, y int, show bool) {
+	if f.focus == nil {
+		return 0, 0, false
+	}
+	return f.focus.getCursor()
+}
+
+func (f *Form) onKey(ev tb.Event) error {
+	switch {
+	case ev.Key == tb.KeyTab && (ev.Mod&tb.ModShift) != 0:
+		f.moveFocus(-1)
+	case ev.Key == tb.KeyTab:
+		f.moveFocus(+1)
+	case f.focus != nil:
+		return f.focus.onKey(ev)
+	}
+	return nil
+}
+
+func (f *Form) onMouse(ev tb.Event) error {
+	w := f.pressed
+	if w == nil || isPress(ev) {
+		w = nil
+		for _, ww := range f.windows() {
+			if contains(ww.bounds(), ev.MouseX, ev.MouseY) {
+				w = ww
+			}
+		}
+	}
+
+	switch {
+	case ev.Key == tb.MouseRelease:
+		f.pressed = nil
+	case isPress(ev):
+		f.pressed = w
+		if w != nil && focusable(w) {
+			f.focus = w
+		}
+	}
+
+	if w == nil {
+		return nil
+	}
+	return w.onMouse(ev)
+}
+
+func (f *Form) onDraw(cv *Canvas) {
+	if f.dirty {
+		f.dirty = false
+		f.draw(cv)
+		for _, w := range f.windows() {
+			w.invalidate()
+		}
+	}
+	for _, w := range f.windows() {
+		w.onDraw(cv.sub(w.bounds()))
+	}
+}
+
+// draw clears the form and draws its labels and error messages.
+func (f *Form) draw(cv *Canvas) {
+	cv.Clear(theme.Fg, theme.Bg)
+
+	lw := f.labelWidth()
+	var cells []tb.Cell
+	for _, row := range f.rows {
+		if row.label != "" {
+			cells = appendAligned(cells[:0], row.label, lw-1, f.labelAlign, theme.Fg, theme.Bg)
+			cv.SetCells(0, row.y, cells)
+		}
+		if row.err != "" {
+			b := row.ws[0].bounds()
+			cv.DrawText(lw, b.y1-f.corner.y, f.size.x-lw, row.err, theme.ErrorFg, theme.Bg)
+		}
+	}
+}
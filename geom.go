@@ -127,6 +127,10 @@ func intersects(r1, r2 rect) bool {
 	return !intersection(r1, r2).empty()
 }
 
+func contains(r rect, x, y int) bool {
+	return x >= r.x0 && x < r.x1 && y >= r.y0 && y < r.y1
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
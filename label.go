@@ -0,0 +1,106 @@
+package termwin
+
+import (
+	tb "github.com/nsf/termbox-go"
+)
+
+// A Label displays static text. Text wider than the label is wrapped onto
+// multiple lines, and each line is aligned within the label's width.
+type Label struct {
+	corner coord     // screen coordinate of top-left corner
+	size   coord     // screen dimensions of the label
+	text   string    // text displayed by the label
+	lines  []string  // text wrapped to the label's width
+	align  Alignment // alignment of each line
+	dirty  bool      // label needs to be redrawn
+}
+
+// NewLabel creates a new Label control with the specified screen position
+// and width. The label is as tall as the number of lines needed to display
+// the text.
+func NewLabel(x, y, width int, text string) *Label {
+	l := &Label{
+		corner: coord{x, y},
+		size:   coord{width, 0},
+	}
+	l.SetText(text)
+	addWindow(l)
+	return l
+}
+
+// Text returns the text displayed by the label.
+func (l *Label) Text() string {
+	return l.text
+}
+
+// SetText changes the text displayed by the label.
+func (l *Label) SetText(text string) {
+	damage(l.bounds())
+	l.text = text
+	l.lines = wrapText(text, max(l.size.x, 1))
+	l.size.y = len(l.lines)
+	l.dirty = true
+}
+
+// SetAlign sets the alignment of the label's text.
+func (l *Label) SetAlign(a Alignment) {
+	l.align = a
+	l.dirty = true
+}
+
+func (l *Label) canFocus() bool {
+	return false
+}
+
+func (l *Label) bounds() rect {
+	return newRect(l.corner.x, l.corner.y, l.size.x, l.size.y)
+}
+
+func (l *Label) dirtyRect() rect {
+	if !l.dirty {
+		return emptyRect
+	}
+	return l.bounds()
+}
+
+func (l *Label) invalidate() {
+	l.dirty = true
+}
+
+func (l *Label) move(x, y int) {
+	damage(l.bounds())
+	l.corner = coord{x, y}
+	l.dirty = true
+}
+
+// resize changes the width of the label and rewraps its text. The label's
+// height is determined by the number of lines of text.
+func (l *Label) resize(width, height int) {
+	l.size.x = width
+	l.SetText(l.text)
+}
+
+func (l *Label) getCursor() (x, y int, show bool) {
+	return 0, 0, false
+}
+
+func (l *Label) onKey(ev tb.Event) error {
+	return nil
+}
+
+func (l *Label) onMouse(ev tb.Event) error {
+	return nil
+}
+
+func (l *Label) onDraw(cv *Canvas) {
+	if !l.dirty {
+		return
+	}
+	l.dirty = false
+
+	var cells []tb.Cell
+	for i, line := range l.lines {
+		cells = appendAligned(cells[:0], line, l.size.x, l.align, theme.Fg, theme.Bg)
+		cv.SetCells(0, i, cells)
+	}
+}
@@ -0,0 +1,476 @@
+package termwin
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+const (
+	// searchTimeout is the maximum delay between keystrokes of an
+	// incremental type-to-search.
+	searchTimeout = time.Second
+
+	// doubleClickTime is the maximum delay between the clicks of a
+	// double-click.
+	doubleClickTime = 400 * time.Millisecond
+)
+
+// A ListSource provides the items displayed by a ListBox. Items are
+// requested only when they are displayed or searched, so a source may
+// represent a very large list without materializing every item.
+type ListSource interface {
+	// Len returns the number of items in the list.
+	Len() int
+
+	// Item returns the text of the item at index i.
+	Item(i int) string
+}
+
+// A StringList is a ListSource backed by a slice of strings.
+type StringList []string
+
+// Len returns the number of items in the list.
+func (s StringList) Len() int {
+	return len(s)
+}
+
+// Item returns the item at index i.
+func (s StringList) Item(i int) string {
+	return s[i]
+}
+
+// ListBoxFlags define settings for a ListBox.
+type ListBoxFlags byte
+
+const (
+	// ListBoxMultiSelect allows more than one item to be selected. Space
+	// toggles the selection of the current item, and shift extends the
+	// selection while moving.
+	ListBoxMultiSelect ListBoxFlags = 1 << iota
+)
+
+// A ListBox displays a scrollable list of items from which the user may
+// select one or more.
+type ListBox struct {
+	corner     coord            // screen coordinate of top-left corner
+	size       coord            // screen dimensions of the list
+	source     ListSource       // source of list items
+	flags      ListBoxFlags     // list settings
+	top        int              // index of the first visible item
+	current    int              // index of the item under the cursor
+	anchor     int              // index where a shift-selection started
+	selected   map[int]bool     // selected items in multi-select mode
+	search     []rune           // incremental search text
+	searchTime time.Time        // time of the last search keystroke
+	clickTime  time.Time        // time of the last mouse click
+	fg, bg     tb.Attribute     // colors of unselected items
+	selFg      tb.Attribute     // foreground color of selected items
+	selBg      tb.Attribute     // background color of selected items
+	dirty      bool             // list needs to be redrawn
+	onSelect   func(l *ListBox) // called when the selection changes
+	onActivate func(index int)  // called when an item is activated
+}
+
+// NewListBox creates a new ListBox control with the specified screen
+// position and size, displaying items from source.
+func NewListBox(x, y, width, height int, source ListSource, flags ListBoxFlags) *ListBox {
+	l := newListBox(x, y, width, height, source, flags)
+	addWindow(l)
+	return l
+}
+
+func newListBox(x, y, width, height int, source ListSource, flags ListBoxFlags) *ListBox {
+	if source == nil {
+		source = StringList(nil)
+	}
+	return &ListBox{
+		corner:   coord{x, y},
+		size:     coord{width, height},
+		source:   source,
+		flags:    flags,
+		selected: make(map[int]bool),
+		fg:       tb.ColorDefault,
+		bg:       tb.ColorDefault,
+		selFg:    tb.ColorBlack,
+		selBg:    tb.ColorWhite,
+		dirty:    true,
+	}
+}
+
+// Source returns the source of the list's items.
+func (l *ListBox) Source() ListSource {
+	return l.source
+}
+
+// SetSource replaces the source of the list's items. The selection is
+// cleared and the first item becomes current.
+func (l *ListBox) SetSource(source ListSource) {
+	if source == nil {
+		source = StringList(nil)
+	}
+	l.source = source
+	l.selected = make(map[int]bool)
+	l.top, l.current, l.anchor = 0, 0, 0
+	l.dirty = true
+	l.selectionChanged()
+}
+
+// Refresh redraws the list after the items in its source have changed.
+// The current item and selection are clamped to the new length.
+func (l *ListBox) Refresh() {
+	n := l.source.Len()
+	for i := range l.selected {
+		if i >= n {
+			delete(l.selected, i)
+		}
+	}
+	l.current = min(l.current, max(n-1, 0))
+	l.anchor = min(l.anchor, max(n-1, 0))
+	l.updateView()
+	l.dirty = true
+}
+
+// SetColors sets the foreground and background colors of unselected items.
+func (l *ListBox) SetColors(fg, bg tb.Attribute) {
+	l.fg, l.bg = fg, bg
+	l.dirty = true
+}
+
+// SetSelectedColors sets the foreground and background colors of selected
+// items.
+func (l *ListBox) SetSelectedColors(fg, bg tb.Attribute) {
+	l.selFg, l.selBg = fg, bg
+	l.dirty = true
+}
+
+// OnSelect sets the function called whenever the selection changes.
+func (l *ListBox) OnSelect(f func(l *ListBox)) {
+	l.onSelect = f
+}
+
+// OnActivate sets the function called when the user activates an item by
+// pressing Enter or double-clicking it.
+func (l *ListBox) OnActivate(f func(index int)) {
+	l.onActivate = f
+}
+
+// Current returns the index of the item under the cursor, or -1 if the list
+// is empty.
+func (l *ListBox) Current() int {
+	if l.source.Len() == 0 {
+		return -1
+	}
+	return l.current
+}
+
+// SetCurrent moves the cursor to the item at index i, scrolling the list if
+// necessary. In single-select mode, the item is also selected.
+func (l *ListBox) SetCurrent(i int) {
+	l.moveTo(i, false)
+}
+
+// Selected returns the indices of the selected items in ascending order.
+func (l *ListBox) Selected() []int {
+	if !l.multi() {
+		if l.source.Len() == 0 {
+			return nil
+		}
+		return []int{l.current}
+	}
+
+	s := make([]int, 0, len(l.selected))
+	for i := range l.selected {
+		s = append(s, i)
+	}
+	sort.Ints(s)
+	return s
+}
+
+// IsSelected returns true if the item at index i is selected.
+func (l *ListBox) IsSelected(i int) bool {
+	if !l.multi() {
+		return i == l.current && i < l.source.Len()
+	}
+	return l.selected[i]
+}
+
+// Select selects or deselects the item at index i. In single-select mode,
+// selecting an item makes it current.
+func (l *ListBox) Select(i int, sel bool) {
+	if i < 0 || i >= l.source.Len() {
+		return
+	}
+	if !l.multi() {
+		if sel {
+			l.SetCurrent(i)
+		}
+		return
+	}
+	if l.selected[i] != sel {
+		l.setSelected(i, sel)
+		l.dirty = true
+		l.selectionChanged()
+	}
+}
+
+// ClearSelection deselects all items in multi-select mode.
+func (l *ListBox) ClearSelection() {
+	if len(l.selected) > 0 {
+		l.selected = make(map[int]bool)
+		l.dirty = true
+		l.selectionChanged()
+	}
+}
+
+// Search moves the cursor to the first item at or after index from whose
+// text starts with prefix, ignoring case and wrapping around at the end of
+// the list. It returns false if no item matches.
+func (l *ListBox) Search(prefix string, from int) bool {
+	n := l.source.Len()
+	if n == 0 {
+		return false
+	}
+	prefix = strings.ToLower(prefix)
+	for j := 0; j < n; j++ {
+		i := (from + j) % n
+		if strings.HasPrefix(strings.ToLower(l.source.Item(i)), prefix) {
+			l.moveTo(i, false)
+			return true
+		}
+	}
+	return false
+}
+
+func (l *ListBox) bounds() rect {
+	return newRect(l.corner.x, l.corner.y, l.size.x, l.size.y)
+}
+
+func (l *ListBox) dirtyRect() rect {
+	if !l.dirty {
+		return emptyRect
+	}
+	return l.bounds()
+}
+
+func (l *ListBox) invalidate() {
+	l.dirty = true
+}
+
+func (l *ListBox) move(x, y int) {
+	damage(l.bounds())
+	l.corner = coord{x, y}
+	l.dirty = true
+}
+
+func (l *ListBox) resize(width, height int) {
+	damage(l.bounds())
+	l.size = coord{width, height}
+	l.updateView()
+	l.dirty = true
+}
+
+func (l *ListBox) getCursor() (x, y int, show bool) {
+	return 0, 0, false
+}
+
+func (l *ListBox) onKey(ev tb.Event) error {
+	extend := (ev.Mod & tb.ModShift) != 0
+	page := max(l.size.y-1, 1)
+
+	switch ev.Key {
+	case tb.KeyArrowUp, tb.KeyCtrlP:
+		l.moveTo(l.current-1, extend)
+	case tb.KeyArrowDown, tb.KeyCtrlN:
+		l.moveTo(l.current+1, extend)
+	case tb.KeyPgup:
+		l.moveTo(l.current-page, extend)
+	case tb.KeyPgdn:
+		l.moveTo(l.current+page, extend)
+	case tb.KeyHome:
+		l.moveTo(0, extend)
+	case tb.KeyEnd:
+		l.moveTo(l.source.Len()-1, extend)
+	case tb.KeyEnter:
+		l.activate()
+	case tb.KeySpace:
+		if l.multi() {
+			l.Select(l.current, !l.selected[l.current])
+			l.anchor = l.current
+		} else {
+			l.typeSearch(charSpace)
+		}
+	case tb.KeyEsc:
+		l.search = l.search[:0]
+	default:
+		if ev.Ch != 0 && (ev.Mod&tb.ModAlt) == 0 {
+			l.typeSearch(ev.Ch)
+		}
+	}
+	return nil
+}
+
+func (l *ListBox) onMouse(ev tb.Event) error {
+	switch ev.Key {
+	case tb.MouseLeft:
+		i := l.top + ev.MouseY - l.corner.y
+		if i < l.top || i >= l.source.Len() {
+			return nil
+		}
+		if (ev.Mod & tb.ModMotion) != 0 {
+			l.moveTo(i, l.multi())
+			return nil
+		}
+
+		now := time.Now()
+		double := i == l.current && now.Sub(l.clickTime) < doubleClickTime
+		l.clickTime = now
+		if l.multi() {
+			l.Select(i, !l.selected[i])
+			l.anchor = i
+		}
+		l.moveTo(i, false)
+		if double {
+			l.activate()
+		}
+	case tb.MouseWheelUp:
+		l.scroll(-mouseWheelRows)
+	case tb.MouseWheelDown:
+		l.scroll(+mouseWheelRows)
+	}
+	return nil
+}
+
+func (l *ListBox) onDraw(cv *Canvas) {
+	l.draw(cv)
+}
+
+// Draw updates the contents of the ListBox on the screen.
+func (l *ListBox) Draw() {
+	l.draw(newCanvas(l.bounds()))
+}
+
+// draw redraws the list box on a canvas if it is dirty.
+func (l *ListBox) draw(cv *Canvas) {
+	if !l.dirty {
+		return
+	}
+	l.dirty = false
+
+	n := l.source.Len()
+	for y := 0; y < l.size.y; y++ {
+		fg, bg := l.fg, l.bg
+		text := ""
+		if i := l.top + y; i < n {
+			text = l.source.Item(i)
+			if l.IsSelected(i) {
+				fg, bg = l.selFg, l.selBg
+			}
+			if i == l.current && l.multi() {
+				fg |= tb.AttrUnderline
+			}
+		}
+
+		w := cv.DrawText(0, y, l.size.x, text, fg, bg)
+		cv.Fill(w, y, l.size.x-w, 1, charSpace, fg, bg)
+	}
+}
+
+// multi returns true if the list allows multiple selected items.
+func (l *ListBox) multi() bool {
+	return (l.flags & ListBoxMultiSelect) != 0
+}
+
+// moveTo moves the cursor to item i, clamped to the list bounds. When
+// extend is true in multi-select mode, the selection is replaced by the
+// range of items between the anchor and the new current item.
+func (l *ListBox) moveTo(i int, extend bool) {
+	n := l.source.Len()
+	if n == 0 {
+		return
+	}
+	i = min(max(i, 0), n-1)
+
+	prev := l.current
+	l.current = i
+	l.updateView()
+	l.dirty = true
+
+	switch {
+	case !l.multi():
+		if i != prev {
+			l.selectionChanged()
+		}
+	case extend:
+		l.selected = make(map[int]bool)
+		lo, hi := min(l.anchor, i), max(l.anchor, i)
+		for j := lo; j <= hi; j++ {
+			l.setSelected(j, true)
+		}
+		l.selectionChanged()
+	default:
+		l.anchor = i
+	}
+}
+
+// scroll scrolls the list by dy rows without moving the cursor.
+func (l *ListBox) scroll(dy int) {
+	top := min(max(l.top+dy, 0), max(l.source.Len()-l.size.y, 0))
+	if top != l.top {
+		l.top = top
+		l.dirty = true
+	}
+}
+
+// updateView scrolls the list so the current item is visible.
+func (l *ListBox) updateView() {
+	switch {
+	case l.current < l.top:
+		l.top = l.current
+	case l.current >= l.top+l.size.y:
+		l.top = l.current - l.size.y + 1
+	}
+	l.top = max(l.top, 0)
+}
+
+// typeSearch adds a character to the incremental search text and moves to
+// the first matching item. The search restarts if too much time has passed
+// since the previous keystroke.
+func (l *ListBox) typeSearch(ch rune) {
+	now := time.Now()
+	if now.Sub(l.searchTime) > searchTimeout {
+		l.search = l.search[:0]
+	}
+	l.searchTime = now
+
+	from := l.current
+	if len(l.search) == 0 {
+		from++
+	}
+	l.search = append(l.search, unicode.ToLower(ch))
+	l.Search(string(l.search), from)
+}
+
+// activate calls the activation function with the current item.
+func (l *ListBox) activate() {
+	if l.onActivate != nil && l.source.Len() > 0 {
+		l.onActivate(l.current)
+	}
+}
+
+func (l *ListBox) setSelected(i int, sel bool) {
+	if sel {
+		l.selected[i] = true
+	} else {
+		delete(l.selected, i)
+	}
+}
+
+func (l *ListBox) selectionChanged() {
+	if l.onSelect != nil {
+		l.onSelect(l)
+	}
+}
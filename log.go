@@ -1,28 +1,264 @@
 package termwin
 
 import (
+	stdcontext "context"
 	"fmt"
-	"io"
+	"log/slog"
 	"os"
+	"strconv"
+	"sync"
 )
 
-var log io.Writer
+// Log levels, in increasing order of severity. Key events are logged at
+// LevelDebug only, since they may contain passwords and other sensitive
+// input.
+const (
+	LevelDebug = slog.LevelDebug
+	LevelInfo  = slog.LevelInfo
+	LevelWarn  = slog.LevelWarn
+	LevelError = slog.LevelError
+)
+
+const (
+	// defaultLogMaxSize is the size at which a log file created by
+	// CreateLog is rotated.
+	defaultLogMaxSize = 1 << 20
+
+	// defaultLogBackups is the number of rotated log files kept by
+	// CreateLog.
+	defaultLogBackups = 3
+)
+
+var (
+	// logger receives termwin's log output. It writes to baseLogger and,
+	// if set, to logTap.
+	logger = slog.New(slog.DiscardHandler)
+
+	// baseLogger is the logger selected by the application. By default it
+	// discards everything.
+	baseLogger = logger
+
+	// logTap is an additional handler receiving all log records, such as
+	// the debug console's, or nil.
+	logTap slog.Handler
+
+	// logFile is the file opened by CreateLog for baseLogger, or nil. It
+	// is closed when the logger is replaced.
+	logFile *RotatingFile
+)
+
+// WithLogger selects the logger receiving termwin's log output.
+func WithLogger(l *slog.Logger) Option {
+	return func() {
+		SetLogger(l)
+	}
+}
+
+// SetLogger selects the logger receiving termwin's log output. Passing nil
+// discards all log output, which is the default. A log file created by
+// CreateLog is closed.
+func SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = slog.New(slog.DiscardHandler)
+	}
+	baseLogger = l
+	updateLogger()
+	if logFile != nil {
+		logFile.Close()
+		logFile = nil
+	}
+}
 
-// CreateLog creates a new file for log output.
-func CreateLog(filename string) {
-	file, err := os.OpenFile(filename, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0644)
+// setLogTap installs an additional handler receiving all log records, or
+// removes it if h is nil.
+func setLogTap(h slog.Handler) {
+	logTap = h
+	updateLogger()
+}
+
+// updateLogger combines the application's logger and the log tap.
+func updateLogger() {
+	if logTap == nil {
+		logger = baseLogger
+	} else {
+		logger = slog.New(fanoutHandler{baseLogger.Handler(), logTap})
+	}
+}
+
+// Logger returns the logger receiving termwin's log output. Applications
+// may use it to write their own messages to the same log, and to the debug
+// console if one is open.
+func Logger() *slog.Logger {
+	return logger
+}
+
+// CreateLog creates a new file for log output and directs termwin's log
+// messages at or above the given level to it, in the text format of
+// slog.TextHandler. The file is rotated when it grows beyond 1 MiB, and
+// the three most recent rotated files are kept. The file is closed when
+// the logger is replaced by another call to CreateLog or SetLogger; call
+// SetLogger(nil) to close it when the application exits.
+func CreateLog(filename string, level slog.Level) error {
+	f, err := NewRotatingFile(filename, defaultLogMaxSize, defaultLogBackups)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	log = file
+	SetLogger(slog.New(slog.NewTextHandler(f, &slog.HandlerOptions{Level: level})))
+	logFile = f
+	return nil
 }
 
-// Logf logs a formatted line of text to the log output file.
+// Logf logs a formatted message at the info level.
+//
+// Deprecated: use Logger, which supports levels and key/value attributes.
 func Logf(format string, args ...interface{}) {
-	fmt.Fprintf(log, format, args...)
+	logger.Info(fmt.Sprintf(format, args...))
 }
 
-// Logln logs a newline-terminated string to the log output file.
+// Logln logs a message at the info level.
+//
+// Deprecated: use Logger, which supports levels and key/value attributes.
 func Logln(s string) {
-	fmt.Fprintln(log, s)
+	logger.Info(s)
+}
+
+// logDebugEnabled returns true if debug messages are being logged. It is
+// used to avoid building messages that would be discarded.
+func logDebugEnabled() bool {
+	return logger.Enabled(stdcontext.Background(), LevelDebug)
+}
+
+// A fanoutHandler is a slog.Handler passing each record to several
+// handlers.
+type fanoutHandler []slog.Handler
+
+func (f fanoutHandler) Enabled(ctx stdcontext.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanoutHandler) Handle(ctx stdcontext.Context, r slog.Record) error {
+	var first error
+	for _, h := range f {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	g := make(fanoutHandler, len(f))
+	for i, h := range f {
+		g[i] = h.WithAttrs(attrs)
+	}
+	return g
+}
+
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	g := make(fanoutHandler, len(f))
+	for i, h := range f {
+		g[i] = h.WithGroup(name)
+	}
+	return g
+}
+
+// A RotatingFile is a log file that is rotated when it reaches a maximum
+// size. On rotation the file is renamed with the suffix ".1", older
+// rotated files are renamed with the next higher suffix, and the oldest is
+// removed. A RotatingFile is safe for concurrent use.
+type RotatingFile struct {
+	mu       sync.Mutex
+	filename string   // path of the current log file
+	maxSize  int64    // size at which the file is rotated
+	backups  int      // number of rotated files to keep
+	file     *os.File // current log file
+	size     int64    // bytes written to the current file
+}
+
+// NewRotatingFile creates or truncates a log file that is rotated when
+// writing to it would exceed maxSize bytes, keeping at most backups rotated
+// files. A maxSize of zero or less disables rotation.
+func NewRotatingFile(filename string, maxSize int64, backups int) (*RotatingFile, error) {
+	r := &RotatingFile{
+		filename: filename,
+		maxSize:  maxSize,
+		backups:  max(backups, 0),
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write writes to the log file, rotating it first if the write would make
+// it exceed its maximum size.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the log file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// open creates or truncates the current log file.
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.filename, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	r.file, r.size = f, 0
+	return nil
+}
+
+// rotate closes the current log file, shifts the rotated files and opens a
+// new, empty log file.
+func (r *RotatingFile) rotate() error {
+	r.file.Close()
+	r.file = nil
+
+	if r.backups == 0 {
+		os.Remove(r.filename)
+	} else {
+		os.Remove(r.backupName(r.backups))
+		for i := r.backups - 1; i >= 1; i-- {
+			os.Rename(r.backupName(i), r.backupName(i+1))
+		}
+		os.Rename(r.filename, r.backupName(1))
+	}
+	return r.open()
+}
+
+// backupName returns the path of the i-th rotated log file.
+func (r *RotatingFile) backupName(i int) string {
+	return r.filename + "." + strconv.Itoa(i)
 }
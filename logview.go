@@ -0,0 +1,527 @@
+package termwin
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+const (
+	// defaultLogContext is the number of context lines shown around each
+	// match when context is enabled.
+	defaultLogContext = 2
+
+	// logFilterPrompt is the label displayed before a log view's filter.
+	logFilterPrompt = "Filter: "
+)
+
+// A FilterMode determines how the text of a LogView filter is matched
+// against lines.
+type FilterMode byte
+
+const (
+	// FilterSubstring matches lines containing the filter text. The match
+	// ignores case unless the filter contains an upper-case letter.
+	FilterSubstring FilterMode = iota
+
+	// FilterRegexp matches lines containing a match of the filter text as a
+	// regular expression. The match ignores case unless the expression
+	// contains an upper-case letter.
+	FilterRegexp
+)
+
+// A logLine is a line held by a LogView.
+type logLine struct {
+	text  string     // text of the line, without control characters
+	level slog.Level // severity of the line
+}
+
+// A logRow is a row of a LogView's filtered output.
+type logRow struct {
+	line  int  // index of the line, counting discarded lines
+	match bool // line matches the filter, rather than being context
+	sep   bool // row separates groups of lines, and precedes line
+}
+
+// A LogView is a window displaying a growing list of log lines that can be
+// narrowed as the user types a filter. Lines match the filter if they
+// contain its text, or a match of it as a regular expression, and have at
+// least the minimum severity level selected. Matches are highlighted, and
+// lines around them may be shown as context. The status line shows the
+// number of matching lines and the total.
+//
+// Keys typed into the view edit the filter, except for these:
+//
+//	Up Down, PgUp PgDn      scroll the lines
+//	Ctrl+Home Ctrl+End      go to the first or last line
+//	Esc                     clear the filter
+//	F2                      switch between substring and regexp matching
+//	F3                      cycle the minimum level through DEBUG, INFO,
+//	                        WARN and ERROR
+//	F4                      show or hide context lines
+//
+// While the last line is displayed, the view follows newly added lines.
+type LogView struct {
+	corner    coord                                // screen coordinate of top-left corner
+	size      coord                                // screen dimensions of the view
+	input     *TextInput                           // filter text input
+	mode      FilterMode                           // how the filter is matched
+	filter    string                               // filter text last applied
+	re        *regexp.Regexp                       // compiled filter, or nil to match all lines
+	filterErr error                                // error compiling the filter text
+	minLevel  slog.Level                           // minimum level of matching lines
+	levelFunc func(line string) (slog.Level, bool) // finds the level of a line
+	lines     []logLine                            // lines not yet discarded
+	base      int                                  // number of discarded lines
+	maxLines  int                                  // maximum lines kept, or 0
+	partial   string                               // incomplete line written last
+	rows      []logRow                             // filtered output
+	matches   int                                  // number of matching rows
+	context   int                                  // context lines around matches
+	showCtx   bool                                 // context lines are shown
+	lastShown int                                  // index of the last line in rows, or -1
+	after     int                                  // context lines still to show after a match
+	top       int                                  // index of the first visible row
+	dirty     bool                                 // view needs to be redrawn
+}
+
+// NewLogView creates a new, empty LogView with the specified screen
+// position and size.
+func NewLogView(x, y, width, height int) *LogView {
+	v := &LogView{
+		corner:    coord{x, y},
+		size:      coord{width, height},
+		minLevel:  LevelDebug,
+		levelFunc: DetectLogLevel,
+		context:   defaultLogContext,
+		lastShown: -1,
+		dirty:     true,
+	}
+	n := len(logFilterPrompt)
+	v.input = newTextInput(x+n, y, max(width-n, 0))
+	addWindow(v)
+	return v
+}
+
+// DetectLogLevel finds the severity level of a log line from the first
+// upper-case level name it contains, such as the "level=WARN" attribute of
+// slog's text format. It recognizes DEBUG, INFO, WARN, WARNING, ERROR and
+// FATAL.
+func DetectLogLevel(line string) (slog.Level, bool) {
+	for _, w := range strings.FieldsFunc(line, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		switch w {
+		case "DEBUG":
+			return LevelDebug, true
+		case "INFO":
+			return LevelInfo, true
+		case "WARN", "WARNING":
+			return LevelWarn, true
+		case "ERROR", "FATAL":
+			return LevelError, true
+		}
+	}
+	return 0, false
+}
+
+// Write adds the lines of text written to the view. A final line without a
+// newline is held until the rest of it is written. Write may be called from
+// any goroutine; the lines are added to the view by Poll.
+func (v *LogView) Write(p []byte) (int, error) {
+	s := string(p)
+	Post(func() { v.addText(s) })
+	return len(p), nil
+}
+
+// Feed adds the lines received from a channel to the view, until the
+// channel is closed. The lines are added by Poll.
+func (v *LogView) Feed(ch <-chan string) {
+	feedBatches(ch, func(batch []string) {
+		for _, line := range batch {
+			v.AddLine(line)
+		}
+	}, nil)
+}
+
+// AddLine adds a line to the view.
+func (v *LogView) AddLine(s string) {
+	follow := v.following()
+	s = sanitizeLine(expandTabs(s))
+	level, ok := v.levelFunc(s)
+	if !ok {
+		// Lines without a level, such as stack traces, continue the line
+		// before them.
+		level = LevelInfo
+		if len(v.lines) > 0 {
+			level = v.lines[len(v.lines)-1].level
+		}
+	}
+	v.lines = append(v.lines, logLine{text: s, level: level})
+	v.consider(v.base + len(v.lines) - 1)
+	v.trim()
+	if follow {
+		v.top = v.maxTop()
+	}
+	v.dirty = true
+}
+
+// addText adds written text, splitting it into lines.
+func (v *LogView) addText(s string) {
+	s = v.partial + s
+	for {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			break
+		}
+		v.AddLine(strings.TrimSuffix(s[:i], "\r"))
+		s = s[i+1:]
+	}
+	v.partial = s
+}
+
+// SetMaxLines sets the maximum number of lines kept by the view. When more
+// lines are added, the oldest lines are discarded. A value of 0 removes the
+// limit.
+func (v *LogView) SetMaxLines(n int) {
+	v.maxLines = max(n, 0)
+	v.trim()
+}
+
+// SetLevelFunc sets the function finding the severity level of a line. It
+// is DetectLogLevel by default. Lines for which the function returns false
+// take the level of the line before them.
+func (v *LogView) SetLevelFunc(f func(line string) (slog.Level, bool)) {
+	v.levelFunc = f
+}
+
+// Filter returns the filter text and mode.
+func (v *LogView) Filter() (text string, mode FilterMode) {
+	return v.input.Text(), v.mode
+}
+
+// SetFilter replaces the filter text and mode.
+func (v *LogView) SetFilter(text string, mode FilterMode) {
+	v.input.SetText(text)
+	v.mode = mode
+	v.applyFilter()
+}
+
+// SetMinLevel sets the minimum severity level of matching lines. A level of
+// LevelDebug or lower matches lines of every level.
+func (v *LogView) SetMinLevel(l slog.Level) {
+	v.minLevel = l
+	v.refilter()
+}
+
+// SetContext sets the number of lines shown before and after each match
+// when context lines are shown.
+func (v *LogView) SetContext(n int) {
+	v.context = max(n, 0)
+	v.refilter()
+}
+
+// ShowContext shows or hides the context lines around matches.
+func (v *LogView) ShowContext(on bool) {
+	v.showCtx = on
+	v.refilter()
+}
+
+// Counts returns the number of lines matching the filter and the number of
+// lines in the view.
+func (v *LogView) Counts() (matches, total int) {
+	return v.matches, len(v.lines)
+}
+
+// applyFilter compiles the filter text and refilters the lines. If the
+// filter is an invalid regular expression, the previous filter remains in
+// effect.
+func (v *LogView) applyFilter() {
+	text := v.input.Text()
+	pattern := text
+	if v.mode == FilterSubstring {
+		pattern = regexp.QuoteMeta(text)
+	}
+
+	var re *regexp.Regexp
+	if text != "" {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			v.filterErr = err
+			v.dirty = true
+			return
+		}
+		if strings.IndexFunc(text, unicode.IsUpper) < 0 {
+			re = regexp.MustCompile("(?i)" + pattern)
+		}
+	}
+	v.filter, v.re, v.filterErr = text, re, nil
+	v.refilter()
+}
+
+// refilter rebuilds the filtered output from all lines.
+func (v *LogView) refilter() {
+	v.rows = v.rows[:0]
+	v.matches, v.lastShown, v.after = 0, -1, 0
+	for i := range v.lines {
+		v.consider(v.base + i)
+	}
+	v.top = v.maxTop()
+	v.dirty = true
+}
+
+// consider adds a line to the filtered output if it matches the filter or
+// is context for a match, along with the context lines before a match.
+func (v *LogView) consider(i int) {
+	ctx := 0
+	if v.showCtx {
+		ctx = v.context
+	}
+	if !v.accept(v.line(i)) {
+		if v.after > 0 {
+			v.rows = append(v.rows, logRow{line: i})
+			v.lastShown = i
+			v.after--
+		}
+		return
+	}
+
+	start := max(max(i-ctx, v.lastShown+1), v.base)
+	if ctx > 0 && v.lastShown >= 0 && start > v.lastShown+1 {
+		v.rows = append(v.rows, logRow{line: start, sep: true})
+	}
+	for j := start; j < i; j++ {
+		v.rows = append(v.rows, logRow{line: j})
+	}
+	v.rows = append(v.rows, logRow{line: i, match: true})
+	v.matches++
+	v.lastShown, v.after = i, ctx
+}
+
+// accept returns true if a line matches the filter.
+func (v *LogView) accept(l *logLine) bool {
+	if l.level < v.minLevel {
+		return false
+	}
+	return v.re == nil || v.re.MatchString(l.text)
+}
+
+// line returns the line with an index counting discarded lines.
+func (v *LogView) line(i int) *logLine {
+	return &v.lines[i-v.base]
+}
+
+// trim discards the oldest lines in excess of the maximum, along with their
+// rows.
+func (v *LogView) trim() {
+	n := len(v.lines) - v.maxLines
+	if v.maxLines == 0 || n <= 0 {
+		return
+	}
+	copy(v.lines, v.lines[n:])
+	v.lines = v.lines[:len(v.lines)-n]
+	v.base += n
+
+	k := 0
+	for k < len(v.rows) && (v.rows[k].line < v.base || v.rows[k].sep) {
+		if v.rows[k].match {
+			v.matches--
+		}
+		k++
+	}
+	v.rows = v.rows[:copy(v.rows, v.rows[k:])]
+	v.top = max(v.top-k, 0)
+	v.dirty = true
+}
+
+// visibleRows returns the number of rows displaying lines, between the
+// filter and the status line.
+func (v *LogView) visibleRows() int {
+	return max(v.size.y-2, 1)
+}
+
+// maxTop returns the index of the top row when the last row is at the
+// bottom of the view.
+func (v *LogView) maxTop() int {
+	return max(len(v.rows)-v.visibleRows(), 0)
+}
+
+// following returns true if the last row is displayed.
+func (v *LogView) following() bool {
+	return v.top >= v.maxTop()
+}
+
+// scrollTo makes a row the top row.
+func (v *LogView) scrollTo(top int) {
+	v.top = min(max(top, 0), v.maxTop())
+	v.dirty = true
+}
+
+// cycleLevel raises the minimum level to the next standard level, wrapping
+// around from ERROR to DEBUG.
+func (v *LogView) cycleLevel() {
+	switch {
+	case v.minLevel < LevelInfo:
+		v.SetMinLevel(LevelInfo)
+	case v.minLevel < LevelWarn:
+		v.SetMinLevel(LevelWarn)
+	case v.minLevel < LevelError:
+		v.SetMinLevel(LevelError)
+	default:
+		v.SetMinLevel(LevelDebug)
+	}
+}
+
+func (v *LogView) focusedChild() Window {
+	return v.input
+}
+
+func (v *LogView) bounds() rect {
+	return newRect(v.corner.x, v.corner.y, v.size.x, v.size.y)
+}
+
+func (v *LogView) dirtyRect() rect {
+	if v.dirty {
+		return v.bounds()
+	}
+	return v.input.dirtyRect()
+}
+
+func (v *LogView) invalidate() {
+	v.dirty = true
+}
+
+func (v *LogView) move(x, y int) {
+	damage(v.bounds())
+	v.corner = coord{x, y}
+	v.input.move(x+len(logFilterPrompt), y)
+	v.dirty = true
+}
+
+func (v *LogView) resize(width, height int) {
+	damage(v.bounds())
+	v.size = coord{width, height}
+	v.input.resize(max(width-len(logFilterPrompt), 0), 1)
+	v.scrollTo(v.top)
+}
+
+func (v *LogView) getCursor() (x, y int, show bool) {
+	return v.input.getCursor()
+}
+
+func (v *LogView) onKey(ev tb.Event) error {
+	ctrl := (ev.Mod & tb.ModCtrl) != 0
+	page := v.visibleRows()
+	switch {
+	case ev.Key == tb.KeyArrowUp:
+		v.scrollTo(v.top - 1)
+	case ev.Key == tb.KeyArrowDown:
+		v.scrollTo(v.top + 1)
+	case ev.Key == tb.KeyPgup:
+		v.scrollTo(v.top - page)
+	case ev.Key == tb.KeyPgdn:
+		v.scrollTo(v.top + page)
+	case ctrl && ev.Key == tb.KeyHome:
+		v.scrollTo(0)
+	case ctrl && ev.Key == tb.KeyEnd:
+		v.scrollTo(v.maxTop())
+	case ev.Key == tb.KeyEsc:
+		v.SetFilter("", v.mode)
+	case ev.Key == tb.KeyF2:
+		v.mode = 1 - v.mode
+		v.applyFilter()
+	case ev.Key == tb.KeyF3:
+		v.cycleLevel()
+	case ev.Key == tb.KeyF4:
+		v.ShowContext(!v.showCtx)
+	default:
+		err := v.input.onKey(ev)
+		if v.input.Text() != v.filter || v.filterErr != nil {
+			v.applyFilter()
+		}
+		return err
+	}
+	return nil
+}
+
+func (v *LogView) onMouse(ev tb.Event) error {
+	switch ev.Key {
+	case tb.MouseWheelUp:
+		v.scrollTo(v.top - mouseWheelRows)
+	case tb.MouseWheelDown:
+		v.scrollTo(v.top + mouseWheelRows)
+	default:
+		if ev.MouseY == v.corner.y {
+			return v.input.onMouse(ev)
+		}
+	}
+	return nil
+}
+
+func (v *LogView) onDraw(cv *Canvas) {
+	if v.dirty {
+		v.dirty = false
+		cv.Clear(theme.Fg, theme.Bg)
+		cv.DrawText(0, 0, v.size.x, logFilterPrompt, theme.Fg, theme.Bg)
+		for y := 0; y < v.visibleRows() && v.top+y < len(v.rows); y++ {
+			v.drawRow(cv, 1+y, v.rows[v.top+y])
+		}
+		v.drawStatus(cv, v.size.y-1)
+		v.input.invalidate()
+	}
+	v.input.onDraw(cv.sub(v.input.bounds()))
+}
+
+// drawRow draws a row of the filtered output, highlighting the matches of
+// the filter on matching lines.
+func (v *LogView) drawRow(cv *Canvas, y int, r logRow) {
+	if r.sep {
+		cv.DrawText(0, y, v.size.x, "--", theme.DisabledFg, theme.Bg)
+		return
+	}
+	s := v.line(r.line).text
+	if !r.match {
+		cv.DrawText(0, y, v.size.x, s, theme.DisabledFg, theme.Bg)
+		return
+	}
+	cv.DrawText(0, y, v.size.x, s, theme.Fg, theme.Bg)
+	if v.re == nil {
+		return
+	}
+	for _, m := range v.re.FindAllStringIndex(s, -1) {
+		x0, x1 := textWidth(s[:m[0]]), textWidth(s[:m[1]])
+		for x := x0; x < min(x1, v.size.x); x++ {
+			cv.setAttr(x, y, tb.AttrReverse)
+		}
+	}
+}
+
+// drawStatus draws the status line, showing the filter settings and the
+// number of matching lines, or the error in an invalid filter.
+func (v *LogView) drawStatus(cv *Canvas, y int) {
+	fg, bg := theme.MenuFg, theme.MenuBg
+	cv.Fill(0, y, v.size.x, 1, charSpace, fg, bg)
+
+	mode := "substring"
+	if v.mode == FilterRegexp {
+		mode = "regexp"
+	}
+	level := "all levels"
+	if v.minLevel > LevelDebug {
+		level = "level>=" + v.minLevel.String()
+	}
+	left := fmt.Sprintf(" %s | %s", mode, level)
+	if v.showCtx {
+		left += fmt.Sprintf(" | context %d", v.context)
+	}
+	right := fmt.Sprintf("%d/%d lines ", v.matches, len(v.lines))
+	if v.filterErr != nil {
+		right = v.filterErr.Error() + " "
+		fg = theme.ErrorFg
+	}
+	rx := max(v.size.x-textWidth(right), 0)
+	cv.DrawText(0, y, rx, left, theme.MenuFg, bg)
+	cv.DrawText(rx, y, v.size.x-rx, right, fg, bg)
+}
@@ -0,0 +1,607 @@
+package termwin
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+const (
+	charCheck       = '✓'
+	charSubmenu     = '►'
+	menuAccelMarker = '&'
+)
+
+// A MenuItem is a single entry in a menu. An ampersand in the label marks
+// the following character as the item's accelerator key; without one, the
+// first character of the label is used. Use "&&" for a literal ampersand.
+type MenuItem struct {
+	Label     string // text displayed for the item
+	Shortcut  string // description of a shortcut key shown to the right
+	Action    func() // called when the item is chosen
+	Submenu   *Menu  // submenu opened by the item, or nil
+	Disabled  bool   // item cannot be chosen
+	Checkable bool   // choosing the item toggles Checked
+	Checked   bool   // item displays a check mark
+	separator bool
+}
+
+// MenuSeparator returns a menu item that draws a horizontal line between
+// groups of items.
+func MenuSeparator() *MenuItem {
+	return &MenuItem{separator: true}
+}
+
+// A Menu is a list of items displayed in a drop-down from a MenuBar, as a
+// submenu of another menu, or as a context menu.
+type Menu struct {
+	Title string // title displayed in a menu bar; '&' marks the accelerator
+	Items []*MenuItem
+}
+
+// NewMenu creates a new menu with a title and items.
+func NewMenu(title string, items ...*MenuItem) *Menu {
+	return &Menu{Title: title, Items: items}
+}
+
+// Show displays the menu as a context menu with its top-left corner at the
+// screen position (x, y). The menu is moved as needed to fit on the screen.
+func (m *Menu) Show(x, y int) {
+	openMenu(m, x, y, nil, nil)
+}
+
+// A MenuBar is a row of menu titles, usually displayed along the top of the
+// screen. Pressing Alt with a title's accelerator key, or F10, opens a menu
+// from the most recently created menu bar. Alt keys bound to commands of
+// the focused window, such as an edit box's Alt+y, go to the window
+// instead.
+type MenuBar struct {
+	corner coord   // screen coordinate of left edge
+	width  int     // screen width of the bar
+	menus  []*Menu // menus displayed in the bar
+	titleX []int   // column of each menu title within the bar
+	active int     // index of the open menu, or -1
+	dirty  bool    // bar needs to be redrawn
+}
+
+// NewMenuBar creates a new menu bar at the specified screen position.
+func NewMenuBar(x, y, width int, menus ...*Menu) *MenuBar {
+	b := &MenuBar{
+		corner: coord{x, y},
+		width:  width,
+		active: -1,
+	}
+	for _, m := range menus {
+		b.AddMenu(m)
+	}
+	addWindow(b)
+	c.menuBar = b
+	return b
+}
+
+// AddMenu appends a menu to the end of the menu bar.
+func (b *MenuBar) AddMenu(m *Menu) {
+	x := 0
+	if n := len(b.menus); n > 0 {
+		x = b.titleX[n-1] + menuLabelLen(b.menus[n-1].Title) + 2
+	}
+	b.menus = append(b.menus, m)
+	b.titleX = append(b.titleX, x)
+	b.dirty = true
+}
+
+// Menus returns the menus displayed in the menu bar.
+func (b *MenuBar) Menus() []*Menu {
+	return b.menus
+}
+
+// Open opens the i-th menu of the menu bar and selects its first item.
+func (b *MenuBar) Open(i int) {
+	if i < 0 || i >= len(b.menus) {
+		return
+	}
+	if b.active >= 0 {
+		closeMenus()
+	}
+	b.active = i
+	b.dirty = true
+	p := openMenu(b.menus[i], b.corner.x+b.titleX[i], b.corner.y+1, nil, b)
+	p.selectNext(0, +1)
+}
+
+// accelerate opens a menu if a key event is one of the menu bar's
+// accelerator keys.
+func (b *MenuBar) accelerate(ev tb.Event) bool {
+	if ev.Key == tb.KeyF10 {
+		b.Open(0)
+		return len(b.menus) > 0
+	}
+	if (ev.Mod&tb.ModAlt) == 0 || ev.Ch == 0 {
+		return false
+	}
+	if i := b.menuFor(ev.Ch); i >= 0 {
+		b.Open(i)
+		return true
+	}
+	return false
+}
+
+// menuFor returns the index of the menu whose accelerator is ch, or -1.
+func (b *MenuBar) menuFor(ch rune) int {
+	ch = unicode.ToLower(ch)
+	for i, m := range b.menus {
+		if _, accel, _ := parseMenuLabel(m.Title); accel == ch {
+			return i
+		}
+	}
+	return -1
+}
+
+// openAdjacent closes the open menu and opens the one to its left (dir < 0)
+// or right (dir > 0).
+func (b *MenuBar) openAdjacent(dir int) {
+	n := len(b.menus)
+	b.Open((b.active + dir + n) % n)
+}
+
+// titleAt returns the index of the menu title at a screen column, or -1.
+func (b *MenuBar) titleAt(x int) int {
+	x -= b.corner.x
+	for i, m := range b.menus {
+		if x >= b.titleX[i] && x < b.titleX[i]+menuLabelLen(m.Title)+2 {
+			return i
+		}
+	}
+	return -1
+}
+
+func (b *MenuBar) canFocus() bool {
+	return false
+}
+
+func (b *MenuBar) bounds() rect {
+	return newRect(b.corner.x, b.corner.y, b.width, 1)
+}
+
+func (b *MenuBar) dirtyRect() rect {
+	if b.dirty {
+		return b.bounds()
+	}
+	return emptyRect
+}
+
+func (b *MenuBar) invalidate() {
+	b.dirty = true
+}
+
+func (b *MenuBar) move(x, y int) {
+	damage(b.bounds())
+	b.corner = coord{x, y}
+	b.dirty = true
+}
+
+// resize changes the width of the bar. The bar is always one row tall.
+func (b *MenuBar) resize(width, height int) {
+	damage(b.bounds())
+	b.width = width
+	b.dirty = true
+}
+
+func (b *MenuBar) getCursor() (x, y int, show bool) {
+	return 0, 0, false
+}
+
+func (b *MenuBar) onKey(ev tb.Event) error {
+	return nil
+}
+
+func (b *MenuBar) onMouse(ev tb.Event) error {
+	if ev.Key == tb.MouseLeft && (ev.Mod&tb.ModMotion) == 0 {
+		b.Open(b.titleAt(ev.MouseX))
+	}
+	return nil
+}
+
+func (b *MenuBar) onDraw(cv *Canvas) {
+	if !b.dirty {
+		return
+	}
+	b.dirty = false
+
+	cv.Clear(theme.MenuFg, theme.MenuBg)
+	for i, m := range b.menus {
+		fg, bg := theme.MenuFg, theme.MenuBg
+		if i == b.active {
+			fg, bg = theme.MenuSelFg, theme.MenuSelBg
+		}
+		x := b.titleX[i]
+		w := b.width - x
+		n := cv.DrawText(x, 0, w, " ", fg, bg)
+		n += drawMenuLabel(cv, x+n, 0, w-n, m.Title, fg, bg)
+		cv.DrawText(x+n, 0, w-n, " ", fg, bg)
+	}
+}
+
+// A menuPopup is a window displaying the items of an open menu.
+type menuPopup struct {
+	menu   *Menu
+	parent *menuPopup // menu that opened this one as a submenu, or nil
+	child  *menuPopup // open submenu, or nil
+	bar    *MenuBar   // menu bar that opened the menu, or nil
+	corner coord      // screen coordinate of top-left corner
+	size   coord      // screen dimensions of the popup
+	sel    int        // index of the selected item, or -1
+	dirty  bool       // popup needs to be redrawn
+}
+
+// openMenu displays a menu as a modal popup near the screen position (x, y).
+func openMenu(m *Menu, x, y int, parent *menuPopup, bar *MenuBar) *menuPopup {
+	p := &menuPopup{
+		menu:   m,
+		parent: parent,
+		bar:    bar,
+		sel:    -1,
+		dirty:  true,
+	}
+
+	label, shortcut, arrow := 0, 0, 0
+	for _, it := range m.Items {
+		label = max(label, menuLabelLen(it.Label))
+		shortcut = max(shortcut, textWidth(it.Shortcut))
+		if it.Submenu != nil {
+			arrow = 2
+		}
+	}
+	if shortcut > 0 {
+		shortcut += 2
+	}
+	p.size = coord{label + shortcut + arrow + 6, len(m.Items) + 2}
+
+	sw, sh := tb.Size()
+	if parent != nil && x+p.size.x > sw {
+		x = parent.corner.x - p.size.x
+	}
+	x = max(min(x, sw-p.size.x), 0)
+	y = max(min(y, sh-p.size.y), 0)
+	p.corner = coord{x, y}
+
+	if parent != nil {
+		parent.child = p
+	}
+	ShowModal(p)
+	return p
+}
+
+// closeMenus closes every open menu.
+func closeMenus() {
+	for {
+		p, ok := topModal().(*menuPopup)
+		if !ok {
+			return
+		}
+		p.close()
+	}
+}
+
+// root returns the top-level menu of a chain of submenus.
+func (p *menuPopup) root() *menuPopup {
+	for p.parent != nil {
+		p = p.parent
+	}
+	return p
+}
+
+// close closes a menu and any submenus it has open.
+func (p *menuPopup) close() {
+	if p.child != nil {
+		p.child.close()
+	}
+	EndModal(p)
+	if p.parent != nil {
+		p.parent.child = nil
+	} else if p.bar != nil {
+		p.bar.active = -1
+		p.bar.dirty = true
+	}
+}
+
+// choose activates the i-th item of the menu, opening its submenu or
+// closing all menus and calling its action.
+func (p *menuPopup) choose(i int, keyboard bool) {
+	if i < 0 || i >= len(p.menu.Items) {
+		return
+	}
+	it := p.menu.Items[i]
+	if it.separator || it.Disabled {
+		return
+	}
+	p.setSel(i)
+
+	if it.Submenu != nil {
+		if p.child != nil {
+			if p.child.menu == it.Submenu {
+				return
+			}
+			p.child.close()
+		}
+		child := openMenu(it.Submenu, p.corner.x+p.size.x, p.corner.y+i, p, p.bar)
+		if keyboard {
+			child.selectNext(0, +1)
+		}
+		return
+	}
+
+	closeMenus()
+	if it.Checkable {
+		it.Checked = !it.Checked
+	}
+	if it.Action != nil {
+		it.Action()
+	}
+}
+
+// selectNext selects the first item that is not a separator, starting at
+// index i and moving in direction dir.
+func (p *menuPopup) selectNext(i, dir int) {
+	n := len(p.menu.Items)
+	for j := 0; j < n; j++ {
+		k := ((i+dir*j)%n + n) % n
+		if !p.menu.Items[k].separator {
+			p.setSel(k)
+			return
+		}
+	}
+}
+
+func (p *menuPopup) setSel(i int) {
+	if i != p.sel {
+		p.sel = i
+		p.dirty = true
+	}
+}
+
+// itemFor returns the index of the item whose accelerator is ch, or -1.
+func (p *menuPopup) itemFor(ch rune) int {
+	ch = unicode.ToLower(ch)
+	for i, it := range p.menu.Items {
+		if it.separator {
+			continue
+		}
+		if _, accel, _ := parseMenuLabel(it.Label); accel == ch {
+			return i
+		}
+	}
+	return -1
+}
+
+// dismiss closes menus when the user clicks outside them. A click on a
+// parent menu or on the menu bar is passed on to it.
+func (p *menuPopup) dismiss(ev tb.Event) error {
+	for q := p.parent; q != nil; q = q.parent {
+		if contains(q.bounds(), ev.MouseX, ev.MouseY) {
+			q.child.close()
+			return handleMouse(ev)
+		}
+	}
+
+	bar := p.root().bar
+	closeMenus()
+	if bar != nil && contains(bar.bounds(), ev.MouseX, ev.MouseY) {
+		if i := bar.titleAt(ev.MouseX); i >= 0 && i != p.root().barIndex() {
+			return handleMouse(ev)
+		}
+	}
+	return nil
+}
+
+// barIndex returns the index of the menu within its menu bar, or -1.
+func (p *menuPopup) barIndex() int {
+	if p.bar != nil {
+		for i, m := range p.bar.menus {
+			if m == p.menu {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func (p *menuPopup) bounds() rect {
+	return newRect(p.corner.x, p.corner.y, p.size.x, p.size.y)
+}
+
+func (p *menuPopup) dirtyRect() rect {
+	if p.dirty {
+		return p.bounds()
+	}
+	return emptyRect
+}
+
+func (p *menuPopup) invalidate() {
+	p.dirty = true
+}
+
+func (p *menuPopup) move(x, y int) {
+	damage(p.bounds())
+	p.corner = coord{x, y}
+	p.dirty = true
+}
+
+func (p *menuPopup) resize(width, height int) {
+}
+
+func (p *menuPopup) getCursor() (x, y int, show bool) {
+	return 0, 0, false
+}
+
+func (p *menuPopup) onKey(ev tb.Event) error {
+	switch ev.Key {
+	case tb.KeyArrowUp:
+		p.selectNext(p.sel-1, -1)
+	case tb.KeyArrowDown:
+		p.selectNext(p.sel+1, +1)
+	case tb.KeyHome:
+		p.selectNext(0, +1)
+	case tb.KeyEnd:
+		p.selectNext(len(p.menu.Items)-1, -1)
+	case tb.KeyEnter, tb.KeySpace:
+		p.choose(p.sel, true)
+	case tb.KeyArrowRight:
+		if p.sel >= 0 && p.menu.Items[p.sel].Submenu != nil && !p.menu.Items[p.sel].Disabled {
+			p.choose(p.sel, true)
+		} else if p.bar != nil {
+			p.bar.openAdjacent(+1)
+		}
+	case tb.KeyArrowLeft:
+		switch {
+		case p.parent != nil:
+			p.close()
+		case p.bar != nil:
+			p.bar.openAdjacent(-1)
+		}
+	case tb.KeyEsc:
+		if p.parent != nil {
+			p.close()
+		} else {
+			closeMenus()
+		}
+	case tb.KeyF10:
+		closeMenus()
+	default:
+		if ev.Ch == 0 {
+			break
+		}
+		if (ev.Mod&tb.ModAlt) != 0 && p.bar != nil {
+			if i := p.bar.menuFor(ev.Ch); i >= 0 {
+				p.bar.Open(i)
+				break
+			}
+		}
+		p.choose(p.itemFor(ev.Ch), true)
+	}
+	return nil
+}
+
+func (p *menuPopup) onMouse(ev tb.Event) error {
+	if ev.Key != tb.MouseLeft {
+		return nil
+	}
+	i := ev.MouseY - p.corner.y - 1
+	if i < 0 || i >= len(p.menu.Items) || !contains(p.bounds(), ev.MouseX, ev.MouseY) {
+		return nil
+	}
+	if (ev.Mod & tb.ModMotion) != 0 {
+		if !p.menu.Items[i].separator {
+			p.setSel(i)
+		}
+		return nil
+	}
+	p.choose(i, false)
+	return nil
+}
+
+func (p *menuPopup) onDraw(cv *Canvas) {
+	if !p.dirty {
+		return
+	}
+	p.dirty = false
+
+	cv.Clear(theme.MenuFg, theme.MenuBg)
+	cv.Box(0, 0, p.size.x, p.size.y, theme.MenuFg, theme.MenuBg)
+	inner := p.size.x - 2
+	for i, it := range p.menu.Items {
+		y := 1 + i
+		if it.separator {
+			cv.HLine(0, y, p.size.x, theme.MenuFg, theme.MenuBg)
+			continue
+		}
+
+		fg, bg := theme.MenuFg, theme.MenuBg
+		switch {
+		case i == p.sel:
+			fg, bg = theme.MenuSelFg, theme.MenuSelBg
+		case it.Disabled:
+			fg = theme.MenuDisabledFg
+		}
+
+		x := 1
+		cv.Fill(x, y, inner, 1, charSpace, fg, bg)
+		if it.Checked {
+			cv.SetCell(x+1, y, charCheck, fg, bg)
+		}
+		drawMenuLabel(cv, x+3, y, inner-3, it.Label, fg, bg)
+
+		right := x + inner - 1
+		if it.Submenu != nil {
+			cv.SetCell(right-1, y, charSubmenu, fg, bg)
+			right -= 2
+		}
+		if it.Shortcut != "" {
+			n := textWidth(it.Shortcut)
+			cv.DrawText(right-n, y, n, it.Shortcut, fg, bg)
+		}
+	}
+}
+
+// parseMenuLabel removes the accelerator marker from a menu label. It
+// returns the text to display, the lower-cased accelerator key and the
+// position of the accelerator within the text, or -1 if the label has no
+// marker and the first character is used as the accelerator.
+func parseMenuLabel(s string) (text string, accel rune, pos int) {
+	var buf strings.Builder
+	pos = -1
+	marked := false
+	n := 0
+	for _, ch := range s {
+		switch {
+		case marked:
+			marked = false
+			if ch != menuAccelMarker && pos < 0 {
+				pos, accel = n, unicode.ToLower(ch)
+			}
+		case ch == menuAccelMarker:
+			marked = true
+			continue
+		}
+		buf.WriteRune(ch)
+		n++
+	}
+
+	text = buf.String()
+	if pos < 0 {
+		if ch, _ := utf8.DecodeRuneInString(text); ch != utf8.RuneError {
+			accel = unicode.ToLower(ch)
+		}
+	}
+	return text, accel, pos
+}
+
+// menuLabelLen returns the number of characters displayed for a menu label.
+func menuLabelLen(s string) int {
+	text, _, _ := parseMenuLabel(s)
+	return textWidth(text)
+}
+
+// drawMenuLabel draws a menu label on a canvas with its accelerator key
+// underlined and returns the number of columns drawn.
+func drawMenuLabel(cv *Canvas, x, y, width int, s string, fg, bg tb.Attribute) int {
+	text, _, pos := parseMenuLabel(s)
+	n := cv.DrawText(x, y, width, text, fg, bg)
+	if pos < 0 {
+		return n
+	}
+
+	// Don't underline a key hidden by truncation.
+	visible := n
+	if textWidth(text) > width {
+		visible--
+	}
+	r := []rune(text)
+	col := textWidth(string(r[:pos]))
+	if col+textWidth(string(r[pos])) <= visible {
+		cv.SetCell(x+col, y, r[pos], fg|tb.AttrUnderline, bg)
+	}
+	return n
+}
@@ -0,0 +1,703 @@
+package termwin
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	runewidth "github.com/mattn/go-runewidth"
+	tb "github.com/nsf/termbox-go"
+)
+
+const (
+	// pagerBatchLines is the maximum number of lines a pager's reader
+	// collects before passing them to the pager.
+	pagerBatchLines = 256
+
+	// pagerSearchLines is the number of lines a pager reads ahead at a time
+	// while searching forward for a pattern.
+	pagerSearchLines = 4096
+)
+
+// PagerFlags define settings for a Pager.
+type PagerFlags byte
+
+const (
+	// PagerANSI interprets ANSI escape sequences in the text, displaying the
+	// colors and attributes selected by SGR sequences and removing all other
+	// sequences. Without it, control characters are shown in caret
+	// notation, such as "^[" for escape.
+	PagerANSI PagerFlags = 1 << iota
+
+	// PagerLineNumbers displays a line number before each line.
+	PagerLineNumbers
+)
+
+// A Pager is a read-only window displaying text from an io.Reader, in the
+// manner of the less command. Lines are read on a separate goroutine, and
+// only as far as needed to display or search them, so a pager may display
+// very large or unending streams. The bottom row of the pager is a status
+// line showing the position in the text, messages and prompts.
+//
+// The pager is controlled with these keys, several of which accept a count
+// typed as a number before them:
+//
+//	j k, Up Down, Enter     scroll down or up one line (count lines)
+//	Space f, PgDn PgUp b    scroll down or up one page
+//	d u                     scroll down or up half a page
+//	Left Right              scroll left or right half a page
+//	g Home, G End           go to the first or last line (line count)
+//	% p                     go to count percent of the text
+//	/ ?                     search forward or backward for a regexp
+//	n N                     repeat the search in the same or other direction
+//	#                       toggle line numbers
+//	F                       follow new lines as they are read; any key stops
+//	q                       call the function set by OnQuit
+type Pager struct {
+	corner    coord          // screen coordinate of top-left corner
+	size      coord          // screen dimensions of the pager
+	flags     PagerFlags     // pager settings
+	reader    io.Reader      // source of the text
+	feed      *pagerFeed     // state shared with the reading goroutine
+	ansi      *ansiDecoder   // decoder of escape sequences, if enabled
+	lines     [][]tb.Cell    // lines read so far, one cell per column
+	requested int            // number of lines requested from the reader
+	eof       bool           // all lines have been read
+	err       error          // error reading the text, other than EOF
+	top       int            // index of the first visible line
+	left      int            // first visible column
+	follow    bool           // view follows new lines
+	waiting   func() bool    // command waiting for more lines, or nil
+	re        *regexp.Regexp // search pattern, or nil
+	backward  bool           // last search was backward
+	prompt    rune           // prompt character while a pattern is typed, or 0
+	input     []rune         // pattern typed at the prompt
+	count     string         // count typed before a command
+	message   string         // message displayed on the status line
+	dirty     bool           // pager needs to be redrawn
+	onQuit    func()         // called when q is pressed
+}
+
+// A pagerFeed is the state shared by a pager and the goroutine reading its
+// lines.
+type pagerFeed struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	want   int  // total number of lines requested by the pager
+	closed bool // the pager was closed
+}
+
+// NewPager creates a new Pager with the specified screen position and size,
+// displaying the text read from r.
+func NewPager(x, y, width, height int, r io.Reader, flags PagerFlags) *Pager {
+	p := &Pager{
+		corner: coord{x, y},
+		size:   coord{width, height},
+		flags:  flags,
+		reader: r,
+		feed:   new(pagerFeed),
+		dirty:  true,
+	}
+	p.feed.cond = sync.NewCond(&p.feed.mu)
+	if (flags & PagerANSI) != 0 {
+		p.ansi = new(ansiDecoder)
+	}
+	go p.read()
+	p.need(2 * p.rows())
+	addWindow(p)
+	return p
+}
+
+// Close stops reading the text, closing the reader if it is an io.Closer.
+func (p *Pager) Close() {
+	p.feed.mu.Lock()
+	closed := p.feed.closed
+	p.feed.closed = true
+	p.feed.mu.Unlock()
+	p.feed.cond.Signal()
+	if c, ok := p.reader.(io.Closer); ok && !closed {
+		c.Close()
+	}
+}
+
+// OnQuit sets the function called when the user presses q.
+func (p *Pager) OnQuit(f func()) {
+	p.onQuit = f
+}
+
+// LineNumbers returns true if line numbers are displayed.
+func (p *Pager) LineNumbers() bool {
+	return (p.flags & PagerLineNumbers) != 0
+}
+
+// SetLineNumbers shows or hides the line numbers.
+func (p *Pager) SetLineNumbers(on bool) {
+	if on {
+		p.flags |= PagerLineNumbers
+	} else {
+		p.flags &^= PagerLineNumbers
+	}
+	p.dirty = true
+}
+
+// Lines returns the number of lines read so far, and true if the entire
+// text has been read.
+func (p *Pager) Lines() (n int, all bool) {
+	return len(p.lines), p.eof
+}
+
+// Err returns the error that stopped the reading of the text, if any.
+func (p *Pager) Err() error {
+	return p.err
+}
+
+// Top returns the index of the first visible line.
+func (p *Pager) Top() int {
+	return p.top
+}
+
+// GotoLine scrolls the pager so the line with the given index, starting at
+// 0, is at the top. The line is read first if necessary.
+func (p *Pager) GotoLine(i int) {
+	i = max(i, 0)
+	p.await(func() bool {
+		if i >= len(p.lines) && !p.eof {
+			p.need(i + p.rows())
+			return false
+		}
+		p.scrollTo(i)
+		return true
+	})
+}
+
+// Search compiles a regular expression and scrolls the pager to the next
+// line matching it, searching forward or backward from the top line.
+// Matches of the expression are highlighted until the next search.
+func (p *Pager) Search(pattern string, backward bool) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	p.re, p.backward = re, backward
+	p.find(p.top, backward)
+	return nil
+}
+
+// read reads the lines requested by the pager, passing them to the pager
+// in batches through Post. It runs on its own goroutine.
+func (p *Pager) read() {
+	f := p.feed
+	br := bufio.NewReader(p.reader)
+	var batch [][]byte
+	for n := 0; ; {
+		f.mu.Lock()
+		for n >= f.want && !f.closed {
+			f.cond.Wait()
+		}
+		want, closed := f.want, f.closed
+		f.mu.Unlock()
+		if closed {
+			return
+		}
+
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			batch = append(batch, line)
+			n++
+		}
+		// Pass the batch on before any read that may block.
+		if err != nil || n >= want || len(batch) >= pagerBatchLines || br.Buffered() == 0 {
+			lines := batch
+			batch = nil
+			Post(func() { p.addLines(lines, err) })
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// need asks the reader for the first n lines of the text.
+func (p *Pager) need(n int) {
+	if p.eof || n <= p.requested {
+		return
+	}
+	p.requested = n
+	p.feed.mu.Lock()
+	p.feed.want = n
+	p.feed.mu.Unlock()
+	p.feed.cond.Signal()
+}
+
+// addLines adds a batch of lines read from the text. A non-nil error ends
+// the text.
+func (p *Pager) addLines(lines [][]byte, err error) {
+	for _, b := range lines {
+		p.lines = append(p.lines, p.decodeLine(b))
+	}
+	if err != nil {
+		p.eof = true
+		if err != io.EOF {
+			p.err = err
+			logger.Warn("pager read failed", "err", err)
+		}
+		p.top = min(p.top, p.maxTop())
+	}
+	if p.follow {
+		p.top = p.maxTop()
+		p.need(math.MaxInt)
+	}
+	if p.waiting != nil && p.waiting() {
+		p.waiting = nil
+	}
+	p.dirty = true
+}
+
+// await runs a command that may need more lines than have been read. The
+// command returns false if it must be run again when more lines arrive.
+func (p *Pager) await(f func() bool) {
+	p.waiting = nil
+	if !f() {
+		p.waiting = f
+		p.dirty = true
+	}
+}
+
+// decodeLine converts a line of text into cells, one per screen column.
+// The second column of a double-width character holds a cell with a zero
+// character.
+func (p *Pager) decodeLine(b []byte) []tb.Cell {
+	b = bytes.TrimSuffix(b, []byte{'\n'})
+	b = bytes.TrimSuffix(b, []byte{'\r'})
+
+	var in []tb.Cell
+	if p.ansi != nil {
+		in = p.ansi.decode(b)
+	} else {
+		in = make([]tb.Cell, 0, len(b))
+		for len(b) > 0 {
+			r, n := utf8.DecodeRune(b)
+			b = b[n:]
+			in = append(in, tb.Cell{Ch: r})
+		}
+	}
+
+	out := make([]tb.Cell, 0, len(in))
+	for _, c := range in {
+		switch w := runewidth.RuneWidth(c.Ch); {
+		case c.Ch == '\t':
+			for n := tabWidth - len(out)%tabWidth; n > 0; n-- {
+				out = append(out, tb.Cell{Ch: charSpace, Fg: c.Fg, Bg: c.Bg})
+			}
+		case c.Ch < 32 || c.Ch == 0x7f:
+			if p.ansi == nil {
+				out = append(out, tb.Cell{Ch: '^'}, tb.Cell{Ch: c.Ch ^ 0x40})
+			}
+		case w == 0:
+			// Combining characters cannot be displayed in a cell of
+			// their own.
+		case w == 2:
+			out = append(out, c, tb.Cell{Fg: c.Fg, Bg: c.Bg})
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// lineText returns the text of a line of cells and, for each byte of the
+// text, the column where it is displayed. The final entry holds the column
+// following the text.
+func lineText(cells []tb.Cell) (string, []int) {
+	var b strings.Builder
+	cols := make([]int, 0, len(cells)+1)
+	for x, c := range cells {
+		if c.Ch == 0 {
+			continue
+		}
+		n, _ := b.WriteRune(c.Ch)
+		for ; n > 0; n-- {
+			cols = append(cols, x)
+		}
+	}
+	return b.String(), append(cols, len(cells))
+}
+
+// rows returns the number of rows displaying text, above the status line.
+func (p *Pager) rows() int {
+	return max(p.size.y-1, 1)
+}
+
+// maxTop returns the index of the top line when the last line read is at
+// the bottom of the pager.
+func (p *Pager) maxTop() int {
+	return max(len(p.lines)-p.rows(), 0)
+}
+
+// scrollTo makes a line the top line. Before the end of the text has been
+// read, the top line may be beyond the last line read, in which case more
+// lines are requested. The next page is always read ahead.
+func (p *Pager) scrollTo(top int) {
+	if p.eof {
+		top = min(top, p.maxTop())
+	} else {
+		top = min(top, max(len(p.lines)-1, 0))
+	}
+	p.top = max(top, 0)
+	p.need(p.top + 2*p.rows())
+	p.dirty = true
+}
+
+// scrollBy scrolls the pager down, or up for a negative count, by a number
+// of lines.
+func (p *Pager) scrollBy(n int) {
+	p.scrollTo(p.top + n)
+}
+
+// scrollRight scrolls the pager right, or left for a negative count, by a
+// number of columns. The pager cannot be scrolled past the end of the
+// longest visible line.
+func (p *Pager) scrollRight(n int) {
+	longest := 0
+	for _, line := range p.lines[min(p.top, len(p.lines)):min(p.top+p.rows(), len(p.lines))] {
+		longest = max(longest, len(line))
+	}
+	p.left = max(min(p.left+n, longest-p.textWidth()), 0)
+	p.dirty = true
+}
+
+// gotoEnd scrolls to the end of the text once it has been read entirely.
+func (p *Pager) gotoEnd() {
+	p.await(func() bool {
+		if !p.eof {
+			p.need(math.MaxInt)
+			return false
+		}
+		p.scrollTo(p.maxTop())
+		return true
+	})
+}
+
+// gotoPercent scrolls to a percentage of the text once it has been read
+// entirely.
+func (p *Pager) gotoPercent(pct int) {
+	pct = min(max(pct, 0), 100)
+	p.await(func() bool {
+		if !p.eof {
+			p.need(math.MaxInt)
+			return false
+		}
+		p.scrollTo(len(p.lines) * pct / 100)
+		return true
+	})
+}
+
+// find scrolls to the first line at or after (or at or before, when
+// searching backward) the line with index from that matches the search
+// pattern.
+func (p *Pager) find(from int, backward bool) {
+	if p.re == nil {
+		p.message = "No previous search pattern"
+		return
+	}
+	p.await(func() bool {
+		if backward {
+			for from = min(from, len(p.lines)-1); from >= 0; from-- {
+				if p.matches(from) {
+					p.scrollTo(from)
+					return true
+				}
+			}
+		} else {
+			for from = max(from, 0); from < len(p.lines); from++ {
+				if p.matches(from) {
+					p.scrollTo(from)
+					return true
+				}
+			}
+			if !p.eof {
+				p.need(len(p.lines) + pagerSearchLines)
+				return false
+			}
+		}
+		p.message = "Pattern not found"
+		return true
+	})
+}
+
+// matches returns true if a line matches the search pattern.
+func (p *Pager) matches(i int) bool {
+	s, _ := lineText(p.lines[i])
+	return p.re.MatchString(s)
+}
+
+// takeCount returns the count typed before a command and clears it, or
+// returns def if no count was typed.
+func (p *Pager) takeCount(def int) int {
+	n, err := strconv.Atoi(p.count)
+	p.count = ""
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// gutterWidth returns the width of the line numbers, including the space
+// separating them from the text, or 0 if line numbers are hidden.
+func (p *Pager) gutterWidth() int {
+	if !p.LineNumbers() {
+		return 0
+	}
+	return max(len(strconv.Itoa(p.top+p.rows())), 3) + 1
+}
+
+// textWidth returns the number of columns displaying text.
+func (p *Pager) textWidth() int {
+	return max(p.size.x-p.gutterWidth(), 0)
+}
+
+func (p *Pager) bounds() rect {
+	return newRect(p.corner.x, p.corner.y, p.size.x, p.size.y)
+}
+
+func (p *Pager) dirtyRect() rect {
+	if !p.dirty {
+		return emptyRect
+	}
+	return p.bounds()
+}
+
+func (p *Pager) invalidate() {
+	p.dirty = true
+}
+
+func (p *Pager) move(x, y int) {
+	damage(p.bounds())
+	p.corner = coord{x, y}
+	p.dirty = true
+}
+
+func (p *Pager) resize(width, height int) {
+	damage(p.bounds())
+	p.size = coord{width, height}
+	p.scrollTo(p.top)
+}
+
+// getCursor shows the cursor at the end of a pattern being typed.
+func (p *Pager) getCursor() (x, y int, show bool) {
+	if p.prompt == 0 {
+		return 0, 0, false
+	}
+	x = p.corner.x + min(1+textWidth(string(p.input)), p.size.x-1)
+	return x, p.corner.y + p.size.y - 1, true
+}
+
+func (p *Pager) onKey(ev tb.Event) error {
+	p.message = ""
+	p.dirty = true
+	if p.prompt != 0 {
+		p.onPromptKey(ev)
+		return nil
+	}
+	if p.follow {
+		p.follow = false
+		return nil
+	}
+
+	if ev.Ch >= '0' && ev.Ch <= '9' {
+		p.count += string(ev.Ch)
+		return nil
+	}
+
+	page := p.rows()
+	switch {
+	case ev.Ch == 'j' || ev.Key == tb.KeyArrowDown || ev.Key == tb.KeyEnter:
+		p.scrollBy(p.takeCount(1))
+	case ev.Ch == 'k' || ev.Key == tb.KeyArrowUp:
+		p.scrollBy(-p.takeCount(1))
+	case ev.Ch == 'f' || ev.Key == tb.KeySpace || ev.Key == tb.KeyPgdn:
+		p.scrollBy(p.takeCount(page))
+	case ev.Ch == 'b' || ev.Key == tb.KeyPgup:
+		p.scrollBy(-p.takeCount(page))
+	case ev.Ch == 'd':
+		p.scrollBy(p.takeCount(page / 2))
+	case ev.Ch == 'u':
+		p.scrollBy(-p.takeCount(page / 2))
+	case ev.Key == tb.KeyArrowRight:
+		p.scrollRight(p.textWidth() / 2)
+	case ev.Key == tb.KeyArrowLeft:
+		p.scrollRight(-p.textWidth() / 2)
+	case ev.Ch == 'g' || ev.Key == tb.KeyHome:
+		p.GotoLine(p.takeCount(1) - 1)
+	case ev.Ch == 'G' || ev.Key == tb.KeyEnd:
+		if n := p.takeCount(0); n > 0 {
+			p.GotoLine(n - 1)
+		} else {
+			p.gotoEnd()
+		}
+	case ev.Ch == '%' || ev.Ch == 'p':
+		p.gotoPercent(p.takeCount(0))
+	case ev.Ch == '/' || ev.Ch == '?':
+		p.prompt, p.input = ev.Ch, p.input[:0]
+	case ev.Ch == 'n':
+		p.repeatSearch(p.backward)
+	case ev.Ch == 'N':
+		p.repeatSearch(!p.backward)
+	case ev.Ch == '#':
+		p.SetLineNumbers(!p.LineNumbers())
+	case ev.Ch == 'F':
+		p.follow = true
+		p.top = p.maxTop()
+		p.need(math.MaxInt)
+	case ev.Ch == 'q':
+		if p.onQuit != nil {
+			p.onQuit()
+		}
+	}
+	p.count = ""
+	return nil
+}
+
+// repeatSearch searches for the next match of the last pattern after (or
+// before) the top line.
+func (p *Pager) repeatSearch(backward bool) {
+	if backward {
+		p.find(p.top-1, true)
+	} else {
+		p.find(p.top+1, false)
+	}
+}
+
+// onPromptKey edits the search pattern typed at the prompt. Enter starts
+// the search, repeating the last search if the pattern is empty, and Esc
+// cancels it.
+func (p *Pager) onPromptKey(ev tb.Event) {
+	switch {
+	case ev.Ch != 0:
+		p.input = append(p.input, ev.Ch)
+	case ev.Key == tb.KeySpace:
+		p.input = append(p.input, charSpace)
+	case ev.Key == tb.KeyBackspace || ev.Key == tb.KeyBackspace2:
+		if len(p.input) == 0 {
+			p.prompt = 0
+		} else {
+			p.input = p.input[:len(p.input)-1]
+		}
+	case ev.Key == tb.KeyEsc:
+		p.prompt = 0
+	case ev.Key == tb.KeyEnter:
+		backward := p.prompt == '?'
+		p.prompt = 0
+		if len(p.input) == 0 {
+			p.backward = backward
+			p.repeatSearch(backward)
+		} else if err := p.Search(string(p.input), backward); err != nil {
+			p.message = "Invalid pattern: " + err.Error()
+		}
+	}
+}
+
+func (p *Pager) onMouse(ev tb.Event) error {
+	switch ev.Key {
+	case tb.MouseWheelUp:
+		p.scrollBy(-mouseWheelRows)
+	case tb.MouseWheelDown:
+		p.scrollBy(mouseWheelRows)
+	}
+	return nil
+}
+
+func (p *Pager) onDraw(cv *Canvas) {
+	if !p.dirty {
+		return
+	}
+	p.dirty = false
+
+	cv.Clear(theme.Fg, theme.Bg)
+	gutter, width := p.gutterWidth(), p.textWidth()
+	for y := 0; y < p.rows(); y++ {
+		i := p.top + y
+		if i >= len(p.lines) {
+			if p.eof {
+				cv.SetCell(0, y, '~', theme.DisabledFg, theme.Bg)
+			}
+			continue
+		}
+		if gutter > 0 {
+			num := fmt.Sprintf("%*d", gutter-1, i+1)
+			cv.DrawText(0, y, gutter, num, theme.DisabledFg, theme.Bg)
+		}
+		line := p.lines[i]
+		for x := p.left; x < min(len(line), p.left+width); x++ {
+			c := line[x]
+			if c.Ch == 0 {
+				c.Ch = charSpace
+			}
+			cv.SetCell(gutter+x-p.left, y, c.Ch, c.Fg, c.Bg)
+		}
+		p.drawMatches(cv.sub(newRect(p.corner.x+gutter, p.corner.y+y, width, 1)), line)
+	}
+	p.drawStatus(cv)
+}
+
+// drawMatches highlights the matches of the search pattern on a line.
+func (p *Pager) drawMatches(cv *Canvas, line []tb.Cell) {
+	if p.re == nil {
+		return
+	}
+	s, cols := lineText(line)
+	for _, m := range p.re.FindAllStringIndex(s, -1) {
+		for x := cols[m[0]]; x < cols[m[1]]; x++ {
+			cv.setAttr(x-p.left, 0, tb.AttrReverse)
+		}
+	}
+}
+
+// drawStatus draws the status line, showing the prompt, a message or the
+// position of the view in the text.
+func (p *Pager) drawStatus(cv *Canvas) {
+	y := p.size.y - 1
+	fg, bg := theme.MenuFg, theme.MenuBg
+	cv.Fill(0, y, p.size.x, 1, charSpace, fg, bg)
+
+	var s string
+	switch {
+	case p.prompt != 0:
+		s = string(p.prompt) + string(p.input)
+	case p.message != "":
+		s = p.message
+	case p.follow:
+		s = "Waiting for data... (press any key to stop)"
+	case p.waiting != nil:
+		s = "Reading..."
+	case p.count != "":
+		s = ":" + p.count
+	case p.err != nil:
+		s = "Read error: " + p.err.Error()
+	default:
+		s = p.position()
+	}
+	cv.DrawText(0, y, p.size.x, s, fg, bg)
+}
+
+// position describes the lines shown by the pager, for example
+// "lines 21-40/100 40%".
+func (p *Pager) position() string {
+	first := min(p.top+1, len(p.lines))
+	last := min(p.top+p.rows(), len(p.lines))
+	if !p.eof {
+		return fmt.Sprintf("lines %d-%d/?", first, last)
+	}
+	if last >= len(p.lines) {
+		return fmt.Sprintf("lines %d-%d/%d (END)", first, last, len(p.lines))
+	}
+	pct := last * 100 / max(len(p.lines), 1)
+	return fmt.Sprintf("lines %d-%d/%d %d%%", first, last, len(p.lines), pct)
+}
@@ -0,0 +1,711 @@
+package termwin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	runewidth "github.com/mattn/go-runewidth"
+	tb "github.com/nsf/termbox-go"
+)
+
+// pickerPrompt is the label displayed before a picker's query.
+const pickerPrompt = "> "
+
+// Scores of the fuzzy matching algorithm. Every matched character scores
+// fuzzyScoreMatch plus a bonus depending on its position, and every
+// unmatched character between the first and last match costs a penalty,
+// so compact matches at word boundaries rank first.
+const (
+	fuzzyScoreMatch       = 16
+	fuzzyBonusBoundary    = 8 // match at the start of a word
+	fuzzyBonusCamel       = 7 // match at an upper-case letter after a lower-case one
+	fuzzyBonusConsecutive = 4 // match right after the previous match
+	fuzzyPenaltyGapStart  = 3 // first unmatched character of a gap
+	fuzzyPenaltyGapExtend = 1 // each further unmatched character of a gap
+)
+
+// PickerFlags define settings for a Picker.
+type PickerFlags byte
+
+const (
+	// PickerMultiSelect allows more than one item to be accepted. Tab and
+	// Shift+Tab toggle the selection of the current item.
+	PickerMultiSelect PickerFlags = 1 << iota
+)
+
+// A pickerItem is a candidate held by a Picker.
+type pickerItem struct {
+	text  string // text of the item, without control characters
+	runes []rune // runes of the text, matched against the query
+}
+
+// A pickerMatch is an item matching a Picker's query.
+type pickerMatch struct {
+	item  int // index of the item
+	score int // score of the match; higher scores rank first
+}
+
+// A Picker is a window for choosing items from a list of candidates by
+// typing a fuzzy query, in the manner of fzf. An item matches the query if
+// it contains the characters of every space-separated term of the query in
+// order, though not necessarily adjacent. Matching ignores case unless the
+// query contains an upper-case letter. Matches are ranked by how compact
+// they are and whether they start words, and the matched characters are
+// highlighted. The candidates may be streamed from a channel while the
+// user types, and are ranked as they arrive.
+//
+// A picker created by NewPicker is displayed inline at a fixed position,
+// and may fill the screen of a standalone program. A picker created by
+// NewPickerPopup is framed and displayed modally by Show, and is closed
+// when an item is accepted or the picker is cancelled.
+//
+// Keys typed into the picker edit the query, except for these:
+//
+//	Up Down, Ctrl+P Ctrl+N  move the cursor
+//	PgUp PgDn               move the cursor by a page
+//	Tab Shift+Tab           toggle the selection of the current item and
+//	                        move down or up (multi-select only)
+//	Enter                   accept the selected items, or the current item
+//	                        if none are selected
+//	Esc                     cancel
+type Picker struct {
+	corner   coord                // screen coordinate of top-left corner
+	size     coord                // screen dimensions of the picker
+	title    string               // text displayed in the popup frame
+	popup    bool                 // picker is framed and displayed modally
+	flags    PickerFlags          // picker settings
+	input    *TextInput           // query text input
+	query    string               // query last applied
+	terms    [][]rune             // terms of the query, lower-cased if folding
+	fold     bool                 // matching ignores case
+	items    []pickerItem         // all candidates
+	matches  []pickerMatch        // matching items, best first
+	selected map[int]bool         // indices of selected items
+	current  int                  // index in matches of the item under the cursor
+	top      int                  // index in matches of the first visible row
+	feeding  int                  // number of channels still being read
+	clicked  time.Time            // time of the last mouse click
+	dirty    bool                 // picker needs to be redrawn
+	onAccept func(items []string) // called when items are accepted
+	onCancel func()               // called when the picker is cancelled
+}
+
+// NewPicker creates a new, empty Picker with the specified screen position
+// and size. The query is on the first row, followed by the number of
+// matches and then the list of matching items.
+func NewPicker(x, y, width, height int, flags PickerFlags) *Picker {
+	p := newPicker(flags)
+	p.corner = coord{x, y}
+	p.resize(width, height)
+	addWindow(p)
+	return p
+}
+
+// NewPickerPopup creates a new, empty Picker displayed in a frame with a
+// title. The picker is not displayed until Show is called.
+func NewPickerPopup(title string, flags PickerFlags) *Picker {
+	p := newPicker(flags)
+	p.title = title
+	p.popup = true
+	return p
+}
+
+func newPicker(flags PickerFlags) *Picker {
+	return &Picker{
+		flags:    flags,
+		input:    newTextInput(0, 0, 1),
+		selected: make(map[int]bool),
+		dirty:    true,
+	}
+}
+
+// Show centers a popup picker on the screen and displays it modally, with
+// the focus on its query.
+func (p *Picker) Show() {
+	sw, sh := tb.Size()
+	width := min(max(sw*3/4, 40), sw)
+	height := min(max(sh*2/3, 10), sh)
+	p.corner = coord{(sw - width) / 2, (sh - height) / 2}
+	p.resize(width, height)
+	ShowModal(p)
+}
+
+// OnAccept sets the function called when the user accepts items by
+// pressing Enter or double-clicking an item. It receives the selected
+// items in the order they were added, or the current item if none are
+// selected.
+func (p *Picker) OnAccept(f func(items []string)) {
+	p.onAccept = f
+}
+
+// OnCancel sets the function called when the user cancels the picker by
+// pressing Esc, or by clicking outside a popup picker.
+func (p *Picker) OnCancel(f func()) {
+	p.onCancel = f
+}
+
+// Query returns the text of the query.
+func (p *Picker) Query() string {
+	return p.input.Text()
+}
+
+// SetQuery replaces the text of the query.
+func (p *Picker) SetQuery(s string) {
+	p.input.SetText(s)
+	p.applyQuery()
+}
+
+// SetItems replaces the candidates, clearing the selection.
+func (p *Picker) SetItems(items []string) {
+	p.items, p.matches = nil, nil
+	p.selected = make(map[int]bool)
+	p.current, p.top = 0, 0
+	p.AddItems(items...)
+}
+
+// AddItems adds candidates, ranking those matching the query among the
+// current matches. The cursor stays on the current item.
+func (p *Picker) AddItems(items ...string) {
+	cur := p.currentItem()
+	var added []pickerMatch
+	for _, s := range items {
+		s = sanitizeLine(expandTabs(s))
+		p.items = append(p.items, pickerItem{text: s, runes: []rune(s)})
+		i := len(p.items) - 1
+		if score, ok := p.score(i); ok {
+			added = append(added, pickerMatch{i, score})
+		}
+	}
+	if len(added) == 0 {
+		p.dirty = true
+		return
+	}
+	sort.Slice(added, func(i, j int) bool { return p.less(added[i], added[j]) })
+	p.matches = p.merge(p.matches, added)
+	if cur >= 0 {
+		for i, m := range p.matches {
+			if m.item == cur {
+				p.current = i
+				break
+			}
+		}
+	}
+	p.scrollTo(p.current)
+}
+
+// Feed adds the candidates received from a channel, until the channel is
+// closed. The candidates are added by Poll, and the picker indicates that
+// more are expected until the channel is closed.
+func (p *Picker) Feed(ch <-chan string) {
+	p.feeding++
+	p.dirty = true
+	feedBatches(ch, func(batch []string) {
+		p.AddItems(batch...)
+	}, func() {
+		p.feeding--
+		p.dirty = true
+	})
+}
+
+// Loading returns true while candidates are still being received from a
+// channel passed to Feed.
+func (p *Picker) Loading() bool {
+	return p.feeding > 0
+}
+
+// Counts returns the number of items matching the query and the number of
+// candidates.
+func (p *Picker) Counts() (matches, total int) {
+	return len(p.matches), len(p.items)
+}
+
+// Current returns the item under the cursor, or false if no items match
+// the query.
+func (p *Picker) Current() (string, bool) {
+	if i := p.currentItem(); i >= 0 {
+		return p.items[i].text, true
+	}
+	return "", false
+}
+
+// Selected returns the selected items in the order they were added.
+func (p *Picker) Selected() []string {
+	idx := make([]int, 0, len(p.selected))
+	for i := range p.selected {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	s := make([]string, len(idx))
+	for j, i := range idx {
+		s[j] = p.items[i].text
+	}
+	return s
+}
+
+// accept closes a popup picker and reports the selected items, or the
+// current item if none are selected. It does nothing if there is no item
+// to accept.
+func (p *Picker) accept() {
+	items := p.Selected()
+	if len(items) == 0 {
+		s, ok := p.Current()
+		if !ok {
+			return
+		}
+		items = []string{s}
+	}
+	if p.popup {
+		EndModal(p)
+	}
+	if p.onAccept != nil {
+		p.onAccept(items)
+	}
+}
+
+// cancel closes a popup picker and reports the cancellation.
+func (p *Picker) cancel() {
+	if p.popup {
+		EndModal(p)
+	}
+	if p.onCancel != nil {
+		p.onCancel()
+	}
+}
+
+// dismiss cancels a popup picker when the user clicks outside it.
+func (p *Picker) dismiss(ev tb.Event) error {
+	p.cancel()
+	return nil
+}
+
+// applyQuery splits the query into terms and matches the items against
+// it. When the new query extends the previous one, only the items already
+// matching need to be matched again.
+func (p *Picker) applyQuery() {
+	text := p.input.Text()
+	if text == p.query {
+		return
+	}
+	narrow := p.query != "" && strings.HasPrefix(text, p.query)
+	p.query = text
+	p.fold = strings.IndexFunc(text, unicode.IsUpper) < 0
+	p.terms = p.terms[:0]
+	for _, t := range strings.Fields(text) {
+		if p.fold {
+			t = strings.ToLower(t)
+		}
+		p.terms = append(p.terms, []rune(t))
+	}
+	p.rematch(narrow)
+}
+
+// rematch matches and ranks the items, or only the current matches if
+// narrow is true, and moves the cursor to the best match.
+func (p *Picker) rematch(narrow bool) {
+	var candidates []int
+	if narrow {
+		candidates = make([]int, len(p.matches))
+		for i, m := range p.matches {
+			candidates[i] = m.item
+		}
+	} else {
+		candidates = make([]int, len(p.items))
+		for i := range p.items {
+			candidates[i] = i
+		}
+	}
+
+	p.matches = p.matches[:0]
+	for _, i := range candidates {
+		if score, ok := p.score(i); ok {
+			p.matches = append(p.matches, pickerMatch{i, score})
+		}
+	}
+	sort.Slice(p.matches, func(i, j int) bool { return p.less(p.matches[i], p.matches[j]) })
+	p.current, p.top = 0, 0
+	p.dirty = true
+}
+
+// score returns the score of an item's match of the query, or false if the
+// item does not match.
+func (p *Picker) score(i int) (int, bool) {
+	total := 0
+	for _, t := range p.terms {
+		score, _, ok := fuzzyMatch(p.items[i].runes, t, p.fold)
+		if !ok {
+			return 0, false
+		}
+		total += score
+	}
+	return total, true
+}
+
+// positions returns the indices of the runes of an item matched by the
+// query, in ascending order.
+func (p *Picker) positions(i int) []int {
+	var pos []int
+	for _, t := range p.terms {
+		_, tp, _ := fuzzyMatch(p.items[i].runes, t, p.fold)
+		pos = append(pos, tp...)
+	}
+	sort.Ints(pos)
+	return pos
+}
+
+// less returns true if match a ranks before match b. Higher scores rank
+// first, then shorter items while a query is entered, then earlier items.
+func (p *Picker) less(a, b pickerMatch) bool {
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	if len(p.terms) > 0 {
+		la, lb := len(p.items[a.item].runes), len(p.items[b.item].runes)
+		if la != lb {
+			return la < lb
+		}
+	}
+	return a.item < b.item
+}
+
+// merge merges two ranked lists of matches.
+func (p *Picker) merge(a, b []pickerMatch) []pickerMatch {
+	m := make([]pickerMatch, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if p.less(b[0], a[0]) {
+			m, b = append(m, b[0]), b[1:]
+		} else {
+			m, a = append(m, a[0]), a[1:]
+		}
+	}
+	m = append(m, a...)
+	return append(m, b...)
+}
+
+// fuzzyMatch finds the runes of a pattern in order in text, and returns
+// the score of the match and the indices of the matched runes. If fold is
+// true, the pattern must be lower case and text is compared without
+// regard to case. Like fzf's first algorithm, it finds the first
+// occurrence of the pattern, then shortens the match by searching backward
+// from its end.
+func fuzzyMatch(text, pattern []rune, fold bool) (score int, pos []int, ok bool) {
+	if len(pattern) == 0 {
+		return 0, nil, true
+	}
+	eq := func(i, j int) bool {
+		r := text[i]
+		if fold {
+			r = unicode.ToLower(r)
+		}
+		return r == pattern[j]
+	}
+
+	// Find the end of the first occurrence.
+	j, end := 0, -1
+	for i := range text {
+		if eq(i, j) {
+			if j++; j == len(pattern) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	// Find the latest start of an occurrence ending there.
+	start := end
+	for i, j := end, len(pattern)-1; j >= 0; i-- {
+		if eq(i, j) {
+			start = i
+			j--
+		}
+	}
+
+	pos = make([]int, 0, len(pattern))
+	gap, prev := false, false
+	for i, j := start, 0; i <= end; i++ {
+		if j < len(pattern) && eq(i, j) {
+			bonus := fuzzyBonus(text, i)
+			if prev {
+				bonus = max(bonus, fuzzyBonusConsecutive)
+			}
+			if j == 0 {
+				bonus *= 2
+			}
+			score += fuzzyScoreMatch + bonus
+			pos = append(pos, i)
+			j++
+			gap, prev = false, true
+			continue
+		}
+		if gap {
+			score -= fuzzyPenaltyGapExtend
+		} else {
+			score -= fuzzyPenaltyGapStart
+		}
+		gap, prev = true, false
+	}
+	return score, pos, true
+}
+
+// fuzzyBonus returns the bonus for matching the rune at index i of text,
+// based on the rune before it.
+func fuzzyBonus(text []rune, i int) int {
+	r := text[i]
+	if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+		return 0
+	}
+	if i == 0 {
+		return fuzzyBonusBoundary
+	}
+	prev := text[i-1]
+	switch {
+	case !unicode.IsLetter(prev) && !unicode.IsDigit(prev):
+		return fuzzyBonusBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(r):
+		return fuzzyBonusCamel
+	case unicode.IsDigit(prev) != unicode.IsDigit(r):
+		return fuzzyBonusCamel
+	}
+	return 0
+}
+
+// currentItem returns the index of the item under the cursor, or -1 if no
+// items match.
+func (p *Picker) currentItem() int {
+	if p.current < len(p.matches) {
+		return p.matches[p.current].item
+	}
+	return -1
+}
+
+// multi returns true if the picker allows multiple selected items.
+func (p *Picker) multi() bool {
+	return (p.flags & PickerMultiSelect) != 0
+}
+
+// toggle toggles the selection of the current item and moves the cursor.
+func (p *Picker) toggle(dir int) {
+	i := p.currentItem()
+	if !p.multi() || i < 0 {
+		return
+	}
+	if p.selected[i] {
+		delete(p.selected, i)
+	} else {
+		p.selected[i] = true
+	}
+	p.scrollTo(p.current + dir)
+	p.dirty = true
+}
+
+// frame returns the width of the picker's border.
+func (p *Picker) frame() int {
+	if p.popup {
+		return 1
+	}
+	return 0
+}
+
+// visibleRows returns the number of rows displaying matches, below the
+// query and the match count.
+func (p *Picker) visibleRows() int {
+	return max(p.size.y-2-2*p.frame(), 1)
+}
+
+// scrollTo moves the cursor to a match, scrolling the list if necessary.
+func (p *Picker) scrollTo(i int) {
+	p.current = min(max(i, 0), max(len(p.matches)-1, 0))
+	n := p.visibleRows()
+	switch {
+	case p.current < p.top:
+		p.top = p.current
+	case p.current >= p.top+n:
+		p.top = p.current - n + 1
+	}
+	p.top = max(min(p.top, len(p.matches)-n), 0)
+	p.dirty = true
+}
+
+// colors returns the colors of the picker's body.
+func (p *Picker) colors() (fg, bg tb.Attribute) {
+	if p.popup {
+		return theme.DialogFg, theme.DialogBg
+	}
+	return theme.Fg, theme.Bg
+}
+
+func (p *Picker) focusedChild() Window {
+	return p.input
+}
+
+func (p *Picker) bounds() rect {
+	return newRect(p.corner.x, p.corner.y, p.size.x, p.size.y)
+}
+
+func (p *Picker) dirtyRect() rect {
+	if p.dirty {
+		return p.bounds()
+	}
+	return p.input.dirtyRect()
+}
+
+func (p *Picker) invalidate() {
+	p.dirty = true
+	p.input.invalidate()
+}
+
+func (p *Picker) move(x, y int) {
+	damage(p.bounds())
+	p.corner = coord{x, y}
+	f := p.frame()
+	p.input.move(x+f+len(pickerPrompt), y+f)
+	p.dirty = true
+}
+
+func (p *Picker) resize(width, height int) {
+	damage(p.bounds())
+	p.size = coord{width, height}
+	f := p.frame()
+	p.input.move(p.corner.x+f+len(pickerPrompt), p.corner.y+f)
+	p.input.resize(max(width-2*f-len(pickerPrompt), 0), 1)
+	p.scrollTo(p.current)
+}
+
+func (p *Picker) getCursor() (x, y int, show bool) {
+	return p.input.getCursor()
+}
+
+func (p *Picker) onKey(ev tb.Event) error {
+	page := p.visibleRows()
+	switch {
+	case ev.Key == tb.KeyArrowUp || ev.Key == tb.KeyCtrlP:
+		p.scrollTo(p.current - 1)
+	case ev.Key == tb.KeyArrowDown || ev.Key == tb.KeyCtrlN:
+		p.scrollTo(p.current + 1)
+	case ev.Key == tb.KeyPgup:
+		p.scrollTo(p.current - page)
+	case ev.Key == tb.KeyPgdn:
+		p.scrollTo(p.current + page)
+	case ev.Key == tb.KeyTab && (ev.Mod&tb.ModShift) != 0:
+		p.toggle(-1)
+	case ev.Key == tb.KeyTab:
+		p.toggle(+1)
+	case ev.Key == tb.KeyEnter:
+		p.accept()
+	case ev.Key == tb.KeyEsc:
+		p.cancel()
+	default:
+		err := p.input.onKey(ev)
+		p.applyQuery()
+		return err
+	}
+	return nil
+}
+
+func (p *Picker) onMouse(ev tb.Event) error {
+	f := p.frame()
+	switch ev.Key {
+	case tb.MouseWheelUp:
+		p.scrollTo(p.current - mouseWheelRows)
+	case tb.MouseWheelDown:
+		p.scrollTo(p.current + mouseWheelRows)
+	case tb.MouseLeft:
+		if ev.MouseY == p.corner.y+f {
+			return p.input.onMouse(ev)
+		}
+		i := p.top + ev.MouseY - (p.corner.y + f + 2)
+		if i < p.top || i >= len(p.matches) {
+			return nil
+		}
+		p.scrollTo(i)
+		if (ev.Mod & tb.ModMotion) != 0 {
+			return nil
+		}
+		now := time.Now()
+		double := now.Sub(p.clicked) < doubleClickTime
+		p.clicked = now
+		if double {
+			p.accept()
+		}
+	}
+	return nil
+}
+
+func (p *Picker) onDraw(cv *Canvas) {
+	if p.dirty {
+		p.dirty = false
+		p.draw(cv)
+		p.input.invalidate()
+	}
+	p.input.onDraw(cv.sub(p.input.bounds()))
+}
+
+// draw draws the frame of a popup picker, the prompt, the match count and
+// the matching items.
+func (p *Picker) draw(cv *Canvas) {
+	fg, bg := p.colors()
+	cv.Clear(fg, bg)
+	f := p.frame()
+	if p.popup {
+		cv.Box(0, 0, p.size.x, p.size.y, fg, bg)
+		if p.title != "" {
+			title := " " + p.title + " "
+			cv.DrawText(max((p.size.x-textWidth(title))/2, 1), 0, p.size.x-2, title, fg|tb.AttrBold, bg)
+		}
+	}
+	width := p.size.x - 2*f
+	cv.DrawText(f, f, width, pickerPrompt, fg|tb.AttrBold, bg)
+
+	info := fmt.Sprintf("  %d/%d", len(p.matches), len(p.items))
+	if p.feeding > 0 {
+		info += string(charEllipsis)
+	}
+	if len(p.selected) > 0 {
+		info += fmt.Sprintf(" (%d)", len(p.selected))
+	}
+	w := cv.DrawText(f, f+1, width, info, theme.DisabledFg, bg)
+	cv.Fill(f+w+1, f+1, width-w-1, 1, boxHorizontal, theme.DisabledFg, bg)
+
+	for y := 0; y < p.visibleRows() && p.top+y < len(p.matches); y++ {
+		p.drawRow(cv, f, f+2+y, width, p.top+y)
+	}
+}
+
+// drawRow draws a matching item, marking the cursor and the selection and
+// highlighting the characters matched by the query.
+func (p *Picker) drawRow(cv *Canvas, x, y, width, i int) {
+	fg, bg := p.colors()
+	if i == p.current {
+		fg, bg = theme.FocusFg, theme.FocusBg
+	}
+	item := p.matches[i].item
+	cv.Fill(x, y, width, 1, charSpace, fg, bg)
+	if i == p.current {
+		cv.SetCell(x, y, '>', fg|tb.AttrBold, bg)
+	}
+	if p.selected[item] {
+		cv.SetCell(x+1, y, '*', fg|tb.AttrBold, bg)
+	}
+
+	x, width = x+2, width-2
+	text, runes := p.items[item].text, p.items[item].runes
+	cv.DrawText(x, y, width, text, fg, bg)
+	if textWidth(text) > width {
+		width-- // leave the ellipsis unhighlighted
+	}
+	col, k := 0, 0
+	for _, pos := range p.positions(item) {
+		for ; k < pos; k++ {
+			col += runewidth.RuneWidth(runes[k])
+		}
+		if col >= width {
+			break
+		}
+		cv.setAttr(x+col, y, tb.AttrBold|tb.AttrUnderline)
+	}
+}
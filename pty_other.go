@@ -0,0 +1,20 @@
+//go:build !linux
+
+package termwin
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// startPty reports that pseudo-terminals are not supported on this
+// platform.
+func startPty(cmd *exec.Cmd, width, height int) (*os.File, error) {
+	return nil, errors.New("termwin: pseudo-terminals are not supported on this platform")
+}
+
+// setPtySize does nothing on platforms without pseudo-terminal support.
+func setPtySize(f *os.File, width, height int) error {
+	return nil
+}
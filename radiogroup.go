@@ -0,0 +1,152 @@
+package termwin
+
+import (
+	"unicode/utf8"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+const (
+	radioOn  = "(•) "
+	radioOff = "( ) "
+)
+
+// A RadioGroup is a vertical list of mutually exclusive options, exactly
+// one of which is selected. The arrow keys select the previous or next
+// option, and clicking an option selects it.
+type RadioGroup struct {
+	corner   coord            // screen coordinate of top-left corner
+	options  []string         // option labels
+	selected int              // index of the selected option
+	onChange func(option int) // called when the user selects an option
+	focused  bool             // group had the focus when last drawn
+	dirty    bool             // group needs to be redrawn
+}
+
+// NewRadioGroup creates a new RadioGroup control at the specified screen
+// position. The first option is selected.
+func NewRadioGroup(x, y int, options ...string) *RadioGroup {
+	r := &RadioGroup{
+		corner: coord{x, y},
+		dirty:  true,
+	}
+	for _, o := range options {
+		r.options = append(r.options, sanitizeLine(o))
+	}
+	addWindow(r)
+	return r
+}
+
+// Selected returns the index of the selected option.
+func (r *RadioGroup) Selected() int {
+	return r.selected
+}
+
+// SetSelected selects an option without calling the change function.
+func (r *RadioGroup) SetSelected(option int) {
+	option = min(max(option, 0), max(len(r.options)-1, 0))
+	if option != r.selected {
+		r.selected = option
+		r.dirty = true
+	}
+}
+
+// Value returns the label of the selected option, or the empty string if
+// the group has no options.
+func (r *RadioGroup) Value() string {
+	if r.selected >= len(r.options) {
+		return ""
+	}
+	return r.options[r.selected]
+}
+
+// OnChange sets the function called when the user selects an option.
+func (r *RadioGroup) OnChange(f func(option int)) {
+	r.onChange = f
+}
+
+// choose selects an option at the user's request.
+func (r *RadioGroup) choose(option int) {
+	prev := r.selected
+	r.SetSelected(option)
+	if r.selected != prev && r.onChange != nil {
+		r.onChange(r.selected)
+	}
+}
+
+func (r *RadioGroup) bounds() rect {
+	width := 0
+	for _, o := range r.options {
+		width = max(width, utf8.RuneCountInString(o))
+	}
+	return newRect(r.corner.x, r.corner.y, width+4, len(r.options))
+}
+
+func (r *RadioGroup) dirtyRect() rect {
+	if !r.dirty && r.focused == isFocused(r) {
+		return emptyRect
+	}
+	return r.bounds()
+}
+
+func (r *RadioGroup) invalidate() {
+	r.dirty = true
+}
+
+func (r *RadioGroup) move(x, y int) {
+	damage(r.bounds())
+	r.corner = coord{x, y}
+	r.dirty = true
+}
+
+// resize does nothing, since a radio group sizes itself to fit its options.
+func (r *RadioGroup) resize(width, height int) {
+}
+
+func (r *RadioGroup) getCursor() (x, y int, show bool) {
+	return r.corner.x + 1, r.corner.y + r.selected, isFocused(r)
+}
+
+func (r *RadioGroup) onKey(ev tb.Event) error {
+	switch ev.Key {
+	case tb.KeyArrowUp, tb.KeyArrowLeft:
+		r.choose(r.selected - 1)
+	case tb.KeyArrowDown, tb.KeyArrowRight:
+		r.choose(r.selected + 1)
+	case tb.KeyHome:
+		r.choose(0)
+	case tb.KeyEnd:
+		r.choose(len(r.options) - 1)
+	}
+	return nil
+}
+
+func (r *RadioGroup) onMouse(ev tb.Event) error {
+	if ev.Key == tb.MouseLeft && (ev.Mod&tb.ModMotion) == 0 {
+		if i := ev.MouseY - r.corner.y; i >= 0 && i < len(r.options) {
+			r.choose(i)
+		}
+	}
+	return nil
+}
+
+func (r *RadioGroup) onDraw(cv *Canvas) {
+	if r.dirtyRect().empty() {
+		return
+	}
+	r.dirty = false
+	r.focused = isFocused(r)
+
+	width := r.bounds().x1 - r.corner.x
+	for i, o := range r.options {
+		box := radioOff
+		fg, bg := theme.Fg, theme.Bg
+		if i == r.selected {
+			box = radioOn
+			fg, bg = focusColors(r.focused)
+		}
+		n := cv.DrawText(0, i, width, box, theme.Fg, theme.Bg)
+		n += cv.DrawText(n, i, width-n, o, fg, bg)
+		cv.Fill(n, i, width-n, 1, charSpace, theme.Fg, theme.Bg)
+	}
+}
@@ -2,6 +2,7 @@ package termwin
 
 import (
 	"errors"
+	"strings"
 	"unicode/utf8"
 
 	tb "github.com/nsf/termbox-go"
@@ -18,6 +19,44 @@ var (
 	emptyCell = tb.Cell{Ch: charSpace}
 )
 
+// attrSelected marks the cells of an edit buffer that are selected. It is
+// never drawn; selected cells are drawn with the selection colors instead
+// of their own.
+const attrSelected tb.Attribute = 1 << 15
+
+// tabWidth is the distance between the tab stops used when interpreting
+// written text the way a terminal would.
+const tabWidth = 8
+
+// mouseWheelRows is the number of rows scrolled by each turn of the mouse
+// wheel.
+const mouseWheelRows = 3
+
+// A SelectionMode determines the shape of the text selected when the cursor
+// is moved with the shift key held down.
+type SelectionMode byte
+
+const (
+	// SelectionStream selects a contiguous stream of characters running from
+	// the selection anchor to the cursor.
+	SelectionStream SelectionMode = iota
+
+	// SelectionBlock selects a rectangle of columns spanning the rows between
+	// the selection anchor and the cursor.
+	SelectionBlock
+)
+
+// A command identifies the kind of editing command most recently executed
+// by a screenBox. It is used to chain consecutive kill and yank commands;
+// moving the cursor or editing the buffer in any other way ends the chain.
+type command byte
+
+const (
+	cmdNone command = iota
+	cmdKill
+	cmdYank
+)
+
 // A row represents a single line of text within the screen buffer.
 type row struct {
 	cells []tb.Cell // edit buffer cells in this row
@@ -41,16 +80,27 @@ func (r *row) grow(n int) {
 // A screenBox represents a rectangle of text that can be displayed on the
 // console at a given location.
 type screenBox struct {
-	size      coord       // screen dimensions of the buffer
-	corner    coord       // screen coordinate of top-left corner
-	view      rect        // visible portion of the buffer
-	dirty     rect        // portion of the buffer that needs an update
-	rows      []row       // all rows in the edit buffer
-	cursor    coord       // current cursor position
-	lastX     int         // cursor X position after last horz move
-	modifiers tb.Modifier // modifier keys currently down
-	selecting bool        // cursor in selecting mode
-	selection crange      // current selection range
+	size      coord         // screen dimensions of the buffer
+	corner    coord         // screen coordinate of top-left corner
+	view      rect          // visible portion of the buffer
+	dirty     rect          // portion of the buffer that needs an update
+	rows      []row         // all rows in the edit buffer
+	cursor    coord         // current cursor position
+	lastX     int           // cursor X position after last horz move
+	modifiers tb.Modifier   // modifier keys currently down
+	selecting bool          // cursor in selecting mode
+	selection crange        // current selection range
+	block     bool          // current selection is rectangular
+	selMode   SelectionMode // shape of new selections
+	yanked    crange        // range of text inserted by the last yank
+	lastCmd   command       // most recently executed command
+	carets    []caret       // secondary cursors
+	inCarets  bool          // applying an operation to every cursor
+	modified  bool          // buffer contents changed since last cleared
+	ansi      *ansiDecoder  // decoder of written escape sequences, or nil
+	appending bool          // Write appends to the end of the buffer
+	outX      int           // column on the last row where Write appends
+	maxRows   int           // maximum rows kept when appending, or 0
 }
 
 // newScreenBox creates a new EditBox control with the specified screen
@@ -65,35 +115,285 @@ func newScreenBox(x, y, width, height int) screenBox {
 	}
 }
 
-// getCursor returns the absolute screen position of the cursor.
+// getCursor returns the absolute screen position of the cursor. The cursor
+// is hidden while the view is scrolled away from it.
 func (b *screenBox) getCursor() (x, y int, show bool) {
 	x = b.cursor.x - b.view.x0 + b.corner.x
 	y = b.cursor.y - b.view.y0 + b.corner.y
-	show = true
+	show = contains(b.view, b.cursor.x, b.cursor.y)
 	return
 }
 
+// bounds returns the screen rectangle occupied by the box.
+func (b *screenBox) bounds() rect {
+	return newRect(b.corner.x, b.corner.y, b.size.x, b.size.y)
+}
+
+// onMouse moves the cursor to the buffer position under a left click,
+// extends the selection while the mouse is dragged, and scrolls the view
+// with the mouse wheel.
+func (b *screenBox) onMouse(ev tb.Event) error {
+	switch ev.Key {
+	case tb.MouseLeft:
+		if (ev.Mod & tb.ModMotion) != 0 {
+			b.modifiers = tb.ModShift
+		} else {
+			b.modifiers = 0
+			if len(b.carets) > 0 {
+				b.ClearCursors()
+			}
+		}
+		x := ev.MouseX - b.corner.x + b.view.x0
+		y := ev.MouseY - b.corner.y + b.view.y0
+		b.CursorSet(max(x, 0), max(y, 0))
+	case tb.MouseWheelUp:
+		b.scrollView(-mouseWheelRows)
+	case tb.MouseWheelDown:
+		b.scrollView(+mouseWheelRows)
+	}
+	return nil
+}
+
+// move moves the box to a new screen position.
+func (b *screenBox) move(x, y int) {
+	damage(b.bounds())
+	b.corner = coord{x, y}
+	b.invalidate()
+}
+
+// resize changes the screen dimensions of the box, scrolling the view to
+// keep the cursor visible.
+func (b *screenBox) resize(width, height int) {
+	damage(b.bounds())
+	b.size = coord{width, height}
+	b.view = newRect(b.view.x0, b.view.y0, width, height)
+	b.updateView()
+	b.invalidate()
+}
+
+// scrollView scrolls the view vertically by dy rows without moving the
+// cursor.
+func (b *screenBox) scrollView(dy int) {
+	y := min(max(b.view.y0+dy, 0), max(len(b.rows)-b.size.y, 0))
+	if y != b.view.y0 {
+		b.SetView(b.view.x0, y)
+	}
+}
+
+// dirtyRect returns the screen rectangle that will be updated the next time
+// the box is drawn.
+func (b *screenBox) dirtyRect() rect {
+	r := intersection(b.dirty, b.view)
+	if r.empty() {
+		return emptyRect
+	}
+	dx, dy := b.corner.x-b.view.x0, b.corner.y-b.view.y0
+	return rect{r.x0 + dx, r.y0 + dy, r.x1 + dx, r.y1 + dy}
+}
+
+// invalidate marks the entire visible portion of the buffer as needing an
+// update.
+func (b *screenBox) invalidate() {
+	b.updateDirtyRect(b.view)
+}
+
 // Write the contents of a UTF8-formatted buffer starting at the current
 // cursor position. This function allows you to use standard formatted
 // output functions like `fmt.Fprintf` with an EditBox control.
+//
+// If ANSI interpretation is enabled with SetANSI, text is written the way a
+// terminal would display it: it overwrites the characters under the
+// cursor, colored by SGR escape sequences, and carriage returns,
+// backspaces, newlines and tabs move the cursor.
+//
+// In append mode, selected with SetAppendMode, text is written to the end
+// of the buffer instead of at the cursor.
 func (b *screenBox) Write(p []byte) (n int, err error) {
+	if b.appending {
+		b.appendText(p)
+		return len(p), nil
+	}
+	if b.ansi != nil {
+		cells := b.ansi.decode(p)
+		b.forEachCaret(func() {
+			for _, c := range cells {
+				b.writeCell(c)
+			}
+		})
+		return len(p), nil
+	}
+
+	n = len(p)
 	for len(p) > 0 {
 		ch, sz := utf8.DecodeRune(p)
-		if err != nil {
-			return 0, err
-		}
 		p = p[sz:]
 		b.InsertChar(ch)
 	}
-	return 0, nil
+	return n, nil
+}
+
+// SetANSI enables or disables the interpretation of ANSI escape sequences
+// and control characters by Write. When enabled, SGR sequences set the
+// colors, bold, underline and reverse attributes of the text that
+// follows, and all other escape sequences are removed. This allows the
+// colored output of commands such as "git diff --color" to be displayed.
+func (b *screenBox) SetANSI(on bool) {
+	switch {
+	case on && b.ansi == nil:
+		b.ansi = new(ansiDecoder)
+	case !on:
+		b.ansi = nil
+	}
+}
+
+// ANSI returns true if Write interprets ANSI escape sequences.
+func (b *screenBox) ANSI() bool {
+	return b.ansi != nil
+}
+
+// SetAppendMode enables or disables append mode, which suits boxes
+// displaying streamed output such as logs. In append mode, Write adds text
+// to the end of the buffer without moving the cursor or changing the
+// selection, and carriage returns are ignored unless ANSI interpretation
+// is enabled. While the view shows the last row, it follows the appended
+// text; scrolling up stops following, and returning to the end of the
+// buffer resumes it.
+func (b *screenBox) SetAppendMode(on bool) {
+	b.appending = on
+	b.outX = b.rowLen(len(b.rows) - 1)
+}
+
+// AppendMode returns true if Write appends to the end of the buffer.
+func (b *screenBox) AppendMode() bool {
+	return b.appending
+}
+
+// SetMaxRows sets the maximum number of rows kept in append mode. When
+// appended text makes the buffer longer, the oldest rows are discarded. A
+// value of 0 removes the limit.
+func (b *screenBox) SetMaxRows(n int) {
+	b.maxRows = max(n, 0)
+}
+
+// Following returns true if the view shows the last row of the buffer, so
+// that it follows text written in append mode.
+func (b *screenBox) Following() bool {
+	return b.view.y1 >= len(b.rows)
+}
+
+// appendText writes text at the end of the buffer, restoring the cursor,
+// selection and view afterwards. A view that was following the end of the
+// buffer is scrolled to show the new text.
+func (b *screenBox) appendText(p []byte) {
+	follow := b.Following()
+	cursor, lastX, view, mods := b.cursor, b.lastX, b.view, b.modifiers
+	selecting, carets := b.selecting, b.carets
+	b.selecting, b.carets, b.modifiers = false, nil, 0
+
+	last := len(b.rows) - 1
+	if b.ansi != nil {
+		b.cursor = coord{min(b.outX, b.rowLen(last)), last}
+		for _, c := range b.ansi.decode(p) {
+			b.writeCell(c)
+		}
+	} else {
+		b.cursor = coord{b.rowLen(last), last}
+		for len(p) > 0 {
+			ch, sz := utf8.DecodeRune(p)
+			p = p[sz:]
+			if ch != charLinefeed {
+				b.insertChar(ch)
+			}
+		}
+	}
+	b.outX = b.cursor.x
+
+	b.cursor, b.lastX, b.view, b.modifiers = cursor, lastX, view, mods
+	b.selecting, b.carets = selecting, carets
+	b.trimRows()
+	if follow {
+		b.SetView(b.view.x0, max(len(b.rows)-b.size.y, 0))
+	}
+}
+
+// trimRows discards the oldest rows in excess of the maximum row count,
+// moving the cursors, selections and view up with the remaining rows.
+// Positions within the discarded rows move to the start of the buffer.
+func (b *screenBox) trimRows() {
+	n := len(b.rows) - b.maxRows
+	if b.maxRows == 0 || n <= 0 {
+		return
+	}
+	copy(b.rows, b.rows[n:])
+	b.rows = b.rows[:len(b.rows)-n]
+
+	shift := func(c coord) coord {
+		if c.y < n {
+			return coord{0, 0}
+		}
+		return coord{c.x, c.y - n}
+	}
+	b.cursor = shift(b.cursor)
+	b.selection = crange{shift(b.selection.c0), shift(b.selection.c1)}
+	for i := range b.carets {
+		ct := &b.carets[i]
+		ct.cursor = shift(ct.cursor)
+		ct.selection = crange{shift(ct.selection.c0), shift(ct.selection.c1)}
+	}
+	b.lastCmd = cmdNone
+	b.SetView(b.view.x0, max(b.view.y0-n, 0))
+}
+
+// writeCell writes a cell decoded from ANSI text at the cursor, the way a
+// terminal would. A newline starts the next line, adding a row if the
+// cursor is on the last row.
+func (b *screenBox) writeCell(c tb.Cell) {
+	if b.selecting {
+		b.deleteSelection()
+	}
+
+	cx, cy := b.cursor.x, b.cursor.y
+	switch {
+	case c.Ch == charNewline:
+		if cy+1 < len(b.rows) {
+			b.updateCursor(0, cy+1)
+		} else {
+			b.updateCursor(b.rowLen(cy), cy)
+			b.insertChar(charNewline)
+		}
+	case c.Ch == charLinefeed:
+		b.updateCursor(0, cy)
+	case c.Ch == charBackspace:
+		b.updateCursor(max(cx-1, 0), cy)
+	case c.Ch == '\t':
+		for n := tabWidth - cx%tabWidth; n > 0; n-- {
+			b.writeCell(tb.Cell{Ch: charSpace, Fg: c.Fg, Bg: c.Bg})
+		}
+	case c.Ch < 32 || c.Ch == 0x7f:
+		// Other control characters are ignored.
+	case cx < b.rowLen(cy):
+		*b.cellAtPos(b.cursor) = c
+		b.updateDirtyRect(rect{cx, cy, cx + 1, cy + 1})
+		b.modified = true
+		b.updateCursor(cx+1, cy)
+	default:
+		b.insertChar(c.Ch)
+		*b.cellAtPos(coord{cx, cy}) = c
+	}
+	b.lastX = b.cursor.x
 }
 
 // InsertChar inserts a new character at the current cursor position and
 // advances the cursor by one column.
 func (b *screenBox) InsertChar(ch rune) {
+	b.forEachCaret(func() {
+		b.insertChar(ch)
+	})
+}
+
+func (b *screenBox) insertChar(ch rune) {
 	if b.selecting {
-		b.deleteRange(b.selection.ordered())
-		b.selecting = false
+		b.deleteSelection()
 	}
 
 	cx, cy := b.cursor.x, b.cursor.y
@@ -108,6 +408,7 @@ func (b *screenBox) InsertChar(ch rune) {
 			nextRow.cells = append(nextRow.cells, currRow.cells[cx:]...)
 			currRow.cells = append(currRow.cells[:cx], emptyCell)
 			b.updateDirtyRect(rect{cx, cy, maxValue, cy + 1})
+			b.modified = true
 
 		case charLinefeed:
 			b.updateCursor(0, cy)
@@ -119,12 +420,25 @@ func (b *screenBox) InsertChar(ch rune) {
 		copy(row.cells[cx+1:], row.cells[cx:])
 		row.cells[cx] = tb.Cell{Ch: ch}
 		b.updateDirtyRect(rect{cx, cy, maxValue, cy + 1})
+		b.modified = true
 		b.updateCursor(cx+1, cy)
 	}
 
 	b.lastX = b.cursor.x
 }
 
+// OverwriteChar replaces the character at the current cursor position and
+// advances the cursor by one column. At the end of a line, or when text is
+// selected, the character is inserted instead.
+func (b *screenBox) OverwriteChar(ch rune) {
+	b.forEachCaret(func() {
+		if !b.selecting && ch >= 32 && b.cursor.x < b.rowLen(b.cursor.y) {
+			b.deleteChar()
+		}
+		b.insertChar(ch)
+	})
+}
+
 // InsertString inserts an entire string at the current cursor position
 // and advances the cursor by the length of the string.
 func (b *screenBox) InsertString(s string) {
@@ -142,13 +456,18 @@ func (b *screenBox) InsertRow() {
 	b.rows[cy] = newRow(b.size.x)
 	b.updateCursor(0, cy)
 	b.updateDirtyRect(rect{0, cy, maxValue, maxValue})
+	b.modified = true
 }
 
 // DeleteChar deletes a single character at the current cursor position.
 func (b *screenBox) DeleteChar() {
+	b.forEachCaret(b.deleteChar)
+}
+
+func (b *screenBox) deleteChar() {
+	b.lastCmd = cmdNone
 	if b.selecting {
-		b.deleteRange(b.selection.ordered())
-		b.selecting = false
+		b.deleteSelection()
 		return
 	}
 
@@ -163,6 +482,7 @@ func (b *screenBox) DeleteChar() {
 			row.cells = append(row.cells[:rl], nr.cells...)
 			b.rows = append(b.rows[:cy+1], b.rows[cy+2:]...)
 			b.updateDirtyRect(rect{0, cy, maxValue, maxValue})
+			b.modified = true
 		}
 		return
 	}
@@ -171,24 +491,28 @@ func (b *screenBox) DeleteChar() {
 	copy(row.cells[cx:], row.cells[cx+1:])
 	row.cells = row.cells[:len(row.cells)-1]
 	b.updateDirtyRect(rect{cx, cy, maxValue, cy + 1})
+	b.modified = true
 }
 
 // DeleteCharLeft deletes the character to the left of the cursor and moves
 // the cursor to the position of the deleted character. If the cursor is at
 // the start of the line, the newline is removed.
 func (b *screenBox) DeleteCharLeft() {
+	b.forEachCaret(b.deleteCharLeft)
+}
+
+func (b *screenBox) deleteCharLeft() {
 	if b.cursor.x == 0 && b.cursor.y == 0 {
 		return
 	}
 
 	if b.selecting {
-		b.deleteRange(b.selection.ordered())
-		b.selecting = false
+		b.deleteSelection()
 		return
 	}
 
-	b.CursorLeft()
-	b.DeleteChar()
+	b.cursorLeft()
+	b.deleteChar()
 }
 
 // DeleteChars deletes multiple characters starting from the current cursor
@@ -199,6 +523,7 @@ func (b *screenBox) DeleteChars(n int) {
 
 // DeleteRow deletes the entire row containing the cursor.
 func (b *screenBox) DeleteRow() {
+	b.lastCmd = cmdNone
 	cy := b.cursor.y
 	if cy+1 < len(b.rows) {
 		b.rows = append(b.rows[:cy+1], b.rows[cy+2:]...)
@@ -207,6 +532,19 @@ func (b *screenBox) DeleteRow() {
 		b.rows = b.rows[:cy+1]
 		b.updateDirtyRect(rect{0, cy, maxValue, maxValue})
 	}
+	b.modified = true
+}
+
+// Modified returns true if the contents of the buffer have changed since
+// the modified flag was last cleared with SetModified.
+func (b *screenBox) Modified() bool {
+	return b.modified
+}
+
+// SetModified sets or clears the buffer's modified flag. Applications
+// usually clear it after loading or saving the buffer's contents.
+func (b *screenBox) SetModified(modified bool) {
+	b.modified = modified
 }
 
 // LastRow returns the row number of the last row in the buffer.
@@ -219,12 +557,20 @@ func (b *screenBox) Size() (width, height int) {
 	return b.size.x, b.size.y
 }
 
-// CopyToClipboard copies the current selection to the clipboard.
+// CopyToClipboard copies the current selection to the clipboard. It does
+// nothing if no text is selected.
 func (b *screenBox) CopyToClipboard() {
-	if b.selecting {
-		ClipboardSet(b.getRange(b.selection.ordered()))
-	} else {
-		ClipboardClear()
+	var err error
+	switch {
+	case !b.selecting:
+		return
+	case b.block:
+		err = clipboardSetBlock(b.Selection())
+	default:
+		err = ClipboardSet(b.Selection())
+	}
+	if err != nil {
+		logger.Warn("clipboard write failed", "err", err)
 	}
 }
 
@@ -232,34 +578,187 @@ func (b *screenBox) CopyToClipboard() {
 // deletes it from the edit buffer.
 func (b *screenBox) CutToClipboard() {
 	if b.selecting {
-		r := b.selection.ordered()
-		ClipboardSet(b.getRange(r))
-		b.deleteRange(r)
-		b.selecting = false
+		b.CopyToClipboard()
+		b.deleteSelection()
 	}
 }
 
 // PasteFromClipboard pastes the current clipboard contents to the edit buffer
 // at the current cursor position.
 func (b *screenBox) PasteFromClipboard() {
+	b.paste(clipboardPaste())
+}
+
+// paste inserts text from the clipboard at every cursor position. Text
+// copied from a block selection is inserted column-wise.
+func (b *screenBox) paste(s string) {
+	if clipboardIsBlock(s) {
+		b.InsertBlock(s)
+		return
+	}
+
+	b.forEachCaret(func() {
+		b.yank(s)
+	})
+	if len(b.carets) > 0 {
+		b.lastCmd = cmdNone
+	}
+}
+
+// InsertBlock inserts a block of text column-wise at the cursor position.
+// Each line of the string is inserted into a successive row starting at the
+// cursor's column. Short rows are padded with spaces, and rows are added to
+// the end of the buffer as needed. The cursor moves to the end of the last
+// inserted line.
+func (b *screenBox) InsertBlock(s string) {
 	if b.selecting {
-		b.deleteRange(b.selection)
-		b.selecting = false
+		b.deleteSelection()
+	}
+
+	lines := strings.Split(s, "\n")
+	cx, cy := b.cursor.x, b.cursor.y
+	for i, line := range lines {
+		y := cy + i
+		if y == len(b.rows) {
+			last := &b.rows[y-1]
+			last.cells = append(last.cells, emptyCell)
+			b.rows = append(b.rows, newRow(b.size.x))
+		}
+
+		r := &b.rows[y]
+		rl := b.rowLen(y)
+		cells := make([]tb.Cell, 0, len(line)+max(cx-rl, 0))
+		for x := rl; x < cx; x++ {
+			cells = append(cells, emptyCell)
+		}
+		for _, ch := range line {
+			cells = append(cells, tb.Cell{Ch: ch})
+		}
+
+		x := min(cx, rl)
+		r.cells = append(r.cells[:x], append(cells, r.cells[x:]...)...)
+		b.updateDirtyRect(rect{x, y, maxValue, y + 1})
+		b.modified = true
+	}
+
+	last := len(lines) - 1
+	b.updateCursor(cx+utf8.RuneCountInString(lines[last]), cy+last)
+	b.lastX = b.cursor.x
+}
+
+// KillToEndOfLine cuts the text from the cursor to the end of the line and
+// places it on the clipboard. If the cursor is already at the end of the
+// line, the newline is cut instead. Consecutive kills are merged into a
+// single clipboard entry.
+func (b *screenBox) KillToEndOfLine() {
+	cx, cy := b.cursor.x, b.cursor.y
+	r := crange{coord{cx, cy}, coord{b.rowLen(cy), cy}}
+	if r.empty() {
+		if cy+1 >= len(b.rows) {
+			return
+		}
+		r.c1 = coord{0, cy + 1}
+	}
+
+	s := b.getRange(r)
+	var err error
+	if b.lastCmd == cmdKill {
+		err = ClipboardAppend(s)
+	} else {
+		err = ClipboardSet(s)
+	}
+	if err != nil {
+		logger.Warn("clipboard write failed", "err", err)
+	}
+	b.deleteRange(r)
+	b.lastCmd = cmdKill
+}
+
+// YankPop replaces the text inserted by the immediately preceding paste or
+// yank-pop with the next older entry in the clipboard's kill ring. It does
+// nothing if the previous command was not a paste.
+func (b *screenBox) YankPop() {
+	if b.lastCmd != cmdYank {
+		return
+	}
+
+	b.deleteRange(b.yanked)
+	b.updateCursor(b.yanked.c0.x, b.yanked.c0.y)
+	b.yank(ClipboardYankPop())
+}
+
+// yank replaces the selection with a string, or inserts it at the cursor
+// position, and records the inserted range so it can be replaced by a
+// subsequent yank-pop.
+func (b *screenBox) yank(s string) {
+	if b.selecting {
+		b.deleteSelection()
 	}
 
-	s := ClipboardGet()
+	b.yanked.c0 = b.cursor
 	if s != "" {
 		b.InsertString(s)
 	}
+	b.yanked.c1 = b.cursor
+	b.lastCmd = cmdYank
+}
+
+// SelectAll selects the entire contents of the edit buffer and moves the
+// cursor to the end of the buffer.
+func (b *screenBox) SelectAll() {
+	if len(b.carets) > 0 {
+		b.ClearCursors()
+	}
+	mods, mode := b.modifiers, b.selMode
+	b.modifiers, b.selMode = 0, SelectionStream
+	b.CursorStartOfBuffer()
+	b.modifiers = tb.ModShift
+	b.CursorEndOfBuffer()
+	b.modifiers, b.selMode = mods, mode
 }
 
 // Selection returns the contents of the substring currently selected in the
 // edit buffer.
 func (b *screenBox) Selection() string {
-	if b.selecting {
+	switch {
+	case !b.selecting:
+		return ""
+	case b.block:
+		return b.getBlock(blockRect(b.selection))
+	default:
 		return b.getRange(b.selection.ordered())
 	}
-	return ""
+}
+
+// SelectionLen returns the number of characters, including newlines, in
+// the current selection.
+func (b *screenBox) SelectionLen() int {
+	switch {
+	case !b.selecting:
+		return 0
+	case b.block:
+		r, n := blockRect(b.selection), 0
+		for y := r.y0; y < r.y1 && y < len(b.rows); y++ {
+			rl := b.rowLen(y)
+			n += min(r.x1, rl) - min(r.x0, rl)
+		}
+		return n
+	default:
+		r := b.selection.ordered()
+		return b.offsetOf(r.c1) - b.offsetOf(r.c0)
+	}
+}
+
+// SelectionMode returns the shape of selections made with the shift key.
+func (b *screenBox) SelectionMode() SelectionMode {
+	return b.selMode
+}
+
+// SetSelectionMode sets the shape of selections made with the shift key.
+// Holding the alt key along with the shift key always makes a block
+// selection.
+func (b *screenBox) SetSelectionMode(m SelectionMode) {
+	b.selMode = m
 }
 
 // Cursor returns the cursor's current column and row within the edit buffer.
@@ -298,6 +797,10 @@ func (b *screenBox) CursorSet(x, y int) {
 // CursorLeft moves the cursor left, shifting to the end of the previous line
 // if the cursor is at column 0.
 func (b *screenBox) CursorLeft() {
+	b.forEachCaret(b.cursorLeft)
+}
+
+func (b *screenBox) cursorLeft() {
 	cx, cy := b.cursor.x, b.cursor.y
 	if cx > 0 {
 		b.updateCursor(cx-1, cy)
@@ -311,6 +814,10 @@ func (b *screenBox) CursorLeft() {
 // CursorRight moves the cursor right, shifting to the next line if the cursor
 // is at the right-most column of the current line.
 func (b *screenBox) CursorRight() {
+	b.forEachCaret(b.cursorRight)
+}
+
+func (b *screenBox) cursorRight() {
 	cx, cy := b.cursor.x, b.cursor.y
 	rl := b.rowLen(cy)
 	if cx < rl {
@@ -323,6 +830,10 @@ func (b *screenBox) CursorRight() {
 
 // CursorDown moves the cursor down a line.
 func (b *screenBox) CursorDown() {
+	b.forEachCaret(b.cursorDown)
+}
+
+func (b *screenBox) cursorDown() {
 	if b.cursor.y+1 >= len(b.rows) {
 		return
 	}
@@ -337,6 +848,10 @@ func (b *screenBox) CursorDown() {
 
 // CursorUp moves the cursor up a line.
 func (b *screenBox) CursorUp() {
+	b.forEachCaret(b.cursorUp)
+}
+
+func (b *screenBox) cursorUp() {
 	if b.cursor.y == 0 {
 		return
 	}
@@ -351,6 +866,10 @@ func (b *screenBox) CursorUp() {
 
 // CursorWordStart moves the cursor to the start of the word.
 func (b *screenBox) CursorWordStart() {
+	b.forEachCaret(b.cursorWordStart)
+}
+
+func (b *screenBox) cursorWordStart() {
 	c := b.cursor
 	for {
 		p, r, err := b.prevThenGet(c)
@@ -373,6 +892,10 @@ func (b *screenBox) CursorWordStart() {
 
 // CursorWordEnd moves the cursor to end of the word.
 func (b *screenBox) CursorWordEnd() {
+	b.forEachCaret(b.cursorWordEnd)
+}
+
+func (b *screenBox) cursorWordEnd() {
 	c := b.cursor
 	for {
 		n, r, err := b.getThenNext(c)
@@ -423,6 +946,10 @@ func (b *screenBox) CursorStartOfBuffer() {
 
 // CursorStartOfLine moves the cursor to the start of the current line.
 func (b *screenBox) CursorStartOfLine() {
+	b.forEachCaret(b.cursorStartOfLine)
+}
+
+func (b *screenBox) cursorStartOfLine() {
 	b.updateCursor(0, b.cursor.y)
 	b.lastX = b.cursor.x
 }
@@ -437,6 +964,10 @@ func (b *screenBox) CursorEndOfBuffer() {
 
 // CursorEndOfLine moves the cursor to the end of the current line.
 func (b *screenBox) CursorEndOfLine() {
+	b.forEachCaret(b.cursorEndOfLine)
+}
+
+func (b *screenBox) cursorEndOfLine() {
 	cy := b.cursor.y
 	cx := b.rowLen(cy)
 	b.updateCursor(cx, cy)
@@ -537,6 +1068,49 @@ func (b *screenBox) getRange(r crange) string {
 	return string(buf)
 }
 
+// getBlock returns the contents of the edit buffer covering the columns and
+// rows of a rectangle, with each row's text separated by a newline.
+func (b *screenBox) getBlock(r rect) string {
+	var buf []byte
+	for y := r.y0; y < r.y1; y++ {
+		if y > r.y0 {
+			buf = append(buf, '\n')
+		}
+		buf = appendCellChars(buf, b.getCells(y, r.x0, r.x1))
+	}
+	return string(buf)
+}
+
+// deleteSelection removes the currently selected text from the edit buffer
+// and ends the selection.
+func (b *screenBox) deleteSelection() {
+	if b.block {
+		b.deleteBlock(blockRect(b.selection))
+	} else {
+		b.deleteRange(b.selection.ordered())
+	}
+	b.selecting = false
+}
+
+// deleteBlock removes the columns of a rectangle from each of its rows and
+// moves the cursor to the top-left corner of the rectangle.
+func (b *screenBox) deleteBlock(r rect) {
+	for y := r.y0; y < r.y1; y++ {
+		rl := b.rowLen(y)
+		x0, x1 := min(r.x0, rl), min(r.x1, rl)
+		row := &b.rows[y]
+		row.cells = append(row.cells[:x0], row.cells[x1:]...)
+		b.updateDirtyRect(rect{x0, y, maxValue, y + 1})
+		if x1 > x0 {
+			b.modified = true
+		}
+	}
+
+	b.cursor.x, b.cursor.y = min(r.x0, b.rowLen(r.y0)), r.y0
+	b.lastX = b.cursor.x
+	b.updateView()
+}
+
 // deleteRange removes a range of text from the edit buffer.
 func (b *screenBox) deleteRange(r crange) {
 	x, y := r.c0.x, r.c0.y
@@ -581,11 +1155,13 @@ func (b *screenBox) deleteCells(y, x0, x1 int) {
 	b.updateDirtyRect(rect{x0, y, maxValue, y + 1})
 	if x1 < rl || y+1 == len(b.rows) {
 		r.cells = append(r.cells[:x0], r.cells[x1:]...)
+		b.modified = b.modified || x1 > x0
 	} else {
 		nr := &b.rows[y+1]
 		r.cells = append(r.cells[:x0], nr.cells...)
 		b.rows = append(b.rows[:y+1], b.rows[y+2:]...)
 		b.updateDirtyRect(rect{0, y + 1, maxValue, maxValue})
+		b.modified = true
 	}
 
 	// adjust cursor
@@ -606,32 +1182,31 @@ func (b *screenBox) deleteCells(y, x0, x1 int) {
 
 // Draw updates the contents of the EditBox on the screen.
 func (b *screenBox) Draw() {
-	buf := tb.CellBuffer()
-	stride, _ := tb.Size()
+	b.draw(newCanvas(b.bounds()))
+}
 
+// draw redraws the dirty portion of the view on a canvas.
+func (b *screenBox) draw(cv *Canvas) {
 	r := intersection(b.dirty, b.view)
-	width, height := r.x1-r.x0, r.y1-r.y0
-
-	boffset := b.corner.x + (b.corner.y+r.y0-b.view.y0)*stride
-
-	ymax := min(r.y1, len(b.rows))
-	ymin := min(max(r.y0, 0), ymax)
-	for y := ymin; y < ymax; y++ {
-		row := &b.rows[y]
-		xmax := min(r.x1, len(row.cells))
-		xmin := min(max(r.x0, 0), xmax)
-		o := boffset + r.x0 - b.view.x0
-		copy(buf[o:], row.cells[xmin:xmax])
-		clearCells(buf[o+xmax-xmin : o+width])
-		boffset += stride
-	}
-
-	remain := height - (ymax - ymin)
-	for y := 0; y < remain; y++ {
-		clearCells(buf[boffset : boffset+width])
-		boffset += stride
+	width := r.x1 - r.x0
+	x := r.x0 - b.view.x0
+
+	for y := r.y0; y < r.y1; y++ {
+		n := 0
+		if y >= 0 && y < len(b.rows) {
+			cells := b.rows[y].cells
+			xmax := min(r.x1, len(cells))
+			xmin := min(max(r.x0, 0), xmax)
+			for i, c := range cells[xmin:xmax] {
+				fg, bg := cellColors(c)
+				cv.SetCell(x+i, y-b.view.y0, c.Ch, fg, bg)
+			}
+			n = xmax - xmin
+		}
+		cv.Fill(x+n, y-b.view.y0, width-n, 1, emptyCell.Ch, emptyCell.Fg, emptyCell.Bg)
 	}
 
+	b.drawCarets(cv)
 	b.dirty = emptyRect
 }
 
@@ -687,14 +1262,17 @@ func (b *screenBox) updateDirtyRect(r rect) {
 // updateCursor updates the position of the cursor. The new position is not
 // validated.
 func (b *screenBox) updateCursor(cx, cy int) {
+	b.lastCmd = cmdNone
 	shiftDown := (b.modifiers & tb.ModShift) != 0
+	altDown := (b.modifiers & tb.ModAlt) != 0
 	switch {
 	case shiftDown && !b.selecting:
 		b.selection.c0 = b.cursor
 		b.selection.c1 = b.cursor
 		b.selecting = true
+		b.block = altDown || b.selMode == SelectionBlock
 	case !shiftDown && b.selecting:
-		b.unhighlight(b.selection.ordered())
+		b.unhighlightSelection()
 		b.selecting = false
 	}
 
@@ -709,6 +1287,11 @@ func (b *screenBox) updateCursor(cx, cy int) {
 // updateSelection updates the currently selected range of text in the edit
 // buffer.
 func (b *screenBox) updateSelection(x, y int) {
+	if b.block {
+		b.updateBlockSelection(x, y)
+		return
+	}
+
 	curr := coord{x, y}
 	switch {
 	case b.selection.c0.lessThan(b.selection.c1):
@@ -736,31 +1319,68 @@ func (b *screenBox) updateSelection(x, y int) {
 	b.selection.c1 = curr
 }
 
+// updateBlockSelection updates the currently selected rectangle of text in
+// the edit buffer. Vertical cursor movement keeps the rectangle's column
+// even when the cursor is clamped to the end of a short row.
+func (b *screenBox) updateBlockSelection(x, y int) {
+	if y != b.cursor.y {
+		x = max(x, b.lastX)
+	}
+
+	b.setCellSelectedRect(blockRect(b.selection), false)
+	b.selection.c1 = coord{x, y}
+	b.setCellSelectedRect(blockRect(b.selection), true)
+}
+
+// unhighlightSelection removes the highlight from the selected text.
+func (b *screenBox) unhighlightSelection() {
+	if b.block {
+		b.setCellSelectedRect(blockRect(b.selection), false)
+	} else {
+		b.unhighlight(b.selection.ordered())
+	}
+}
+
 func (b *screenBox) highlight(r crange) {
-	b.setCellAttribRange(r, tb.ColorBlack, tb.ColorWhite)
+	b.setCellSelectedRange(r, true)
 }
 
 func (b *screenBox) unhighlight(r crange) {
-	b.setCellAttribRange(r, tb.ColorDefault, tb.ColorDefault)
+	b.setCellSelectedRange(r, false)
 }
 
-// setCellAttribRange adjusts the attributes of all cells within a range.
-func (b *screenBox) setCellAttribRange(r crange, fg, bg tb.Attribute) {
+// setCellSelectedRange marks all cells within a range as selected or
+// unselected.
+func (b *screenBox) setCellSelectedRange(r crange, selected bool) {
 	x, y := r.c0.x, r.c0.y
 	for ; y < r.c1.y; y++ {
 		row := &b.rows[y]
 		for ; x < len(row.cells); x++ {
-			setCellAttrib(&row.cells[x], fg, bg)
+			setCellSelected(&row.cells[x], selected)
 		}
 		x = 0
 	}
 	for ; x < r.c1.x; x++ {
-		setCellAttrib(b.cellAtPos(coord{x, y}), fg, bg)
+		setCellSelected(b.cellAtPos(coord{x, y}), selected)
 	}
 
 	b.updateDirtyRect(rect{0, r.c0.y, maxValue, r.c1.y + 1})
 }
 
+// setCellSelectedRect marks all cells within the columns and rows of a
+// rectangle as selected or unselected.
+func (b *screenBox) setCellSelectedRect(r rect, selected bool) {
+	for y := r.y0; y < r.y1; y++ {
+		row := &b.rows[y]
+		x1 := min(r.x1, b.rowLen(y))
+		for x := r.x0; x < x1; x++ {
+			setCellSelected(&row.cells[x], selected)
+		}
+	}
+
+	b.updateDirtyRect(rect{0, r.y0, maxValue, r.y1})
+}
+
 // updateView uses the current cursor position to make sure the text under
 // the cursor is visible.
 func (b *screenBox) updateView() {
@@ -791,14 +1411,31 @@ func (b *screenBox) updateView() {
 	}
 }
 
-func clearCells(c []tb.Cell) {
-	for i := range c {
-		c[i] = emptyCell
+// blockRect returns the rectangle of columns and rows covered by a block
+// selection running from r.c0 to r.c1.
+func blockRect(r crange) rect {
+	return rect{
+		x0: min(r.c0.x, r.c1.x),
+		y0: min(r.c0.y, r.c1.y),
+		x1: max(r.c0.x, r.c1.x),
+		y1: max(r.c0.y, r.c1.y) + 1,
 	}
 }
 
-func setCellAttrib(c *tb.Cell, fg, bg tb.Attribute) {
-	c.Fg, c.Bg = fg, bg
+func setCellSelected(c *tb.Cell, selected bool) {
+	if selected {
+		c.Fg |= attrSelected
+	} else {
+		c.Fg &^= attrSelected
+	}
+}
+
+// cellColors returns the colors a cell of an edit buffer is drawn with.
+func cellColors(c tb.Cell) (fg, bg tb.Attribute) {
+	if (c.Fg & attrSelected) != 0 {
+		return tb.ColorBlack, tb.ColorWhite
+	}
+	return c.Fg, c.Bg
 }
 
 func isWhitespace(r rune) bool {
@@ -0,0 +1,179 @@
+package termwin
+
+import (
+	"strings"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+const charDropDown = '▼'
+
+// A Select is a drop-down list from which one option may be chosen. The
+// list is opened by clicking the control or by pressing Enter, Space or
+// Alt+Down. The Up and Down arrow keys change the selection without
+// opening the list.
+type Select struct {
+	corner   coord            // screen coordinate of left edge
+	width    int              // screen width of the control
+	options  []string         // option labels
+	selected int              // index of the selected option
+	onChange func(option int) // called when the user selects an option
+	focused  bool             // control had the focus when last drawn
+	dirty    bool             // control needs to be redrawn
+}
+
+// NewSelect creates a new Select control with the specified screen position
+// and width. The first option is selected.
+func NewSelect(x, y, width int, options ...string) *Select {
+	s := &Select{
+		corner: coord{x, y},
+		width:  width,
+		dirty:  true,
+	}
+	s.SetOptions(options)
+	addWindow(s)
+	return s
+}
+
+// Options returns the option labels.
+func (s *Select) Options() []string {
+	return s.options
+}
+
+// SetOptions replaces the options and selects the first one.
+func (s *Select) SetOptions(options []string) {
+	s.options = s.options[:0]
+	for _, o := range options {
+		s.options = append(s.options, sanitizeLine(o))
+	}
+	s.selected = 0
+	s.dirty = true
+}
+
+// Selected returns the index of the selected option.
+func (s *Select) Selected() int {
+	return s.selected
+}
+
+// SetSelected selects an option without calling the change function.
+func (s *Select) SetSelected(option int) {
+	option = min(max(option, 0), max(len(s.options)-1, 0))
+	if option != s.selected {
+		s.selected = option
+		s.dirty = true
+	}
+}
+
+// Value returns the label of the selected option, or the empty string if
+// there are no options.
+func (s *Select) Value() string {
+	if s.selected >= len(s.options) {
+		return ""
+	}
+	return s.options[s.selected]
+}
+
+// OnChange sets the function called when the user selects an option.
+func (s *Select) OnChange(f func(option int)) {
+	s.onChange = f
+}
+
+// Open displays the drop-down list of options below the control.
+func (s *Select) Open() {
+	if len(s.options) == 0 {
+		return
+	}
+	m := NewMenu("")
+	for i, o := range s.options {
+		i := i
+		m.Items = append(m.Items, &MenuItem{
+			Label:   strings.Replace(o, string(menuAccelMarker), string(menuAccelMarker)+string(menuAccelMarker), -1),
+			Checked: i == s.selected,
+			Action:  func() { s.choose(i) },
+		})
+	}
+	p := openMenu(m, s.corner.x, s.corner.y+1, nil, nil)
+	p.setSel(s.selected)
+}
+
+// choose selects an option at the user's request.
+func (s *Select) choose(option int) {
+	prev := s.selected
+	s.SetSelected(option)
+	if s.selected != prev && s.onChange != nil {
+		s.onChange(s.selected)
+	}
+}
+
+func (s *Select) bounds() rect {
+	return newRect(s.corner.x, s.corner.y, s.width, 1)
+}
+
+func (s *Select) dirtyRect() rect {
+	if !s.dirty && s.focused == isFocused(s) {
+		return emptyRect
+	}
+	return s.bounds()
+}
+
+func (s *Select) invalidate() {
+	s.dirty = true
+}
+
+func (s *Select) move(x, y int) {
+	damage(s.bounds())
+	s.corner = coord{x, y}
+	s.dirty = true
+}
+
+// resize changes the width of the control. The control is always one row
+// tall.
+func (s *Select) resize(width, height int) {
+	damage(s.bounds())
+	s.width = width
+	s.dirty = true
+}
+
+func (s *Select) getCursor() (x, y int, show bool) {
+	return 0, 0, false
+}
+
+func (s *Select) onKey(ev tb.Event) error {
+	switch {
+	case ev.Key == tb.KeyArrowDown && (ev.Mod&tb.ModAlt) != 0:
+		s.Open()
+	case ev.Key == tb.KeyArrowUp:
+		s.choose(s.selected - 1)
+	case ev.Key == tb.KeyArrowDown:
+		s.choose(s.selected + 1)
+	case ev.Key == tb.KeyHome:
+		s.choose(0)
+	case ev.Key == tb.KeyEnd:
+		s.choose(len(s.options) - 1)
+	case ev.Key == tb.KeyEnter || ev.Key == tb.KeySpace:
+		s.Open()
+	}
+	return nil
+}
+
+func (s *Select) onMouse(ev tb.Event) error {
+	if ev.Key == tb.MouseLeft && (ev.Mod&tb.ModMotion) == 0 {
+		s.Open()
+	}
+	return nil
+}
+
+func (s *Select) onDraw(cv *Canvas) {
+	if s.dirtyRect().empty() {
+		return
+	}
+	s.dirty = false
+	s.focused = isFocused(s)
+
+	fg, bg := focusColors(s.focused)
+	cells := appendAligned(nil, " "+s.Value(), max(s.width-2, 0), AlignLeft, fg, bg)
+	cells = append(cells,
+		tb.Cell{Ch: charSpace, Fg: fg, Bg: bg},
+		tb.Cell{Ch: charDropDown, Fg: fg, Bg: bg})
+	cv.SetCells(0, 0, cells[:min(s.width, len(cells))])
+}
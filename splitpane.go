@@ -0,0 +1,377 @@
+package termwin
+
+import (
+	"time"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+// A SplitOrientation determines how a SplitPane arranges its panes.
+type SplitOrientation int
+
+// Orientations of a SplitPane.
+const (
+	// SplitHorizontal places the panes side by side, separated by a
+	// vertical divider.
+	SplitHorizontal SplitOrientation = iota
+
+	// SplitVertical stacks the panes one above the other, separated by a
+	// horizontal divider.
+	SplitVertical
+)
+
+// A SplitPane is a container displaying two windows, called panes, separated
+// by a divider. The panes are resized to fill the space on either side of the
+// divider whenever it moves or the split pane is resized.
+//
+// F6 moves the focus to the other pane, and Alt+Left/Right (or Alt+Up/Down
+// for a vertical split) move the divider. The divider may also be dragged
+// with the mouse, and double-clicking it collapses or expands the second
+// pane.
+type SplitPane struct {
+	corner    coord            // screen coordinate of top-left corner
+	size      coord            // screen dimensions of the split pane
+	orient    SplitOrientation // arrangement of the panes
+	panes     [2]Window        // first (left or top) and second panes
+	pos       int              // divider offset from the top-left corner
+	minSize   [2]int           // minimum size of each pane
+	collapsed int              // index of the collapsed pane, or -1
+	focus     int              // index of the pane with the focus
+	pressed   Window           // pane receiving mouse events until release
+	dragging  bool             // the divider is being dragged
+	clickTime time.Time        // time the divider was last clicked
+	dirty     bool             // divider needs to be redrawn
+}
+
+// NewSplitPane creates a new SplitPane container with the specified screen
+// position, size and orientation. Both panes are removed from the screen's
+// window stack and are drawn, positioned and sized by the split pane. The
+// divider starts in the middle.
+func NewSplitPane(x, y, width, height int, orient SplitOrientation, first, second Window) *SplitPane {
+	RemoveWindow(first)
+	RemoveWindow(second)
+	s := &SplitPane{
+		corner:    coord{x, y},
+		size:      coord{width, height},
+		orient:    orient,
+		panes:     [2]Window{first, second},
+		collapsed: -1,
+	}
+	s.pos = s.clamp((s.length() - 1) / 2)
+	s.layout()
+	addWindow(s)
+	return s
+}
+
+// Pane returns the first (i == 0) or second (i == 1) pane.
+func (s *SplitPane) Pane(i int) Window {
+	return s.panes[i]
+}
+
+// Divider returns the offset of the divider from the left edge (or top edge
+// for a vertical split) of the split pane.
+func (s *SplitPane) Divider() int {
+	return s.dividerAt()
+}
+
+// SetDivider moves the divider to an offset from the left edge (or top edge
+// for a vertical split) of the split pane, expanding a collapsed pane. The
+// offset is limited by the minimum sizes of the panes.
+func (s *SplitPane) SetDivider(pos int) {
+	pos = s.clamp(pos)
+	if pos != s.pos || s.collapsed >= 0 {
+		s.pos = pos
+		s.collapsed = -1
+		s.layout()
+	}
+}
+
+// SetMinSizes sets the minimum sizes of the first and second panes, and
+// moves the divider if necessary to respect them. A collapsed pane is
+// hidden regardless of its minimum size.
+func (s *SplitPane) SetMinSizes(first, second int) {
+	s.minSize = [2]int{max(first, 0), max(second, 0)}
+	s.pos = s.clamp(s.pos)
+	s.layout()
+}
+
+// Collapse hides the first (i == 0) or second (i == 1) pane, giving all of
+// the space to the other pane. The divider remains at the edge of the split
+// pane so the collapsed pane can be expanded again.
+func (s *SplitPane) Collapse(i int) {
+	if i != 0 && i != 1 || i == s.collapsed {
+		return
+	}
+	s.collapsed = i
+	if s.pressed == s.panes[i] {
+		s.pressed = nil
+	}
+	s.layout()
+}
+
+// Expand restores a collapsed pane, returning the divider to its position
+// before the pane was collapsed.
+func (s *SplitPane) Expand() {
+	if s.collapsed >= 0 {
+		s.collapsed = -1
+		s.layout()
+	}
+}
+
+// Collapsed returns the index of the collapsed pane, or -1 if neither pane
+// is collapsed.
+func (s *SplitPane) Collapsed() int {
+	return s.collapsed
+}
+
+// length returns the size of the split pane along the axis divided by the
+// divider.
+func (s *SplitPane) length() int {
+	if s.orient == SplitVertical {
+		return s.size.y
+	}
+	return s.size.x
+}
+
+// clamp limits a divider offset to the range allowed by the pane minimum
+// sizes. If the split pane is too small for both minimum sizes, the first
+// pane's minimum takes priority.
+func (s *SplitPane) clamp(pos int) int {
+	n := s.length()
+	pos = min(pos, n-1-s.minSize[1])
+	pos = max(pos, s.minSize[0])
+	return min(max(pos, 0), max(n-1, 0))
+}
+
+// dividerAt returns the offset of the divider, taking collapsed panes into
+// account.
+func (s *SplitPane) dividerAt() int {
+	switch s.collapsed {
+	case 0:
+		return 0
+	case 1:
+		return max(s.length()-1, 0)
+	}
+	return s.pos
+}
+
+// paneRect returns the screen area of the i-th pane.
+func (s *SplitPane) paneRect(i int) rect {
+	d := s.dividerAt()
+	x, y := s.corner.x, s.corner.y
+	if s.orient == SplitVertical {
+		if i == 0 {
+			return newRect(x, y, s.size.x, d)
+		}
+		return newRect(x, y+d+1, s.size.x, max(s.size.y-d-1, 0))
+	}
+	if i == 0 {
+		return newRect(x, y, d, s.size.y)
+	}
+	return newRect(x+d+1, y, max(s.size.x-d-1, 0), s.size.y)
+}
+
+// dividerRect returns the screen area of the divider.
+func (s *SplitPane) dividerRect() rect {
+	d := s.dividerAt()
+	if s.orient == SplitVertical {
+		return newRect(s.corner.x, s.corner.y+d, s.size.x, 1)
+	}
+	return newRect(s.corner.x+d, s.corner.y, 1, s.size.y)
+}
+
+// visible returns true if the i-th pane is displayed.
+func (s *SplitPane) visible(i int) bool {
+	return s.collapsed != i && !s.paneRect(i).empty()
+}
+
+// layout moves and resizes the visible panes to fit on either side of the
+// divider.
+func (s *SplitPane) layout() {
+	damage(s.bounds())
+	for i, w := range s.panes {
+		if !s.visible(i) {
+			continue
+		}
+		r := s.paneRect(i)
+		w.move(r.x0, r.y0)
+		w.resize(r.x1-r.x0, r.y1-r.y0)
+	}
+	s.dirty = true
+}
+
+// moveDivider moves the divider by a number of cells at the user's request.
+func (s *SplitPane) moveDivider(delta int) {
+	s.SetDivider(s.dividerAt() + delta)
+}
+
+// paneAt returns the index of the visible pane containing a screen
+// position, or -1.
+func (s *SplitPane) paneAt(x, y int) int {
+	for i := range s.panes {
+		if s.visible(i) && contains(s.paneRect(i), x, y) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *SplitPane) focusedChild() Window {
+	switch {
+	case s.visible(s.focus):
+		return s.panes[s.focus]
+	case s.visible(1 - s.focus):
+		return s.panes[1-s.focus]
+	}
+	return nil
+}
+
+func (s *SplitPane) bounds() rect {
+	return newRect(s.corner.x, s.corner.y, s.size.x, s.size.y)
+}
+
+func (s *SplitPane) dirtyRect() rect {
+	if s.dirty {
+		return s.bounds()
+	}
+	r := emptyRect
+	for i, w := range s.panes {
+		if s.visible(i) {
+			r = union(r, w.dirtyRect())
+		}
+	}
+	return r
+}
+
+func (s *SplitPane) invalidate() {
+	s.dirty = true
+}
+
+func (s *SplitPane) move(x, y int) {
+	damage(s.bounds())
+	s.corner = coord{x, y}
+	s.layout()
+}
+
+// resize changes the size of the split pane, keeping the divider at the same
+// offset where the minimum pane sizes allow it.
+func (s *SplitPane) resize(width, height int) {
+	damage(s.bounds())
+	s.size = coord{width, height}
+	s.pos = s.clamp(s.pos)
+	s.layout()
+}
+
+func (s *SplitPane) getCursor() (x, y int, show bool) {
+	if w := s.focusedChild(); w != nil {
+		return w.getCursor()
+	}
+	return 0, 0, false
+}
+
+func (s *SplitPane) onKey(ev tb.Event) error {
+	alt := (ev.Mod & tb.ModAlt) != 0
+	vert := s.orient == SplitVertical
+	switch {
+	case ev.Key == tb.KeyF6:
+		if s.visible(1-s.focus) && focusable(s.panes[1-s.focus]) {
+			s.focus = 1 - s.focus
+		}
+	case alt && !vert && ev.Key == tb.KeyArrowLeft, alt && vert && ev.Key == tb.KeyArrowUp:
+		s.moveDivider(-1)
+	case alt && !vert && ev.Key == tb.KeyArrowRight, alt && vert && ev.Key == tb.KeyArrowDown:
+		s.moveDivider(+1)
+	default:
+		if w := s.focusedChild(); w != nil {
+			return w.onKey(ev)
+		}
+	}
+	return nil
+}
+
+func (s *SplitPane) onMouse(ev tb.Event) error {
+	if s.dragging {
+		switch {
+		case ev.Key == tb.MouseRelease:
+			s.dragging = false
+		case ev.Key == tb.MouseLeft:
+			if s.orient == SplitVertical {
+				s.SetDivider(ev.MouseY - s.corner.y)
+			} else {
+				s.SetDivider(ev.MouseX - s.corner.x)
+			}
+		}
+		return nil
+	}
+
+	if isPress(ev) || ev.Key == tb.MouseWheelUp || ev.Key == tb.MouseWheelDown {
+		s.pressed = nil
+		if contains(s.dividerRect(), ev.MouseX, ev.MouseY) {
+			if ev.Key == tb.MouseLeft {
+				s.onDividerClick()
+			}
+			return nil
+		}
+		if i := s.paneAt(ev.MouseX, ev.MouseY); i >= 0 {
+			s.pressed = s.panes[i]
+			if isPress(ev) && focusable(s.pressed) {
+				s.focus = i
+			}
+		}
+	}
+
+	w := s.pressed
+	if ev.Key == tb.MouseRelease {
+		s.pressed = nil
+	}
+	if w == nil {
+		return nil
+	}
+	return w.onMouse(ev)
+}
+
+// onDividerClick starts dragging the divider, or toggles the collapse of a
+// pane when the divider is double-clicked.
+func (s *SplitPane) onDividerClick() {
+	now := time.Now()
+	double := now.Sub(s.clickTime) < doubleClickTime
+	s.clickTime = now
+	if !double {
+		s.dragging = true
+		return
+	}
+
+	s.clickTime = time.Time{}
+	if s.collapsed >= 0 {
+		s.Expand()
+	} else {
+		s.Collapse(1)
+	}
+}
+
+func (s *SplitPane) onDraw(cv *Canvas) {
+	if s.dirty {
+		s.dirty = false
+		s.drawDivider(cv)
+		for i, w := range s.panes {
+			if s.visible(i) {
+				w.invalidate()
+			}
+		}
+	}
+	for i, w := range s.panes {
+		if s.visible(i) {
+			w.onDraw(cv.sub(w.bounds()))
+		}
+	}
+}
+
+// drawDivider draws the line separating the panes.
+func (s *SplitPane) drawDivider(cv *Canvas) {
+	d := s.dividerAt()
+	if s.orient == SplitVertical {
+		cv.HLine(0, d, s.size.x, theme.Fg, theme.Bg)
+	} else {
+		cv.VLine(d, 0, s.size.y, theme.Fg, theme.Bg)
+	}
+}
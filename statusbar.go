@@ -0,0 +1,197 @@
+package termwin
+
+import (
+	"fmt"
+	"strings"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+// An EditState is a snapshot of the state of an EditBox displayed by a
+// status bar bound to it.
+type EditState struct {
+	Line      int  // cursor row, starting at 0
+	Column    int  // cursor column, starting at 0
+	Lines     int  // number of rows in the buffer
+	Selected  int  // number of characters selected
+	Modified  bool // buffer changed since the modified flag was cleared
+	Overwrite bool // edit box is in overwrite mode
+}
+
+// A StatusFormatter formats the state of an EditBox for display in a status
+// bar segment.
+type StatusFormatter func(st EditState) string
+
+// DefaultStatus formats the state of an EditBox as the cursor position,
+// number of characters selected, modified flag and insert mode, for example
+// "Ln 12/40  Col 7  Sel 5  Modified  INS".
+func DefaultStatus(st EditState) string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "Ln %d/%d  Col %d", st.Line+1, st.Lines, st.Column+1)
+	if st.Selected > 0 {
+		fmt.Fprintf(&buf, "  Sel %d", st.Selected)
+	}
+	if st.Modified {
+		buf.WriteString("  Modified")
+	}
+	if st.Overwrite {
+		buf.WriteString("  OVR")
+	} else {
+		buf.WriteString("  INS")
+	}
+	return buf.String()
+}
+
+// A StatusBar is a single row of text divided into left-aligned, centered
+// and right-aligned segments. A segment may be bound to an EditBox, in which
+// case it is updated automatically as the edit box changes.
+type StatusBar struct {
+	corner   coord           // screen coordinate of left edge
+	width    int             // screen width of the bar
+	segments [3]string       // text of left, center and right segments
+	fg, bg   tb.Attribute    // colors of the bar
+	edit     *EditBox        // edit box bound to the bar, or nil
+	align    Alignment       // segment displaying the edit box state
+	format   StatusFormatter // formats the edit box state
+	state    EditState       // edit box state last displayed
+	dirty    bool            // bar needs to be redrawn
+}
+
+// NewStatusBar creates a new status bar at the specified screen position.
+func NewStatusBar(x, y, width int) *StatusBar {
+	s := &StatusBar{
+		corner: coord{x, y},
+		width:  width,
+		fg:     tb.ColorBlack,
+		bg:     tb.ColorWhite,
+		dirty:  true,
+	}
+	addWindow(s)
+	return s
+}
+
+// Text returns the text of one of the status bar's segments.
+func (s *StatusBar) Text(align Alignment) string {
+	return s.segments[align]
+}
+
+// SetText sets the text of one of the status bar's segments.
+func (s *StatusBar) SetText(align Alignment, text string) {
+	text = sanitizeLine(text)
+	if text != s.segments[align] {
+		s.segments[align] = text
+		s.dirty = true
+	}
+}
+
+// SetColors sets the foreground and background colors of the status bar.
+func (s *StatusBar) SetColors(fg, bg tb.Attribute) {
+	s.fg, s.bg = fg, bg
+	s.dirty = true
+}
+
+// Bind displays the state of an edit box in one of the status bar's
+// segments, formatted by f. If f is nil, DefaultStatus is used. The segment
+// is updated whenever the edit box's state changes. Passing a nil edit box
+// removes the binding.
+func (s *StatusBar) Bind(e *EditBox, align Alignment, f StatusFormatter) {
+	if f == nil {
+		f = DefaultStatus
+	}
+	s.edit, s.align, s.format = e, align, f
+	if e != nil {
+		s.state = e.state()
+		s.SetText(align, f(s.state))
+	}
+}
+
+// refresh updates the bound segment if the edit box's state has changed
+// since it was last displayed.
+func (s *StatusBar) refresh() {
+	if s.edit == nil {
+		return
+	}
+	if st := s.edit.state(); st != s.state {
+		s.state = st
+		s.SetText(s.align, s.format(st))
+	}
+}
+
+func (s *StatusBar) canFocus() bool {
+	return false
+}
+
+func (s *StatusBar) bounds() rect {
+	return newRect(s.corner.x, s.corner.y, s.width, 1)
+}
+
+func (s *StatusBar) dirtyRect() rect {
+	s.refresh()
+	if s.dirty {
+		return s.bounds()
+	}
+	return emptyRect
+}
+
+func (s *StatusBar) invalidate() {
+	s.dirty = true
+}
+
+func (s *StatusBar) move(x, y int) {
+	damage(s.bounds())
+	s.corner = coord{x, y}
+	s.dirty = true
+}
+
+// resize changes the width of the bar. The bar is always one row tall.
+func (s *StatusBar) resize(width, height int) {
+	damage(s.bounds())
+	s.width = width
+	s.dirty = true
+}
+
+func (s *StatusBar) getCursor() (x, y int, show bool) {
+	return 0, 0, false
+}
+
+func (s *StatusBar) onKey(ev tb.Event) error {
+	return nil
+}
+
+func (s *StatusBar) onMouse(ev tb.Event) error {
+	return nil
+}
+
+func (s *StatusBar) onDraw(cv *Canvas) {
+	if !s.dirty {
+		return
+	}
+	s.dirty = false
+
+	cv.Clear(s.fg, s.bg)
+
+	// Draw the center segment first, so the left and right segments take
+	// precedence when the segments overlap.
+	center := s.segments[AlignCenter]
+	cv.DrawText(max((s.width-textWidth(center))/2, 0), 0, s.width, center, s.fg, s.bg)
+
+	right := s.segments[AlignRight]
+	rx := max(s.width-textWidth(right)-1, 0)
+	cv.DrawText(rx, 0, s.width-rx, right, s.fg, s.bg)
+
+	cv.DrawText(1, 0, s.width-1, s.segments[AlignLeft], s.fg, s.bg)
+}
+
+// state returns a snapshot of the edit box's state for display in a status
+// bar.
+func (e *EditBox) state() EditState {
+	x, y := e.Cursor()
+	return EditState{
+		Line:      y,
+		Column:    x,
+		Lines:     e.LastRow() + 1,
+		Selected:  e.SelectionLen(),
+		Modified:  e.Modified(),
+		Overwrite: e.overwrite,
+	}
+}
@@ -0,0 +1,608 @@
+package termwin
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+// An Alignment determines how text is positioned within a space wider than
+// the text.
+type Alignment byte
+
+const (
+	// AlignLeft aligns text with the left edge of the space.
+	AlignLeft Alignment = iota
+
+	// AlignCenter centers text within the space.
+	AlignCenter
+
+	// AlignRight aligns text with the right edge of the space.
+	AlignRight
+)
+
+const (
+	tableSeparator = '│'
+	sortAscending  = '▲'
+	sortDescending = '▼'
+)
+
+// A TableColumn describes a single column of a Table.
+type TableColumn struct {
+	Title    string    // text displayed in the header row
+	Width    int       // width of the column in characters
+	MinWidth int       // minimum width when resizing, at least 1
+	MaxWidth int       // maximum width when resizing, or 0 for no limit
+	Align    Alignment // alignment of the column's cells
+}
+
+// A TableSource provides the cells displayed by a Table. Cells are
+// requested only when they are displayed or sorted.
+type TableSource interface {
+	// Rows returns the number of rows in the table.
+	Rows() int
+
+	// Cell returns the text of the cell at the given row and column.
+	Cell(row, col int) string
+}
+
+// A TableSorter is a TableSource that provides its own ordering of rows.
+// When a table's source does not implement TableSorter, rows are sorted by
+// comparing cell text, numerically if both cells contain numbers.
+type TableSorter interface {
+	TableSource
+
+	// Less returns true if row i sorts before row j in column col.
+	Less(col, i, j int) bool
+}
+
+// A Table displays rows of data in columns beneath a header row. The table
+// scrolls both horizontally and vertically, and its rows may be selected
+// and sorted by column. Columns may be resized with the keyboard or by
+// dragging their header separators with the mouse.
+type Table struct {
+	corner     coord         // screen coordinate of top-left corner
+	size       coord         // screen dimensions of the table
+	cols       []TableColumn // column definitions
+	source     TableSource   // source of cell data
+	order      []int         // source rows in display order, or nil
+	sortCol    int           // column the rows are sorted by, or -1
+	sortDesc   bool          // rows are sorted in descending order
+	top        int           // display index of the first visible row
+	scrollX    int           // horizontal scroll offset in characters
+	current    int           // display index of the selected row
+	curCol     int           // column with keyboard focus
+	resizing   int           // column being resized with the mouse, or -1
+	clickTime  time.Time     // time of the last mouse click on a row
+	fg, bg     tb.Attribute  // colors of unselected rows
+	selFg      tb.Attribute  // foreground color of the selected row
+	selBg      tb.Attribute  // background color of the selected row
+	dirty      bool          // table needs to be redrawn
+	onSelect   func(row int) // called when the selected row changes
+	onActivate func(row int) // called when a row is activated
+}
+
+// NewTable creates a new Table control with the specified screen position,
+// size and columns, displaying data from source.
+func NewTable(x, y, width, height int, cols []TableColumn, source TableSource) *Table {
+	t := newTable(x, y, width, height, cols, source)
+	addWindow(t)
+	return t
+}
+
+func newTable(x, y, width, height int, cols []TableColumn, source TableSource) *Table {
+	t := &Table{
+		corner:   coord{x, y},
+		size:     coord{width, height},
+		source:   source,
+		sortCol:  -1,
+		resizing: -1,
+		fg:       tb.ColorDefault,
+		bg:       tb.ColorDefault,
+		selFg:    tb.ColorBlack,
+		selBg:    tb.ColorWhite,
+		dirty:    true,
+	}
+	t.SetColumns(cols)
+	return t
+}
+
+// Columns returns the table's column definitions, including any width
+// changes made by the user.
+func (t *Table) Columns() []TableColumn {
+	return append([]TableColumn(nil), t.cols...)
+}
+
+// SetColumns replaces the table's column definitions.
+func (t *Table) SetColumns(cols []TableColumn) {
+	t.cols = append([]TableColumn(nil), cols...)
+	for i := range t.cols {
+		c := &t.cols[i]
+		c.MinWidth = max(c.MinWidth, 1)
+		c.Width = t.clampWidth(i, c.Width)
+	}
+	t.curCol = min(t.curCol, max(len(t.cols)-1, 0))
+	if t.sortCol >= len(t.cols) {
+		t.sortCol, t.order = -1, nil
+	}
+	t.dirty = true
+}
+
+// SetSource replaces the source of the table's data. The sort order is
+// cleared and the first row becomes selected.
+func (t *Table) SetSource(source TableSource) {
+	t.source = source
+	t.order, t.sortCol = nil, -1
+	t.top, t.current = 0, 0
+	t.dirty = true
+}
+
+// Refresh redraws the table after the data in its source has changed,
+// reapplying the current sort order.
+func (t *Table) Refresh() {
+	if t.sortCol >= 0 {
+		t.SortBy(t.sortCol, t.sortDesc)
+	}
+	t.current = min(t.current, max(t.rows()-1, 0))
+	t.updateView()
+	t.dirty = true
+}
+
+// SetColors sets the foreground and background colors of unselected rows.
+func (t *Table) SetColors(fg, bg tb.Attribute) {
+	t.fg, t.bg = fg, bg
+	t.dirty = true
+}
+
+// SetSelectedColors sets the foreground and background colors of the
+// selected row.
+func (t *Table) SetSelectedColors(fg, bg tb.Attribute) {
+	t.selFg, t.selBg = fg, bg
+	t.dirty = true
+}
+
+// OnSelect sets the function called with the source row index whenever the
+// selected row changes.
+func (t *Table) OnSelect(f func(row int)) {
+	t.onSelect = f
+}
+
+// OnActivate sets the function called with the source row index when the
+// user activates a row by pressing Enter or double-clicking it.
+func (t *Table) OnActivate(f func(row int)) {
+	t.onActivate = f
+}
+
+// Selected returns the source row index of the selected row, or -1 if the
+// table is empty.
+func (t *Table) Selected() int {
+	if t.rows() == 0 {
+		return -1
+	}
+	return t.sourceRow(t.current)
+}
+
+// SetSelected selects the row with the given source row index, scrolling
+// the table if necessary.
+func (t *Table) SetSelected(row int) {
+	t.moveTo(t.displayIndex(row))
+}
+
+// SortBy sorts the table's rows by a column. Passing a negative column
+// restores the source order.
+func (t *Table) SortBy(col int, descending bool) {
+	sel := t.Selected()
+	if col < 0 || col >= len(t.cols) || t.source == nil {
+		t.order, t.sortCol = nil, -1
+	} else {
+		n := t.source.Rows()
+		t.order = make([]int, n)
+		for i := range t.order {
+			t.order[i] = i
+		}
+		less := t.less(col)
+		sort.SliceStable(t.order, func(i, j int) bool {
+			if descending {
+				return less(t.order[j], t.order[i])
+			}
+			return less(t.order[i], t.order[j])
+		})
+		t.sortCol, t.sortDesc = col, descending
+	}
+	if sel >= 0 {
+		t.current = t.displayIndex(sel)
+		t.updateView()
+	}
+	t.dirty = true
+}
+
+// SortColumn returns the column the rows are sorted by, or -1 if the rows
+// are in source order, along with the sort direction.
+func (t *Table) SortColumn() (col int, descending bool) {
+	return t.sortCol, t.sortDesc
+}
+
+// ResizeColumn sets the width of a column, constrained by its minimum and
+// maximum widths.
+func (t *Table) ResizeColumn(col, width int) {
+	if col < 0 || col >= len(t.cols) {
+		return
+	}
+	t.cols[col].Width = t.clampWidth(col, width)
+	t.dirty = true
+}
+
+func (t *Table) bounds() rect {
+	return newRect(t.corner.x, t.corner.y, t.size.x, t.size.y)
+}
+
+func (t *Table) dirtyRect() rect {
+	if !t.dirty {
+		return emptyRect
+	}
+	return t.bounds()
+}
+
+func (t *Table) invalidate() {
+	t.dirty = true
+}
+
+func (t *Table) move(x, y int) {
+	damage(t.bounds())
+	t.corner = coord{x, y}
+	t.dirty = true
+}
+
+func (t *Table) resize(width, height int) {
+	damage(t.bounds())
+	t.size = coord{width, height}
+	t.updateView()
+	t.dirty = true
+}
+
+func (t *Table) getCursor() (x, y int, show bool) {
+	return 0, 0, false
+}
+
+func (t *Table) onKey(ev tb.Event) error {
+	page := max(t.size.y-2, 1)
+	ctrl := (ev.Mod & tb.ModCtrl) != 0
+
+	switch ev.Key {
+	case tb.KeyArrowUp, tb.KeyCtrlP:
+		t.moveTo(t.current - 1)
+	case tb.KeyArrowDown, tb.KeyCtrlN:
+		t.moveTo(t.current + 1)
+	case tb.KeyPgup:
+		t.moveTo(t.current - page)
+	case tb.KeyPgdn:
+		t.moveTo(t.current + page)
+	case tb.KeyHome:
+		t.moveTo(0)
+	case tb.KeyEnd:
+		t.moveTo(t.rows() - 1)
+	case tb.KeyArrowLeft:
+		if ctrl && len(t.cols) > 0 {
+			t.ResizeColumn(t.curCol, t.cols[t.curCol].Width-1)
+		} else {
+			t.focusColumn(t.curCol - 1)
+		}
+	case tb.KeyArrowRight:
+		if ctrl && len(t.cols) > 0 {
+			t.ResizeColumn(t.curCol, t.cols[t.curCol].Width+1)
+		} else {
+			t.focusColumn(t.curCol + 1)
+		}
+	case tb.KeyCtrlS:
+		t.toggleSort(t.curCol)
+	case tb.KeyEnter:
+		t.activate()
+	}
+	return nil
+}
+
+func (t *Table) onMouse(ev tb.Event) error {
+	x := ev.MouseX - t.corner.x + t.scrollX
+	y := ev.MouseY - t.corner.y
+
+	switch ev.Key {
+	case tb.MouseLeft:
+		if t.resizing >= 0 {
+			t.ResizeColumn(t.resizing, x-t.columnX(t.resizing))
+			return nil
+		}
+		if (ev.Mod & tb.ModMotion) != 0 {
+			if y > 0 {
+				t.moveTo(t.top + y - 1)
+			}
+			return nil
+		}
+
+		if y == 0 {
+			col, edge := t.columnAt(x)
+			switch {
+			case edge:
+				t.resizing = col
+			case col >= 0:
+				t.focusColumn(col)
+				t.toggleSort(col)
+			}
+			return nil
+		}
+
+		i := t.top + y - 1
+		if i >= t.rows() {
+			return nil
+		}
+		now := time.Now()
+		double := i == t.current && now.Sub(t.clickTime) < doubleClickTime
+		t.clickTime = now
+		t.moveTo(i)
+		if double {
+			t.activate()
+		}
+	case tb.MouseRelease:
+		t.resizing = -1
+	case tb.MouseWheelUp:
+		t.scroll(-mouseWheelRows)
+	case tb.MouseWheelDown:
+		t.scroll(+mouseWheelRows)
+	}
+	return nil
+}
+
+func (t *Table) onDraw(cv *Canvas) {
+	t.draw(cv)
+}
+
+// Draw updates the contents of the Table on the screen.
+func (t *Table) Draw() {
+	t.draw(newCanvas(t.bounds()))
+}
+
+// draw redraws the table on a canvas if it is dirty.
+func (t *Table) draw(cv *Canvas) {
+	if !t.dirty {
+		return
+	}
+	t.dirty = false
+
+	line := make([]tb.Cell, 0, t.contentWidth())
+
+	// Header row
+	hfg, hbg := tb.ColorDefault|tb.AttrBold|tb.AttrUnderline, tb.ColorDefault
+	for i, c := range t.cols {
+		title := c.Title
+		switch {
+		case i != t.sortCol:
+		case t.sortDesc:
+			title += string(sortDescending)
+		default:
+			title += string(sortAscending)
+		}
+		fg := hfg
+		if i == t.curCol {
+			fg |= tb.AttrReverse
+		}
+		line = appendAligned(line, title, c.Width, c.Align, fg, hbg)
+		line = append(line, tb.Cell{Ch: tableSeparator, Fg: hfg, Bg: hbg})
+	}
+	t.drawLine(cv, 0, line, hfg, hbg)
+
+	// Data rows
+	n := t.rows()
+	for y := 1; y < t.size.y; y++ {
+		line = line[:0]
+		fg, bg := t.fg, t.bg
+		if i := t.top + y - 1; i < n {
+			if i == t.current {
+				fg, bg = t.selFg, t.selBg
+			}
+			r := t.sourceRow(i)
+			for j, c := range t.cols {
+				line = appendAligned(line, t.source.Cell(r, j), c.Width, c.Align, fg, bg)
+				line = append(line, tb.Cell{Ch: tableSeparator, Fg: fg, Bg: bg})
+			}
+		}
+		t.drawLine(cv, y, line, fg, bg)
+	}
+}
+
+// drawLine copies the visible portion of a line of cells to row y of the
+// canvas. Space to the right of the line is filled using the fg and bg
+// colors.
+func (t *Table) drawLine(cv *Canvas, y int, line []tb.Cell, fg, bg tb.Attribute) {
+	n := 0
+	if t.scrollX < len(line) {
+		visible := line[t.scrollX:min(len(line), t.scrollX+t.size.x)]
+		cv.SetCells(0, y, visible)
+		n = len(visible)
+	}
+	cv.Fill(n, y, t.size.x-n, 1, charSpace, fg, bg)
+}
+
+// rows returns the number of rows in the table.
+func (t *Table) rows() int {
+	if t.source == nil {
+		return 0
+	}
+	return t.source.Rows()
+}
+
+// sourceRow converts a display index into a source row index.
+func (t *Table) sourceRow(i int) int {
+	if t.order != nil && i < len(t.order) {
+		return t.order[i]
+	}
+	return i
+}
+
+// displayIndex converts a source row index into a display index.
+func (t *Table) displayIndex(row int) int {
+	for i, r := range t.order {
+		if r == row {
+			return i
+		}
+	}
+	return row
+}
+
+// less returns the comparison function used to sort rows by a column.
+func (t *Table) less(col int) func(i, j int) bool {
+	if s, ok := t.source.(TableSorter); ok {
+		return func(i, j int) bool {
+			return s.Less(col, i, j)
+		}
+	}
+	return func(i, j int) bool {
+		a, b := t.source.Cell(i, col), t.source.Cell(j, col)
+		fa, erra := strconv.ParseFloat(a, 64)
+		fb, errb := strconv.ParseFloat(b, 64)
+		if erra == nil && errb == nil {
+			return fa < fb
+		}
+		return a < b
+	}
+}
+
+// toggleSort sorts the table by a column, reversing the sort direction if
+// the table is already sorted by the column.
+func (t *Table) toggleSort(col int) {
+	t.SortBy(col, col == t.sortCol && !t.sortDesc)
+}
+
+// moveTo selects the row at display index i, clamped to the table bounds.
+func (t *Table) moveTo(i int) {
+	n := t.rows()
+	if n == 0 {
+		return
+	}
+	i = min(max(i, 0), n-1)
+	if i == t.current {
+		return
+	}
+
+	t.current = i
+	t.updateView()
+	t.dirty = true
+	if t.onSelect != nil {
+		t.onSelect(t.sourceRow(i))
+	}
+}
+
+// scroll scrolls the table by dy rows without changing the selection.
+func (t *Table) scroll(dy int) {
+	top := min(max(t.top+dy, 0), max(t.rows()-(t.size.y-1), 0))
+	if top != t.top {
+		t.top = top
+		t.dirty = true
+	}
+}
+
+// updateView scrolls the table vertically so the selected row is visible.
+func (t *Table) updateView() {
+	visible := max(t.size.y-1, 1)
+	switch {
+	case t.current < t.top:
+		t.top = t.current
+	case t.current >= t.top+visible:
+		t.top = t.current - visible + 1
+	}
+}
+
+// focusColumn gives keyboard focus to a column and scrolls the table
+// horizontally so the column is visible.
+func (t *Table) focusColumn(col int) {
+	if col < 0 || col >= len(t.cols) {
+		return
+	}
+	t.curCol = col
+
+	x0 := t.columnX(col)
+	x1 := x0 + t.cols[col].Width + 1
+	switch {
+	case x0 < t.scrollX:
+		t.scrollX = x0
+	case x1 > t.scrollX+t.size.x:
+		t.scrollX = min(x0, x1-t.size.x)
+	}
+	t.dirty = true
+}
+
+// columnX returns the horizontal offset of a column's left edge within the
+// table's content.
+func (t *Table) columnX(col int) int {
+	x := 0
+	for i := 0; i < col; i++ {
+		x += t.cols[i].Width + 1
+	}
+	return x
+}
+
+// columnAt returns the column containing the horizontal content offset x,
+// or -1 if there is none. The edge result is true if x lies on the
+// separator following the column.
+func (t *Table) columnAt(x int) (col int, edge bool) {
+	for i, c := range t.cols {
+		if x < c.Width {
+			return i, false
+		}
+		if x == c.Width {
+			return i, true
+		}
+		x -= c.Width + 1
+	}
+	return -1, false
+}
+
+// contentWidth returns the total width of all columns and separators.
+func (t *Table) contentWidth() int {
+	return t.columnX(len(t.cols))
+}
+
+// clampWidth constrains a width to a column's minimum and maximum widths.
+func (t *Table) clampWidth(col, width int) int {
+	c := &t.cols[col]
+	if c.MaxWidth > 0 {
+		width = min(width, c.MaxWidth)
+	}
+	return max(width, c.MinWidth)
+}
+
+// activate calls the activation function with the selected row.
+func (t *Table) activate() {
+	if t.onActivate != nil && t.rows() > 0 {
+		t.onActivate(t.sourceRow(t.current))
+	}
+}
+
+// appendAligned appends cells containing a string aligned within a field of
+// the given width. Strings longer than the field are truncated.
+func appendAligned(cells []tb.Cell, s string, width int, a Alignment, fg, bg tb.Attribute) []tb.Cell {
+	r := []rune(s)
+	if len(r) > width {
+		r = r[:width]
+	}
+
+	pad := width - len(r)
+	left := 0
+	switch a {
+	case AlignCenter:
+		left = pad / 2
+	case AlignRight:
+		left = pad
+	}
+
+	for i := 0; i < left; i++ {
+		cells = append(cells, tb.Cell{Ch: charSpace, Fg: fg, Bg: bg})
+	}
+	for _, ch := range r {
+		cells = append(cells, tb.Cell{Ch: ch, Fg: fg, Bg: bg})
+	}
+	for i := left + len(r); i < width; i++ {
+		cells = append(cells, tb.Cell{Ch: charSpace, Fg: fg, Bg: bg})
+	}
+	return cells
+}
@@ -0,0 +1,416 @@
+package termwin
+
+import (
+	tb "github.com/nsf/termbox-go"
+)
+
+const (
+	charTabClose    = '×'
+	charTabModified = '*'
+	charScrollLeft  = '◄'
+	charScrollRight = '►'
+)
+
+// A modifiable window reports whether its contents have unsaved changes.
+// EditBox and TextInput are modifiable.
+type modifiable interface {
+	Modified() bool
+}
+
+// A tab is a page of a TabView.
+type tab struct {
+	title    string // text displayed on the tab
+	w        Window // window displayed when the tab is active
+	modified bool   // modified marker displayed when last drawn
+}
+
+// A TabView is a container displaying one of several windows, selected by a
+// strip of tabs along its top row. A tab whose window has unsaved changes,
+// such as a modified EditBox, is marked with an asterisk.
+//
+// Ctrl+PgUp and Ctrl+PgDn switch to the previous or next tab, Alt+1 through
+// Alt+9 switch to a tab by number, and Ctrl+W closes the active tab. Tabs are
+// also switched and closed by clicking them. When the tabs don't fit on the
+// strip, arrows at its right end scroll the strip.
+type TabView struct {
+	corner     coord            // screen coordinate of top-left corner
+	size       coord            // screen dimensions of the view
+	tabs       []*tab           // tabs, left to right
+	active     int              // index of the active tab, or -1
+	first      int              // index of the first visible tab
+	tabX       []int            // strip column of each tab, or -1
+	pressed    Window           // window receiving mouse events until release
+	onChange   func(i int)      // called when the active tab changes
+	onClose    func(i int) bool // called when the user closes a tab
+	stripDirty bool             // tab strip needs to be redrawn
+}
+
+// NewTabView creates a new TabView container with the specified screen
+// position and size. Windows displayed in the view's tabs should fit within
+// the area returned by ContentArea.
+func NewTabView(x, y, width, height int) *TabView {
+	t := &TabView{
+		corner:     coord{x, y},
+		size:       coord{width, height},
+		active:     -1,
+		stripDirty: true,
+	}
+	addWindow(t)
+	return t
+}
+
+// ContentArea returns the screen position and size of the area below the
+// tab strip in which tab windows are displayed.
+func (t *TabView) ContentArea() (x, y, width, height int) {
+	return t.corner.x, t.corner.y + 1, t.size.x, max(t.size.y-1, 0)
+}
+
+// AddTab adds a tab displaying a window and returns its index. The window is
+// removed from the screen's window stack and moved and resized to fill the
+// view's content area. The first tab added becomes the active tab.
+func (t *TabView) AddTab(title string, w Window) int {
+	RemoveWindow(w)
+	x, y, cw, ch := t.ContentArea()
+	w.move(x, y)
+	w.resize(cw, ch)
+
+	t.tabs = append(t.tabs, &tab{title: sanitizeLine(title), w: w})
+	t.stripDirty = true
+	if t.active < 0 {
+		t.SetActive(0)
+	}
+	return len(t.tabs) - 1
+}
+
+// RemoveTab removes a tab from the view. If it was the active tab, the tab
+// to its right, or else its left, becomes active.
+func (t *TabView) RemoveTab(i int) {
+	if i < 0 || i >= len(t.tabs) {
+		return
+	}
+	if t.pressed == t.tabs[i].w {
+		t.pressed = nil
+	}
+	t.tabs = append(t.tabs[:i], t.tabs[i+1:]...)
+	t.first = min(t.first, max(len(t.tabs)-1, 0))
+
+	switch {
+	case len(t.tabs) == 0:
+		t.active = -1
+		t.changed()
+	case i < t.active:
+		t.active--
+	case i == t.active:
+		t.active = -1
+		t.SetActive(min(i, len(t.tabs)-1))
+	}
+	t.stripDirty = true
+}
+
+// TabCount returns the number of tabs in the view.
+func (t *TabView) TabCount() int {
+	return len(t.tabs)
+}
+
+// Tab returns the window displayed by the i-th tab.
+func (t *TabView) Tab(i int) Window {
+	return t.tabs[i].w
+}
+
+// Title returns the title of the i-th tab.
+func (t *TabView) Title(i int) string {
+	return t.tabs[i].title
+}
+
+// SetTitle changes the title of the i-th tab.
+func (t *TabView) SetTitle(i int, title string) {
+	t.tabs[i].title = sanitizeLine(title)
+	t.stripDirty = true
+}
+
+// Active returns the index of the active tab, or -1 if there are no tabs.
+func (t *TabView) Active() int {
+	return t.active
+}
+
+// SetActive displays the i-th tab's window. Each tab's window keeps its own
+// state while hidden, so the cursor and the focus within a container are
+// restored when its tab becomes active again.
+func (t *TabView) SetActive(i int) {
+	if i < 0 || i >= len(t.tabs) || i == t.active {
+		return
+	}
+	t.active = i
+	t.pressed = nil
+	t.first = min(t.first, i)
+	for t.first < i && t.stripWidth(t.first, i+1) > t.tabSpace() {
+		t.first++
+	}
+	t.stripDirty = true
+	t.changed()
+}
+
+// OnChange sets the function called when the active tab changes. It
+// receives the index of the new active tab, or -1 if the last tab was
+// removed.
+func (t *TabView) OnChange(f func(i int)) {
+	t.onChange = f
+}
+
+// OnClose sets the function called when the user closes a tab. The tab is
+// removed only if the function returns true. If no function is set, tabs
+// are always removed.
+func (t *TabView) OnClose(f func(i int) bool) {
+	t.onClose = f
+}
+
+// requestClose closes a tab at the user's request.
+func (t *TabView) requestClose(i int) {
+	if t.onClose == nil || t.onClose(i) {
+		t.RemoveTab(i)
+	}
+}
+
+// changed clears the content area and notifies the change function after
+// the active tab changes.
+func (t *TabView) changed() {
+	x, y, w, h := t.ContentArea()
+	damage(newRect(x, y, w, h))
+	if t.onChange != nil {
+		t.onChange(t.active)
+	}
+}
+
+// label returns the text displayed on a tab.
+func (tp *tab) label() string {
+	mark := " "
+	if tp.modified {
+		mark = string(charTabModified)
+	}
+	return " " + tp.title + mark + string(charTabClose) + " "
+}
+
+// stripWidth returns the number of columns occupied by tabs [i0:i1].
+func (t *TabView) stripWidth(i0, i1 int) int {
+	n := 0
+	for _, tp := range t.tabs[i0:i1] {
+		n += textWidth(tp.label())
+	}
+	return n
+}
+
+// overflow returns true if the tabs are too wide to fit on the strip.
+func (t *TabView) overflow() bool {
+	return t.stripWidth(0, len(t.tabs)) > t.size.x
+}
+
+// tabSpace returns the number of strip columns available for tabs.
+func (t *TabView) tabSpace() int {
+	if t.overflow() {
+		return max(t.size.x-2, 0)
+	}
+	return t.size.x
+}
+
+// scroll scrolls the tab strip left (dir < 0) or right (dir > 0) by one
+// tab.
+func (t *TabView) scroll(dir int) {
+	first := min(max(t.first+dir, 0), max(len(t.tabs)-1, 0))
+	if first != t.first {
+		t.first = first
+		t.stripDirty = true
+	}
+}
+
+// refreshMarks updates the modified markers of the tabs.
+func (t *TabView) refreshMarks() {
+	for _, tp := range t.tabs {
+		m, ok := tp.w.(modifiable)
+		if modified := ok && m.Modified(); modified != tp.modified {
+			tp.modified = modified
+			t.stripDirty = true
+		}
+	}
+}
+
+// activeWindow returns the window of the active tab, or nil.
+func (t *TabView) activeWindow() Window {
+	if t.active < 0 {
+		return nil
+	}
+	return t.tabs[t.active].w
+}
+
+func (t *TabView) focusedChild() Window {
+	return t.activeWindow()
+}
+
+func (t *TabView) bounds() rect {
+	return newRect(t.corner.x, t.corner.y, t.size.x, t.size.y)
+}
+
+func (t *TabView) dirtyRect() rect {
+	t.refreshMarks()
+	r := emptyRect
+	if t.stripDirty {
+		r = newRect(t.corner.x, t.corner.y, t.size.x, 1)
+	}
+	if w := t.activeWindow(); w != nil {
+		r = union(r, w.dirtyRect())
+	}
+	return r
+}
+
+func (t *TabView) invalidate() {
+	t.stripDirty = true
+	if w := t.activeWindow(); w != nil {
+		w.invalidate()
+	}
+}
+
+func (t *TabView) move(x, y int) {
+	damage(t.bounds())
+	t.corner = coord{x, y}
+	cx, cy, _, _ := t.ContentArea()
+	for _, tp := range t.tabs {
+		tp.w.move(cx, cy)
+	}
+	t.stripDirty = true
+}
+
+// resize changes the size of the view and resizes every tab's window to
+// fill the new content area.
+func (t *TabView) resize(width, height int) {
+	damage(t.bounds())
+	t.size = coord{width, height}
+	_, _, cw, ch := t.ContentArea()
+	for _, tp := range t.tabs {
+		tp.w.resize(cw, ch)
+	}
+	for t.active >= 0 && t.first < t.active && t.stripWidth(t.first, t.active+1) > t.tabSpace() {
+		t.first++
+	}
+	t.stripDirty = true
+}
+
+func (t *TabView) getCursor() (x, y int, show bool) {
+	if w := t.activeWindow(); w != nil {
+		return w.getCursor()
+	}
+	return 0, 0, false
+}
+
+// claimsKey claims the Alt+digit tab commands from menu accelerators.
+func (t *TabView) claimsKey(ev tb.Event) bool {
+	return (ev.Mod&tb.ModAlt) != 0 && ev.Ch >= '1' && ev.Ch <= '9'
+}
+
+func (t *TabView) onKey(ev tb.Event) error {
+	ctrl := (ev.Mod & tb.ModCtrl) != 0
+	switch {
+	case ev.Key == tb.KeyPgup && ctrl:
+		if n := len(t.tabs); n > 0 {
+			t.SetActive((t.active - 1 + n) % n)
+		}
+	case ev.Key == tb.KeyPgdn && ctrl:
+		if n := len(t.tabs); n > 0 {
+			t.SetActive((t.active + 1) % n)
+		}
+	case ev.Key == tb.KeyCtrlW:
+		if t.active >= 0 {
+			t.requestClose(t.active)
+		}
+	case (ev.Mod&tb.ModAlt) != 0 && ev.Ch >= '1' && ev.Ch <= '9':
+		t.SetActive(int(ev.Ch - '1'))
+	default:
+		if w := t.activeWindow(); w != nil {
+			return w.onKey(ev)
+		}
+	}
+	return nil
+}
+
+func (t *TabView) onMouse(ev tb.Event) error {
+	if isPress(ev) || ev.Key == tb.MouseWheelUp || ev.Key == tb.MouseWheelDown {
+		t.pressed = nil
+		if ev.MouseY == t.corner.y {
+			t.onStripMouse(ev)
+			return nil
+		}
+		t.pressed = t.activeWindow()
+	}
+
+	w := t.pressed
+	if ev.Key == tb.MouseRelease {
+		t.pressed = nil
+	}
+	if w == nil {
+		return nil
+	}
+	return w.onMouse(ev)
+}
+
+// onStripMouse handles a mouse event on the tab strip.
+func (t *TabView) onStripMouse(ev tb.Event) {
+	x := ev.MouseX - t.corner.x
+	switch {
+	case ev.Key == tb.MouseWheelUp:
+		t.scroll(-1)
+	case ev.Key == tb.MouseWheelDown:
+		t.scroll(+1)
+	case ev.Key != tb.MouseLeft:
+	case t.overflow() && x == t.size.x-2:
+		t.scroll(-1)
+	case t.overflow() && x == t.size.x-1:
+		t.scroll(+1)
+	default:
+		for i, tx := range t.tabX {
+			w := textWidth(t.tabs[i].label())
+			if tx < 0 || x < tx || x >= tx+w {
+				continue
+			}
+			if x == tx+w-2 {
+				t.requestClose(i)
+			} else {
+				t.SetActive(i)
+			}
+			break
+		}
+	}
+}
+
+func (t *TabView) onDraw(cv *Canvas) {
+	if t.stripDirty {
+		t.stripDirty = false
+		t.drawStrip(cv)
+	}
+	if w := t.activeWindow(); w != nil {
+		w.onDraw(cv.sub(w.bounds()))
+	}
+}
+
+// drawStrip draws the tab strip along the top row of the view.
+func (t *TabView) drawStrip(cv *Canvas) {
+	cv.Fill(0, 0, t.size.x, 1, charSpace, theme.TabFg, theme.TabBg)
+
+	space := t.tabSpace()
+	t.tabX = t.tabX[:0]
+	x := 0
+	for i, tp := range t.tabs {
+		if i < t.first || x >= space {
+			t.tabX = append(t.tabX, -1)
+			continue
+		}
+		t.tabX = append(t.tabX, x)
+		fg, bg := theme.TabFg, theme.TabBg
+		if i == t.active {
+			fg, bg = theme.TabActiveFg, theme.TabActiveBg
+		}
+		x += cv.DrawText(x, 0, space-x, tp.label(), fg, bg)
+	}
+
+	if t.overflow() && t.size.x >= 2 {
+		cv.SetCell(t.size.x-2, 0, charScrollLeft, theme.TabFg, theme.TabBg)
+		cv.SetCell(t.size.x-1, 0, charScrollRight, theme.TabFg, theme.TabBg)
+	}
+}
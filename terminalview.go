@@ -0,0 +1,294 @@
+package termwin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"unicode/utf8"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+// terminalReadSize is the size of the buffer used to read a terminal
+// view's output.
+const terminalReadSize = 4096
+
+// A terminalKey describes the escape sequence sent to a terminal view's
+// program for a special key. Keys with a number are sent as "ESC [ number ~";
+// other keys are sent as "ESC [ final", or "ESC O final" for function keys
+// and for cursor keys in application cursor mode.
+type terminalKey struct {
+	number string // parameter of a "~" sequence, or empty
+	final  byte   // final byte of a sequence without a number
+	ss3    bool   // key is sent with SS3 (ESC O) when unmodified
+}
+
+// terminalKeys maps special keys to the sequences sent for them.
+var terminalKeys = map[tb.Key]terminalKey{
+	tb.KeyArrowUp:    {final: 'A'},
+	tb.KeyArrowDown:  {final: 'B'},
+	tb.KeyArrowRight: {final: 'C'},
+	tb.KeyArrowLeft:  {final: 'D'},
+	tb.KeyHome:       {final: 'H'},
+	tb.KeyEnd:        {final: 'F'},
+	tb.KeyInsert:     {number: "2"},
+	tb.KeyDelete:     {number: "3"},
+	tb.KeyPgup:       {number: "5"},
+	tb.KeyPgdn:       {number: "6"},
+	tb.KeyF1:         {final: 'P', ss3: true},
+	tb.KeyF2:         {final: 'Q', ss3: true},
+	tb.KeyF3:         {final: 'R', ss3: true},
+	tb.KeyF4:         {final: 'S', ss3: true},
+	tb.KeyF5:         {number: "15"},
+	tb.KeyF6:         {number: "17"},
+	tb.KeyF7:         {number: "18"},
+	tb.KeyF8:         {number: "19"},
+	tb.KeyF9:         {number: "20"},
+	tb.KeyF10:        {number: "21"},
+	tb.KeyF11:        {number: "23"},
+	tb.KeyF12:        {number: "24"},
+}
+
+// A TerminalView is a window running a command in a pseudo-terminal. The
+// command's output is interpreted as a VT100/xterm terminal would,
+// including cursor movement, SGR colors, scroll regions and the alternate
+// screen. Keys pressed while the view has the focus are sent to the
+// command, and resizing the view resizes the pseudo-terminal, which
+// signals the command with SIGWINCH.
+//
+// The command's output is read on a separate goroutine and applied to the
+// view through Post, so the application must keep calling Poll for the
+// view to update. Pseudo-terminals are currently supported on Linux only.
+type TerminalView struct {
+	corner  coord                            // screen coordinate of top-left corner
+	size    coord                            // screen dimensions of the view
+	screen  *vtScreen                        // emulated terminal screen
+	pty     *os.File                         // master side of the pseudo-terminal
+	cmd     *exec.Cmd                        // command running in the terminal
+	exited  bool                             // the command has exited
+	closing bool                             // the pseudo-terminal is closed or closing
+	dirty   bool                             // view needs to be redrawn
+	onExit  func(t *TerminalView, err error) // called when the command exits
+}
+
+// NewTerminalView creates a new TerminalView with the specified screen
+// position and size, and starts cmd in it. The command's standard input,
+// output and error are connected to the pseudo-terminal, and TERM is set
+// to "xterm" in its environment.
+func NewTerminalView(x, y, width, height int, cmd *exec.Cmd) (*TerminalView, error) {
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env, "TERM=xterm")
+
+	pty, err := startPty(cmd, width, height)
+	if err != nil {
+		return nil, err
+	}
+	t := &TerminalView{
+		corner: coord{x, y},
+		size:   coord{width, height},
+		screen: newVTScreen(width, height),
+		pty:    pty,
+		cmd:    cmd,
+		dirty:  true,
+	}
+	go t.read()
+	addWindow(t)
+	return t, nil
+}
+
+// OnExit sets the function called when the command exits. The error is
+// the result of waiting for the command.
+func (t *TerminalView) OnExit(f func(t *TerminalView, err error)) {
+	t.onExit = f
+}
+
+// Exited returns true if the command has exited.
+func (t *TerminalView) Exited() bool {
+	return t.exited
+}
+
+// Send writes text to the command as if it had been typed.
+func (t *TerminalView) Send(s string) error {
+	if t.exited {
+		return os.ErrClosed
+	}
+	_, err := t.pty.Write([]byte(s))
+	return err
+}
+
+// Close kills the command if it is still running and closes the
+// pseudo-terminal. The view remains on the screen until it is removed.
+func (t *TerminalView) Close() {
+	if t.closing {
+		return
+	}
+	t.closing = true
+	if !t.exited {
+		t.cmd.Process.Kill()
+	}
+	t.pty.Close()
+}
+
+// read feeds the command's output to the view until the pseudo-terminal
+// is closed, then waits for the command to exit. It runs on its own
+// goroutine.
+func (t *TerminalView) read() {
+	for {
+		buf := make([]byte, terminalReadSize)
+		n, err := t.pty.Read(buf)
+		if n > 0 {
+			Post(func() { t.output(buf[:n]) })
+		}
+		if err != nil {
+			// Linux reports EIO rather than EOF once the command and all
+			// its children have closed the terminal.
+			break
+		}
+	}
+	err := t.cmd.Wait()
+	Post(func() { t.exit(err) })
+}
+
+// output applies the command's output to the screen, and sends back any
+// responses the output requested, such as cursor position reports.
+func (t *TerminalView) output(p []byte) {
+	t.screen.Write(p)
+	if reply := t.screen.takeReply(); len(reply) > 0 && !t.closing {
+		t.pty.Write(reply)
+	}
+	t.dirty = true
+}
+
+// exit records the exit of the command.
+func (t *TerminalView) exit(err error) {
+	t.exited = true
+	t.dirty = true
+	if !t.closing {
+		t.closing = true
+		t.pty.Close()
+	}
+	logger.Debug("terminal command exited", "path", t.cmd.Path, "err", err)
+	if t.onExit != nil {
+		t.onExit(t, err)
+	}
+}
+
+// keyInput returns the bytes a terminal sends for a key event, or nil if
+// the key has no encoding.
+func (t *TerminalView) keyInput(ev tb.Event) []byte {
+	var b []byte
+	switch {
+	case ev.Ch != 0:
+		b = utf8.AppendRune(b, ev.Ch)
+	case ev.Key <= tb.KeySpace || ev.Key == tb.KeyBackspace2:
+		b = append(b, byte(ev.Key))
+	default:
+		k, ok := terminalKeys[ev.Key]
+		if !ok {
+			return nil
+		}
+		return []byte(t.keySeq(k, ev.Mod))
+	}
+	if (ev.Mod & tb.ModAlt) != 0 {
+		b = append([]byte{0x1b}, b...)
+	}
+	return b
+}
+
+// keySeq returns the escape sequence of a special key. When modifier keys
+// are held, the sequence carries the xterm modifier parameter: one plus 1
+// for shift, 2 for alt and 4 for ctrl.
+func (t *TerminalView) keySeq(k terminalKey, mod tb.Modifier) string {
+	m := 1
+	if (mod & tb.ModShift) != 0 {
+		m++
+	}
+	if (mod & tb.ModAlt) != 0 {
+		m += 2
+	}
+	if (mod & tb.ModCtrl) != 0 {
+		m += 4
+	}
+
+	switch {
+	case k.number != "" && m == 1:
+		return "\x1b[" + k.number + "~"
+	case k.number != "":
+		return fmt.Sprintf("\x1b[%s;%d~", k.number, m)
+	case m != 1:
+		return fmt.Sprintf("\x1b[1;%d%c", m, k.final)
+	case k.ss3 || t.screen.appCursor:
+		return "\x1bO" + string(k.final)
+	}
+	return "\x1b[" + string(k.final)
+}
+
+func (t *TerminalView) bounds() rect {
+	return newRect(t.corner.x, t.corner.y, t.size.x, t.size.y)
+}
+
+func (t *TerminalView) dirtyRect() rect {
+	if !t.dirty {
+		return emptyRect
+	}
+	return t.bounds()
+}
+
+func (t *TerminalView) invalidate() {
+	t.dirty = true
+}
+
+func (t *TerminalView) move(x, y int) {
+	damage(t.bounds())
+	t.corner = coord{x, y}
+	t.dirty = true
+}
+
+// resize changes the size of the view and of its pseudo-terminal.
+func (t *TerminalView) resize(width, height int) {
+	damage(t.bounds())
+	t.size = coord{width, height}
+	t.screen.resize(width, height)
+	if !t.closing {
+		if err := setPtySize(t.pty, width, height); err != nil {
+			logger.Warn("terminal resize failed", "err", err)
+		}
+	}
+	t.dirty = true
+}
+
+func (t *TerminalView) getCursor() (x, y int, show bool) {
+	s := t.screen
+	return t.corner.x + s.cursor.x, t.corner.y + s.cursor.y, s.showCursor && !t.exited
+}
+
+func (t *TerminalView) onKey(ev tb.Event) error {
+	if t.exited {
+		return nil
+	}
+	if b := t.keyInput(ev); b != nil {
+		if _, err := t.pty.Write(b); err != nil {
+			logger.Warn("terminal write failed", "err", err)
+		}
+	}
+	return nil
+}
+
+func (t *TerminalView) onMouse(ev tb.Event) error {
+	return nil
+}
+
+func (t *TerminalView) onDraw(cv *Canvas) {
+	if !t.dirty {
+		return
+	}
+	t.dirty = false
+
+	cv.Clear(tb.ColorDefault, tb.ColorDefault)
+	for y, row := range t.screen.rows {
+		cv.SetCells(0, y, row)
+	}
+}
@@ -1,33 +1,59 @@
 package termwin
 
 import (
+	"sync"
+
 	tb "github.com/nsf/termbox-go"
 )
 
-var c context
+// feedBatchLines is the maximum number of strings feedBatches collects
+// from a channel before posting them.
+const feedBatchLines = 256
+
+var c = context{damage: emptyRect}
+
+// posted holds the functions queued by Post until Poll runs them.
+var posted struct {
+	mu    sync.Mutex
+	funcs []func()
+}
 
 type context struct {
-	windows []Window
-	focus   Window
-	kb      []byte
-	escaped bool
+	windows  []Window     // all windows, ordered from bottom to top
+	modal    []modalState // stack of modal windows
+	focus    Window
+	capture  Window        // window receiving mouse events until button release
+	damage   rect          // screen area exposed since the last flush
+	menuBar  *MenuBar      // menu bar receiving menu accelerator keys
+	console  *DebugConsole // debug console receiving input events, or nil
+	onResize func(width, height int)
+	kb       []byte
+	escaped  bool
 }
 
-func addWindow(w Window) {
-	if len(c.windows) == 0 {
-		c.focus = w
+// An Option configures the termwin system when it is initialized.
+type Option func()
+
+// WithClipboard selects the clipboard provider used by termwin controls. By
+// default, the clipboard is stored in memory.
+func WithClipboard(cb Clipboard) Option {
+	return func() {
+		SetClipboardProvider(cb)
 	}
-	c.windows = append(c.windows, w)
 }
 
 // Init must be called before any termwin controls can be used.
-func Init() error {
+func Init(options ...Option) error {
 	err := tb.Init()
 	if err != nil {
 		return err
 	}
 
-	tb.SetInputMode(tb.InputAlt)
+	for _, o := range options {
+		o()
+	}
+
+	tb.SetInputMode(tb.InputAlt | tb.InputMouse)
 	return nil
 }
 
@@ -42,9 +68,37 @@ func Size() (x, y int) {
 }
 
 // Flush flushes the contents of the back buffer to the screen display.
+// Windows are drawn from the bottom of the stack to the top. A window is
+// redrawn in its entirety if it overlaps an area of the screen that was
+// exposed or redrawn by a window beneath it.
 func Flush() {
+	d := c.console
+	if d != nil {
+		d.restoreHighlights()
+		if d.visible {
+			Raise(d)
+		}
+	}
+
+	damage := c.damage
+	c.damage = emptyRect
+	clearRect(damage)
+
+	var redrawn []rect
 	for _, w := range c.windows {
-		w.onDraw()
+		if intersects(damage, w.bounds()) {
+			w.invalidate()
+		}
+		r := w.dirtyRect()
+		damage = union(damage, r)
+		if d != nil && d.highlight && w != Window(d) && !r.empty() {
+			redrawn = append(redrawn, r)
+		}
+		w.onDraw(newCanvas(w.bounds()))
+	}
+
+	if len(redrawn) > 0 {
+		d.highlightRects(redrawn)
 	}
 
 	if c.focus == nil {
@@ -61,22 +115,95 @@ func Flush() {
 	tb.Flush()
 }
 
+// OnResize sets the function called after the terminal is resized, so the
+// application can move and resize its windows to fit the new screen
+// dimensions.
+func OnResize(f func(width, height int)) {
+	c.onResize = f
+}
+
 // SetFocus removes the cursor focus from any window it is currently on and
 // adds focus to the specified window. If you pass nil for the window,
-// SetFocus removes focus from all windows.
+// SetFocus removes focus from all windows. While a modal window is shown,
+// the focus cannot be moved away from it.
 func SetFocus(w Window) {
+	if len(c.modal) > 0 {
+		return
+	}
 	c.focus = w
 }
 
+// Focus returns the window that currently has the focus, or nil.
+func Focus() Window {
+	return c.focus
+}
+
+// Post queues a function to be run by Poll on the goroutine handling input
+// events, waking Poll if it is waiting. It may be called from any
+// goroutine, and is how background work such as reading a child process's
+// output safely updates windows.
+func Post(f func()) {
+	posted.mu.Lock()
+	wake := len(posted.funcs) == 0
+	posted.funcs = append(posted.funcs, f)
+	posted.mu.Unlock()
+	if wake {
+		go tb.Interrupt()
+	}
+}
+
+// feedBatches receives strings from a channel on a new goroutine until the
+// channel is closed, and posts them to add in batches, so that a fast
+// producer does not post a function for every string. Once the channel is
+// closed, done is posted if it is not nil.
+func feedBatches(ch <-chan string, add func(batch []string), done func()) {
+	go func() {
+		for s := range ch {
+			batch := []string{s}
+		collect:
+			for len(batch) < feedBatchLines {
+				select {
+				case s, ok := <-ch:
+					if !ok {
+						break collect
+					}
+					batch = append(batch, s)
+				default:
+					break collect
+				}
+			}
+			Post(func() { add(batch) })
+		}
+		if done != nil {
+			Post(done)
+		}
+	}()
+}
+
+// runPosted runs the functions queued by Post.
+func runPosted() {
+	posted.mu.Lock()
+	funcs := posted.funcs
+	posted.funcs = nil
+	posted.mu.Unlock()
+	for _, f := range funcs {
+		f()
+	}
+}
+
 // Poll polls the system for an input event
 func Poll() error {
 	switch ev := tb.PollEvent(); ev.Type {
 	case tb.EventKey:
-		Logf("Ch=0x%02X Key=0x%04X Mod=0x%02X\n", ev.Ch, ev.Key, ev.Mod)
+		if logDebugEnabled() {
+			logger.Debug("key event", "ch", ev.Ch, "key", ev.Key, "mod", ev.Mod)
+		}
+		seq := ""
 		if ev.Ch != 0 {
 			if c.escaped {
 				c.kb = append(c.kb, byte(ev.Ch))
 				if (ev.Ch >= 'a' && ev.Ch <= 'z') || (ev.Ch >= 'A' && ev.Ch <= 'Z') || ev.Ch == '~' {
+					seq = string(c.kb)
 					handleEscSeq(&ev)
 					c.escaped, c.kb = false, c.kb[:0]
 				} else {
@@ -89,6 +216,18 @@ func Poll() error {
 			}
 		}
 
+		if c.console != nil {
+			c.console.recordEvent(ev, seq)
+			if c.console.isToggle(ev) {
+				c.console.Toggle()
+				break
+			}
+		}
+
+		if c.menuBar != nil && len(c.modal) == 0 && !focusClaimsKey(ev) && c.menuBar.accelerate(ev) {
+			break
+		}
+
 		if c.focus != nil {
 			err := c.focus.onKey(ev)
 			if err != nil {
@@ -96,6 +235,21 @@ func Poll() error {
 			}
 		}
 
+	case tb.EventMouse:
+		if c.console != nil {
+			c.console.recordEvent(ev, "")
+		}
+		return handleMouse(ev)
+
+	case tb.EventResize:
+		if c.console != nil {
+			c.console.recordEvent(ev, "")
+		}
+		handleResize(ev.Width, ev.Height)
+
+	case tb.EventInterrupt:
+		runPosted()
+
 	case tb.EventError:
 		return ev.Err
 	}
@@ -103,6 +257,75 @@ func Poll() error {
 	return nil
 }
 
+// handleResize redraws the entire screen after the terminal is resized.
+func handleResize(width, height int) {
+	// Clearing makes termbox resize its back buffer before windows are
+	// redrawn into it.
+	tb.Clear(tb.ColorDefault, tb.ColorDefault)
+	damage(newRect(0, 0, width, height))
+	if c.onResize != nil {
+		c.onResize(width, height)
+	}
+}
+
+// handleMouse delivers a mouse event to the window under the mouse pointer.
+// A window receiving a button press captures all mouse events until the
+// button is released, and takes the focus.
+func handleMouse(ev tb.Event) error {
+	w := c.capture
+	if w == nil {
+		w = windowAt(ev.MouseX, ev.MouseY)
+		if m := topModal(); m != nil && w != m && !ownedBy(w, m) {
+			if p, ok := m.(popup); ok && isPress(ev) {
+				return p.dismiss(ev)
+			}
+			return nil
+		}
+		if w == nil {
+			return nil
+		}
+	}
+
+	switch ev.Key {
+	case tb.MouseRelease:
+		c.capture = nil
+	case tb.MouseLeft, tb.MouseMiddle, tb.MouseRight:
+		c.capture = w
+		if (ev.Mod&tb.ModMotion) == 0 && focusable(w) {
+			c.focus = w
+		}
+	}
+
+	return w.onMouse(ev)
+}
+
+// isPress returns true if a mouse event is a button press rather than a
+// release, drag or wheel movement.
+func isPress(ev tb.Event) bool {
+	switch ev.Key {
+	case tb.MouseLeft, tb.MouseMiddle, tb.MouseRight:
+		return (ev.Mod & tb.ModMotion) == 0
+	}
+	return false
+}
+
+// clearRect clears the cells of a screen rectangle.
+func clearRect(r rect) {
+	newCanvas(r).Clear(tb.ColorDefault, tb.ColorDefault)
+}
+
+// windowAt returns the top-most window containing the screen position, or
+// nil if there is none.
+func windowAt(x, y int) Window {
+	for i := len(c.windows) - 1; i >= 0; i-- {
+		w := c.windows[i]
+		if contains(w.bounds(), x, y) {
+			return w
+		}
+	}
+	return nil
+}
+
 type keymod struct {
 	Key tb.Key
 	Mod tb.Modifier
@@ -117,6 +340,16 @@ var escSeq = map[string]keymod{
 	"1;5A": {tb.KeyArrowUp, tb.ModCtrl},
 	"1;2B": {tb.KeyArrowDown, tb.ModShift},
 	"1;5B": {tb.KeyArrowDown, tb.ModCtrl},
+	"1;3C": {tb.KeyArrowRight, tb.ModAlt},
+	"1;3D": {tb.KeyArrowLeft, tb.ModAlt},
+	"1;3A": {tb.KeyArrowUp, tb.ModAlt},
+	"1;3B": {tb.KeyArrowDown, tb.ModAlt},
+	"1;4C": {tb.KeyArrowRight, tb.ModAlt | tb.ModShift},
+	"1;4D": {tb.KeyArrowLeft, tb.ModAlt | tb.ModShift},
+	"1;4A": {tb.KeyArrowUp, tb.ModAlt | tb.ModShift},
+	"1;4B": {tb.KeyArrowDown, tb.ModAlt | tb.ModShift},
+	"1;7A": {tb.KeyArrowUp, tb.ModAlt | tb.ModCtrl},
+	"1;7B": {tb.KeyArrowDown, tb.ModAlt | tb.ModCtrl},
 	"1;2F": {tb.KeyEnd, tb.ModShift},
 	"1;5F": {tb.KeyEnd, tb.ModCtrl},
 	"1;6F": {tb.KeyEnd, tb.ModCtrl | tb.ModShift},
@@ -127,10 +360,13 @@ var escSeq = map[string]keymod{
 	"5;5~": {tb.KeyPgup, tb.ModCtrl},
 	"6;2~": {tb.KeyPgdn, tb.ModShift},
 	"6;5~": {tb.KeyPgdn, tb.ModCtrl},
+	"Z":    {tb.KeyTab, tb.ModShift},
 }
 
 func handleEscSeq(ev *tb.Event) {
 	s := escSeq[string(c.kb)]
 	ev.Ch, ev.Key, ev.Mod = 0, s.Key, s.Mod
-	//Logf("%s => %d,%d\n", string(c.kb), ev.Key, ev.Mod)
+	if logDebugEnabled() {
+		logger.Debug("escape sequence", "seq", string(c.kb), "key", ev.Key, "mod", ev.Mod)
+	}
 }
@@ -0,0 +1,583 @@
+package termwin
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+// ghostFg is the foreground attribute used to draw placeholder text and
+// inline completion candidates.
+const ghostFg = tb.ColorBlack | tb.AttrBold
+
+// maxPopupRows is the maximum number of completion candidates displayed in
+// a completion popup at once.
+const maxPopupRows = 8
+
+// A Completer provides tab-completion candidates for a TextInput.
+type Completer interface {
+	// Complete returns the candidates for completing the text at the
+	// cursor position pos, measured in characters. Each candidate replaces
+	// the text in the range [start:pos].
+	Complete(text string, pos int) (start int, candidates []string)
+}
+
+// A CompleterFunc is a function that implements the Completer interface.
+type CompleterFunc func(text string, pos int) (start int, candidates []string)
+
+// Complete calls f(text, pos).
+func (f CompleterFunc) Complete(text string, pos int) (start int, candidates []string) {
+	return f(text, pos)
+}
+
+// A CompletionStyle determines how a TextInput displays multiple completion
+// candidates.
+type CompletionStyle byte
+
+const (
+	// CompletionInline displays the candidates on the input line following
+	// the text.
+	CompletionInline CompletionStyle = iota
+
+	// CompletionPopup displays the candidates in a list below the input
+	// line.
+	CompletionPopup
+)
+
+// A TextInput is a single-line text input control suitable for command
+// prompts and form fields. It supports horizontal scrolling, placeholder
+// text, password masking, input history and tab completion.
+type TextInput struct {
+	screenBox
+	placeholder string            // text displayed when the input is empty
+	mask        rune              // character displayed in place of text, or 0
+	history     []string          // previously submitted text, oldest first
+	histPos     int               // index of the history entry being edited
+	draft       string            // unsubmitted text saved when browsing history
+	completer   Completer         // tab-completion provider
+	style       CompletionStyle   // display style of completion candidates
+	completing  bool              // completion candidates are displayed
+	compStart   int               // column where the completed text starts
+	candidates  []string          // current completion candidates
+	candIndex   int               // candidate currently inserted, or -1
+	popup       *completionPopup  // window displaying candidates, or nil
+	onSubmit    func(text string) // called when Enter is pressed
+}
+
+// NewTextInput creates a new TextInput control with the specified screen
+// position and width.
+func NewTextInput(x, y, width int) *TextInput {
+	t := newTextInput(x, y, width)
+	addWindow(t)
+	return t
+}
+
+func newTextInput(x, y, width int) *TextInput {
+	return &TextInput{
+		screenBox: newScreenBox(x, y, width, 1),
+	}
+}
+
+// Text returns the contents of the input.
+func (t *TextInput) Text() string {
+	return t.Contents()
+}
+
+// SetText replaces the contents of the input and moves the cursor to the
+// end of the text.
+func (t *TextInput) SetText(s string) {
+	t.modifiers = 0
+	t.closeCompletion()
+	t.ClearCursors()
+	t.rows = []row{newRow(t.size.x)}
+	t.cursor = coord{0, 0}
+	t.selecting = false
+	t.InsertString(sanitizeLine(s))
+	t.updateDirtyRect(rect{0, 0, maxValue, 1})
+}
+
+// SetPlaceholder sets the text displayed when the input is empty.
+func (t *TextInput) SetPlaceholder(s string) {
+	t.placeholder = s
+	t.updateDirtyRect(t.view)
+}
+
+// SetMask causes every character of the input to be displayed as ch, which
+// is useful for password entry. Copying or cutting masked text to the
+// clipboard is disabled. Passing 0 displays the text normally.
+func (t *TextInput) SetMask(ch rune) {
+	t.mask = ch
+	t.updateDirtyRect(t.view)
+}
+
+// History returns the input history, ordered from oldest to newest.
+func (t *TextInput) History() []string {
+	return append([]string(nil), t.history...)
+}
+
+// SetHistory replaces the input history. Entries should be ordered from
+// oldest to newest.
+func (t *TextInput) SetHistory(h []string) {
+	t.history = append([]string(nil), h...)
+	t.histPos = len(t.history)
+}
+
+// AddHistory appends an entry to the input history. Empty entries and
+// entries identical to the newest entry are ignored.
+func (t *TextInput) AddHistory(s string) {
+	if s != "" && (len(t.history) == 0 || t.history[len(t.history)-1] != s) {
+		t.history = append(t.history, s)
+	}
+	t.histPos = len(t.history)
+}
+
+// SetCompleter installs the provider used for tab completion, along with
+// the style used to display multiple candidates. Passing nil disables tab
+// completion.
+func (t *TextInput) SetCompleter(c Completer, style CompletionStyle) {
+	t.closeCompletion()
+	t.completer = c
+	t.style = style
+}
+
+// OnSubmit sets the function called with the contents of the input when the
+// user presses Enter. The text is added to the input history before the
+// function is called.
+func (t *TextInput) OnSubmit(f func(text string)) {
+	t.onSubmit = f
+}
+
+// HistoryPrev replaces the input text with the previous history entry.
+func (t *TextInput) HistoryPrev() {
+	if t.histPos == 0 {
+		return
+	}
+	if t.histPos == len(t.history) {
+		t.draft = t.Text()
+	}
+	t.histPos--
+	t.SetText(t.history[t.histPos])
+}
+
+// HistoryNext replaces the input text with the next history entry, or the
+// unsubmitted text after the newest entry.
+func (t *TextInput) HistoryNext() {
+	if t.histPos >= len(t.history) {
+		return
+	}
+	t.histPos++
+	if t.histPos == len(t.history) {
+		t.SetText(t.draft)
+	} else {
+		t.SetText(t.history[t.histPos])
+	}
+}
+
+// Complete performs tab completion at the cursor. A single candidate is
+// inserted immediately. When there are several, the text they have in
+// common is inserted and the candidates are displayed; calling Complete
+// again cycles through them.
+func (t *TextInput) Complete() {
+	if t.completer == nil {
+		return
+	}
+
+	if t.completing {
+		t.candIndex = (t.candIndex + 1) % len(t.candidates)
+		t.replaceCompletion(t.candidates[t.candIndex])
+		if t.popup != nil {
+			t.popup.dirty = true
+		}
+		return
+	}
+
+	start, cands := t.completer.Complete(t.Text(), t.cursor.x)
+	if len(cands) == 0 || start < 0 || start > t.cursor.x {
+		return
+	}
+
+	t.compStart = start
+	if len(cands) == 1 {
+		t.replaceCompletion(cands[0])
+		return
+	}
+
+	t.replaceCompletion(commonPrefix(cands))
+	t.completing = true
+	t.candidates = cands
+	t.candIndex = -1
+	t.updateDirtyRect(t.view)
+	if t.style == CompletionPopup {
+		t.popup = newCompletionPopup(t)
+		addWindow(t.popup)
+	}
+}
+
+// replaceCompletion replaces the text between the start of the completion
+// and the cursor.
+func (t *TextInput) replaceCompletion(s string) {
+	t.modifiers = 0
+	t.deleteCells(0, t.compStart, t.cursor.x)
+	t.InsertString(sanitizeLine(s))
+}
+
+// closeCompletion hides the completion candidates.
+func (t *TextInput) closeCompletion() {
+	if !t.completing {
+		return
+	}
+	t.completing = false
+	t.candidates = nil
+	t.updateDirtyRect(t.view)
+	if t.popup != nil {
+		RemoveWindow(t.popup)
+		t.popup = nil
+	}
+}
+
+// selectCandidate inserts a completion candidate and hides the candidates.
+func (t *TextInput) selectCandidate(i int) {
+	t.candIndex = i
+	t.replaceCompletion(t.candidates[i])
+	t.closeCompletion()
+}
+
+// getCursor returns the absolute screen position of the cursor.
+func (t *TextInput) getCursor() (x, y int, show bool) {
+	return t.screenBox.getCursor()
+}
+
+// dirtyRect returns the screen rectangle that will be updated the next time
+// the input is drawn. The entire input line is redrawn whenever any part of
+// it changes.
+func (t *TextInput) dirtyRect() rect {
+	if intersection(t.dirty, t.view).empty() {
+		return emptyRect
+	}
+	return t.bounds()
+}
+
+// claimsKey claims the Alt+y command from menu accelerators.
+func (t *TextInput) claimsKey(ev tb.Event) bool {
+	return (ev.Mod&tb.ModAlt) != 0 && ev.Ch == 'y'
+}
+
+func (t *TextInput) onKey(ev tb.Event) error {
+	t.modifiers = ev.Mod
+
+	if ev.Key == tb.KeyTab {
+		t.Complete()
+		return nil
+	}
+	if t.completing {
+		accepted := t.candIndex >= 0
+		t.closeCompletion()
+		if ev.Key == tb.KeyEsc || (ev.Key == tb.KeyEnter && accepted) {
+			return nil
+		}
+	}
+
+	switch ev.Key {
+	case tb.KeyArrowLeft:
+		if (ev.Mod & tb.ModCtrl) != 0 {
+			t.CursorWordStart()
+		} else {
+			t.CursorLeft()
+		}
+	case tb.KeyCtrlB:
+		t.CursorLeft()
+	case tb.KeyArrowRight:
+		if (ev.Mod & tb.ModCtrl) != 0 {
+			t.CursorWordEnd()
+		} else {
+			t.CursorRight()
+		}
+	case tb.KeyCtrlF:
+		t.CursorRight()
+	case tb.KeyArrowUp, tb.KeyCtrlP:
+		t.HistoryPrev()
+	case tb.KeyArrowDown, tb.KeyCtrlN:
+		t.HistoryNext()
+	case tb.KeyHome, tb.KeyCtrlA:
+		t.CursorStartOfLine()
+	case tb.KeyEnd, tb.KeyCtrlE:
+		t.CursorEndOfLine()
+	case tb.KeyDelete, tb.KeyCtrlD:
+		t.DeleteChar()
+	case tb.KeyBackspace, tb.KeyBackspace2:
+		t.DeleteCharLeft()
+	case tb.KeyCtrlC:
+		if t.mask == 0 {
+			t.CopyToClipboard()
+		}
+	case tb.KeyCtrlV, tb.KeyCtrlY:
+		t.paste(sanitizeLine(clipboardPaste()))
+	case tb.KeyCtrlX:
+		if t.mask == 0 {
+			t.CutToClipboard()
+		}
+	case tb.KeyCtrlK:
+		if t.mask == 0 {
+			t.KillToEndOfLine()
+		}
+	case tb.KeySpace:
+		t.InsertChar(charSpace)
+	case tb.KeyEnter:
+		text := t.Text()
+		t.AddHistory(text)
+		if t.onSubmit != nil {
+			t.onSubmit(text)
+		}
+	default:
+		if ev.Ch == 'y' && (ev.Mod&tb.ModAlt) != 0 {
+			t.YankPop()
+			break
+		}
+		if ev.Ch != 0 {
+			t.InsertChar(ev.Ch)
+		}
+	}
+
+	return nil
+}
+
+// move moves the input to a new screen position, closing any displayed
+// completion candidates.
+func (t *TextInput) move(x, y int) {
+	t.closeCompletion()
+	t.screenBox.move(x, y)
+}
+
+// resize changes the width of the input. The input is always one row tall.
+func (t *TextInput) resize(width, height int) {
+	t.closeCompletion()
+	t.screenBox.resize(width, 1)
+}
+
+func (t *TextInput) onDraw(cv *Canvas) {
+	t.draw(cv)
+}
+
+// Draw updates the contents of the TextInput on the screen.
+func (t *TextInput) Draw() {
+	t.draw(newCanvas(t.bounds()))
+}
+
+// draw redraws the input on a canvas if it is dirty.
+func (t *TextInput) draw(cv *Canvas) {
+	if intersection(t.dirty, t.view).empty() {
+		return
+	}
+	t.dirty = emptyRect
+
+	cells := t.rows[0].cells
+	cv.Clear(tb.ColorDefault, tb.ColorDefault)
+
+	if len(cells) == 0 && t.placeholder != "" {
+		cv.DrawText(0, 0, t.size.x, t.placeholder, ghostFg, tb.ColorDefault)
+	}
+
+	for x := t.view.x0; x < min(t.view.x1, len(cells)); x++ {
+		c := cells[x]
+		if t.mask != 0 {
+			c.Ch = t.mask
+		}
+		fg, bg := cellColors(c)
+		cv.SetCell(x-t.view.x0, 0, c.Ch, fg, bg)
+	}
+
+	if t.completing && t.style == CompletionInline {
+		t.drawInlineCandidates(cv)
+	}
+}
+
+// drawInlineCandidates draws the completion candidates on the input line
+// following the text.
+func (t *TextInput) drawInlineCandidates(cv *Canvas) {
+	x := len(t.rows[0].cells) - t.view.x0 + 1
+	for i, s := range t.candidates {
+		if x >= t.size.x {
+			break
+		}
+		fg, bg := ghostFg, tb.ColorDefault
+		if i == t.candIndex {
+			fg, bg = tb.ColorBlack, tb.ColorWhite
+		}
+		x += cv.DrawText(x, 0, t.size.x-x, s, fg, bg) + 1
+	}
+}
+
+//
+// completionPopup
+//
+
+// A completionPopup is a window that displays a TextInput's completion
+// candidates in a list below the input line, or above it if there is no
+// room below.
+type completionPopup struct {
+	t     *TextInput // input being completed
+	r     rect       // screen rectangle of the popup
+	focus Window     // window to receive focus after a click
+	dirty bool       // popup needs to be redrawn
+}
+
+func newCompletionPopup(t *TextInput) *completionPopup {
+	n := min(len(t.candidates), maxPopupRows)
+	width := 0
+	for _, s := range t.candidates {
+		width = max(width, utf8.RuneCountInString(s))
+	}
+	width = min(width+2, t.size.x)
+
+	_, sh := tb.Size()
+	x := t.corner.x + t.compStart - t.view.x0
+	y := t.corner.y + 1
+	if y+n > sh {
+		y = t.corner.y - n
+	}
+
+	return &completionPopup{
+		t:     t,
+		r:     newRect(x, y, width, n),
+		focus: c.focus,
+		dirty: true,
+	}
+}
+
+func (p *completionPopup) bounds() rect {
+	return p.r
+}
+
+func (p *completionPopup) dirtyRect() rect {
+	if !p.dirty {
+		return emptyRect
+	}
+	return p.r
+}
+
+func (p *completionPopup) invalidate() {
+	p.dirty = true
+}
+
+func (p *completionPopup) move(x, y int) {
+	damage(p.r)
+	p.r = newRect(x, y, p.r.x1-p.r.x0, p.r.y1-p.r.y0)
+	p.dirty = true
+}
+
+func (p *completionPopup) resize(width, height int) {
+}
+
+func (p *completionPopup) getCursor() (x, y int, show bool) {
+	return 0, 0, false
+}
+
+// owner returns the window that had the focus when the popup opened, which
+// is the modal window when the input is part of one.
+func (p *completionPopup) owner() Window {
+	return p.focus
+}
+
+func (p *completionPopup) onKey(ev tb.Event) error {
+	return nil
+}
+
+func (p *completionPopup) onMouse(ev tb.Event) error {
+	if ev.Key != tb.MouseLeft || (ev.Mod&tb.ModMotion) != 0 {
+		return nil
+	}
+	if i := p.first() + ev.MouseY - p.r.y0; i < len(p.t.candidates) {
+		p.t.selectCandidate(i)
+	}
+	c.focus = p.focus
+	return nil
+}
+
+func (p *completionPopup) onDraw(cv *Canvas) {
+	if !p.dirty {
+		return
+	}
+	p.dirty = false
+
+	t := p.t
+	first := p.first()
+	width := p.r.x1 - p.r.x0
+	for i, n := 0, p.r.y1-p.r.y0; i < n && first+i < len(t.candidates); i++ {
+		fg, bg := tb.ColorWhite, tb.ColorBlue
+		if first+i == t.candIndex {
+			fg, bg = tb.ColorBlack, tb.ColorWhite
+		}
+		cv.Fill(0, i, width, 1, charSpace, fg, bg)
+		cv.DrawText(1, i, width-2, t.candidates[first+i], fg, bg)
+	}
+}
+
+// first returns the index of the first candidate displayed, scrolling the
+// list so the current candidate is visible.
+func (p *completionPopup) first() int {
+	n := p.r.y1 - p.r.y0
+	if p.t.candIndex >= n {
+		return p.t.candIndex - n + 1
+	}
+	return 0
+}
+
+// sanitizeLine replaces the newlines in a string with spaces and removes
+// other control characters.
+func sanitizeLine(s string) string {
+	return strings.Map(func(ch rune) rune {
+		switch {
+		case ch == charNewline:
+			return charSpace
+		case ch < 32:
+			return -1
+		}
+		return ch
+	}, s)
+}
+
+// expandTabs replaces the tabs in a string with spaces up to the next tab
+// stop. Columns restart after each newline, and other control characters,
+// which callers drop, do not occupy a column.
+func expandTabs(s string) string {
+	if !strings.ContainsRune(s, '\t') {
+		return s
+	}
+	var b strings.Builder
+	col := 0
+	for _, ch := range s {
+		switch {
+		case ch == '\t':
+			n := tabWidth - col%tabWidth
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		case ch == charNewline:
+			col = 0
+		case ch >= charSpace:
+			col++
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
+
+// commonPrefix returns the longest prefix shared by all strings.
+func commonPrefix(s []string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	p := []rune(s[0])
+	for _, str := range s[1:] {
+		i := 0
+		for _, ch := range str {
+			if i >= len(p) || p[i] != ch {
+				break
+			}
+			i++
+		}
+		p = p[:i]
+	}
+	return string(p)
+}
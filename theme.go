@@ -0,0 +1,55 @@
+package termwin
+
+import tb "github.com/nsf/termbox-go"
+
+// A Theme defines the colors used to draw dialogs, menus, tabs and form
+// controls.
+type Theme struct {
+	Fg, Bg                   tb.Attribute // normal text
+	FocusFg, FocusBg         tb.Attribute // control with the keyboard focus
+	DisabledFg               tb.Attribute // text of disabled controls
+	ErrorFg                  tb.Attribute // validation error messages
+	DialogFg, DialogBg       tb.Attribute // dialog frame and body
+	MenuFg, MenuBg           tb.Attribute // menu bar and menu items
+	MenuSelFg, MenuSelBg     tb.Attribute // selected menu item
+	MenuDisabledFg           tb.Attribute // disabled menu item
+	TabFg, TabBg             tb.Attribute // tab strip and inactive tabs
+	TabActiveFg, TabActiveBg tb.Attribute // active tab
+}
+
+// DefaultTheme is the theme used until SetTheme is called.
+var DefaultTheme = Theme{
+	Fg:             tb.ColorDefault,
+	Bg:             tb.ColorDefault,
+	FocusFg:        tb.ColorBlack,
+	FocusBg:        tb.ColorCyan,
+	DisabledFg:     tb.ColorBlue,
+	ErrorFg:        tb.ColorRed,
+	DialogFg:       tb.ColorBlack,
+	DialogBg:       tb.ColorWhite,
+	MenuFg:         tb.ColorBlack,
+	MenuBg:         tb.ColorWhite,
+	MenuSelFg:      tb.ColorWhite,
+	MenuSelBg:      tb.ColorBlue,
+	MenuDisabledFg: tb.ColorCyan,
+	TabFg:          tb.ColorBlack,
+	TabBg:          tb.ColorWhite,
+	TabActiveFg:    tb.ColorWhite,
+	TabActiveBg:    tb.ColorBlue,
+}
+
+var theme = DefaultTheme
+
+// CurrentTheme returns the theme currently used to draw controls.
+func CurrentTheme() Theme {
+	return theme
+}
+
+// SetTheme changes the colors used to draw controls and redraws all
+// windows.
+func SetTheme(t Theme) {
+	theme = t
+	for _, w := range c.windows {
+		w.invalidate()
+	}
+}
@@ -0,0 +1,560 @@
+package termwin
+
+import (
+	"reflect"
+	"time"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+const (
+	treeCollapsed = '▸'
+	treeExpanded  = '▾'
+	treeBranch    = '├'
+	treeLastChild = '└'
+	treeGuide     = '│'
+	treeLine      = '─'
+)
+
+// A TreeProvider supplies the nodes displayed by a TreeView. Nodes are
+// opaque values chosen by the provider. Children are requested only when a
+// node is first expanded.
+type TreeProvider interface {
+	// Children returns the child nodes of node, or the root nodes when node
+	// is nil.
+	Children(node interface{}) []interface{}
+
+	// HasChildren returns true if node can be expanded. It is called before
+	// the children are loaded, so it should be inexpensive.
+	HasChildren(node interface{}) bool
+
+	// Label returns the text displayed for node.
+	Label(node interface{}) string
+}
+
+// A TreeKeyer is a TreeProvider that identifies its nodes by key. Refresh
+// uses node identity to keep nodes expanded and selected; a provider whose
+// nodes are not comparable, such as maps or slices, implements TreeKeyer so
+// that this state survives a refresh.
+type TreeKeyer interface {
+	TreeProvider
+
+	// Key returns a comparable value identifying node.
+	Key(node interface{}) interface{}
+}
+
+// A treeItem is a node that has been loaded into a TreeView.
+type treeItem struct {
+	node     interface{} // provider's node value
+	parent   *treeItem   // parent item, or nil for roots
+	depth    int         // nesting level, 0 for roots
+	last     bool        // item is the last of its siblings
+	expanded bool        // children are displayed
+	loaded   bool        // children have been requested from the provider
+	children []*treeItem // loaded children
+}
+
+// A TreeView displays hierarchical data as an indented tree of nodes that
+// can be expanded and collapsed. Children are loaded lazily from a
+// TreeProvider as nodes are expanded.
+type TreeView struct {
+	corner     coord                                 // screen coordinate of top-left corner
+	size       coord                                 // screen dimensions of the tree
+	provider   TreeProvider                          // source of tree nodes
+	roots      []*treeItem                           // top-level items
+	visible    []*treeItem                           // items currently displayed, in order
+	top        int                                   // index of the first visible item
+	current    int                                   // index of the selected item
+	clickTime  time.Time                             // time of the last mouse click
+	fg, bg     tb.Attribute                          // colors of unselected items
+	selFg      tb.Attribute                          // foreground color of the selected item
+	selBg      tb.Attribute                          // background color of the selected item
+	guideFg    tb.Attribute                          // color of indentation guides
+	dirty      bool                                  // tree needs to be redrawn
+	onSelect   func(node interface{})                // called when the selection changes
+	onActivate func(node interface{})                // called when a node is activated
+	onExpand   func(node interface{}, expanded bool) // called on expand/collapse
+}
+
+// NewTreeView creates a new TreeView control with the specified screen
+// position and size, displaying nodes from provider.
+func NewTreeView(x, y, width, height int, provider TreeProvider) *TreeView {
+	t := newTreeView(x, y, width, height, provider)
+	addWindow(t)
+	return t
+}
+
+func newTreeView(x, y, width, height int, provider TreeProvider) *TreeView {
+	t := &TreeView{
+		corner:  coord{x, y},
+		size:    coord{width, height},
+		fg:      tb.ColorDefault,
+		bg:      tb.ColorDefault,
+		selFg:   tb.ColorBlack,
+		selBg:   tb.ColorWhite,
+		guideFg: tb.ColorBlue,
+	}
+	t.SetProvider(provider)
+	return t
+}
+
+// SetProvider replaces the source of the tree's nodes and reloads the root
+// nodes.
+func (t *TreeView) SetProvider(provider TreeProvider) {
+	t.provider = provider
+	t.Refresh()
+}
+
+// Refresh discards all loaded nodes and reloads the root nodes from the
+// provider. Nodes that were expanded before the refresh are expanded
+// again, and the selected node remains selected if it still exists. Nodes
+// are matched by identity, or by key if the provider is a TreeKeyer.
+func (t *TreeView) Refresh() {
+	sel := t.key(t.Selected())
+
+	// Record which loaded nodes were expanded, including those hidden under
+	// a collapsed ancestor.
+	expanded := make(map[interface{}]bool)
+	var collect func(items []*treeItem)
+	collect = func(items []*treeItem) {
+		for _, it := range items {
+			if !it.loaded {
+				continue
+			}
+			if k := t.key(it.node); k != nil {
+				expanded[k] = it.expanded
+			}
+			collect(it.children)
+		}
+	}
+	collect(t.roots)
+
+	t.roots = nil
+	if t.provider != nil {
+		t.roots = t.loadChildren(nil, t.provider.Children(nil))
+	}
+
+	var reexpand func(items []*treeItem)
+	reexpand = func(items []*treeItem) {
+		for _, it := range items {
+			k := t.key(it.node)
+			if k == nil {
+				continue
+			}
+			if exp, ok := expanded[k]; ok && t.provider.HasChildren(it.node) {
+				t.load(it)
+				it.expanded = exp
+				reexpand(it.children)
+			}
+		}
+	}
+	reexpand(t.roots)
+
+	t.rebuild()
+	t.current = 0
+	if sel != nil {
+		t.selectKey(sel)
+	}
+	t.updateView()
+	t.dirty = true
+}
+
+// SetColors sets the foreground and background colors of unselected nodes
+// and the color of the indentation guides.
+func (t *TreeView) SetColors(fg, bg, guideFg tb.Attribute) {
+	t.fg, t.bg, t.guideFg = fg, bg, guideFg
+	t.dirty = true
+}
+
+// SetSelectedColors sets the foreground and background colors of the
+// selected node.
+func (t *TreeView) SetSelectedColors(fg, bg tb.Attribute) {
+	t.selFg, t.selBg = fg, bg
+	t.dirty = true
+}
+
+// OnSelect sets the function called whenever the selected node changes.
+func (t *TreeView) OnSelect(f func(node interface{})) {
+	t.onSelect = f
+}
+
+// OnActivate sets the function called when the user activates a node by
+// pressing Enter or double-clicking it.
+func (t *TreeView) OnActivate(f func(node interface{})) {
+	t.onActivate = f
+}
+
+// OnExpand sets the function called whenever a node is expanded or
+// collapsed.
+func (t *TreeView) OnExpand(f func(node interface{}, expanded bool)) {
+	t.onExpand = f
+}
+
+// Selected returns the selected node, or nil if the tree is empty.
+func (t *TreeView) Selected() interface{} {
+	if t.current >= len(t.visible) {
+		return nil
+	}
+	return t.visible[t.current].node
+}
+
+// Expand expands the selected node, loading its children if necessary.
+func (t *TreeView) Expand() {
+	if it := t.item(); it != nil {
+		t.setExpanded(it, true)
+	}
+}
+
+// Collapse collapses the selected node.
+func (t *TreeView) Collapse() {
+	if it := t.item(); it != nil {
+		t.setExpanded(it, false)
+	}
+}
+
+func (t *TreeView) bounds() rect {
+	return newRect(t.corner.x, t.corner.y, t.size.x, t.size.y)
+}
+
+func (t *TreeView) dirtyRect() rect {
+	if !t.dirty {
+		return emptyRect
+	}
+	return t.bounds()
+}
+
+func (t *TreeView) invalidate() {
+	t.dirty = true
+}
+
+func (t *TreeView) move(x, y int) {
+	damage(t.bounds())
+	t.corner = coord{x, y}
+	t.dirty = true
+}
+
+func (t *TreeView) resize(width, height int) {
+	damage(t.bounds())
+	t.size = coord{width, height}
+	t.updateView()
+	t.dirty = true
+}
+
+func (t *TreeView) getCursor() (x, y int, show bool) {
+	return 0, 0, false
+}
+
+func (t *TreeView) onKey(ev tb.Event) error {
+	page := max(t.size.y-1, 1)
+	it := t.item()
+
+	switch ev.Key {
+	case tb.KeyArrowUp, tb.KeyCtrlP:
+		t.moveTo(t.current - 1)
+	case tb.KeyArrowDown, tb.KeyCtrlN:
+		t.moveTo(t.current + 1)
+	case tb.KeyPgup:
+		t.moveTo(t.current - page)
+	case tb.KeyPgdn:
+		t.moveTo(t.current + page)
+	case tb.KeyHome:
+		t.moveTo(0)
+	case tb.KeyEnd:
+		t.moveTo(len(t.visible) - 1)
+	case tb.KeyArrowLeft:
+		switch {
+		case it == nil:
+		case it.expanded:
+			t.setExpanded(it, false)
+		case it.parent != nil:
+			t.moveTo(t.indexOf(it.parent))
+		}
+	case tb.KeyArrowRight:
+		switch {
+		case it == nil:
+		case !it.expanded:
+			t.setExpanded(it, true)
+		case len(it.children) > 0:
+			t.moveTo(t.current + 1)
+		}
+	case tb.KeySpace:
+		if it != nil {
+			t.setExpanded(it, !it.expanded)
+		}
+	case tb.KeyEnter:
+		t.activate()
+	}
+	return nil
+}
+
+func (t *TreeView) onMouse(ev tb.Event) error {
+	switch ev.Key {
+	case tb.MouseLeft:
+		if (ev.Mod & tb.ModMotion) != 0 {
+			return nil
+		}
+		i := t.top + ev.MouseY - t.corner.y
+		if i >= len(t.visible) {
+			return nil
+		}
+
+		now := time.Now()
+		double := i == t.current && now.Sub(t.clickTime) < doubleClickTime
+		t.clickTime = now
+		t.moveTo(i)
+
+		it := t.visible[i]
+		switch {
+		case ev.MouseX-t.corner.x == indicatorColumn(it):
+			t.setExpanded(it, !it.expanded)
+		case double:
+			t.activate()
+		}
+	case tb.MouseWheelUp:
+		t.scroll(-mouseWheelRows)
+	case tb.MouseWheelDown:
+		t.scroll(+mouseWheelRows)
+	}
+	return nil
+}
+
+func (t *TreeView) onDraw(cv *Canvas) {
+	t.draw(cv)
+}
+
+// Draw updates the contents of the TreeView on the screen.
+func (t *TreeView) Draw() {
+	t.draw(newCanvas(t.bounds()))
+}
+
+// draw redraws the tree on a canvas if it is dirty.
+func (t *TreeView) draw(cv *Canvas) {
+	if !t.dirty {
+		return
+	}
+	t.dirty = false
+
+	guides := make([]rune, 0, 32)
+	for y := 0; y < t.size.y; y++ {
+		i := t.top + y
+		if i >= len(t.visible) {
+			cv.Fill(0, y, t.size.x, 1, charSpace, t.fg, t.bg)
+			continue
+		}
+
+		it := t.visible[i]
+		guides = treeGuides(guides[:0], it)
+		x := cv.DrawText(0, y, t.size.x, string(guides), t.guideFg, t.bg)
+
+		fg, bg := t.fg, t.bg
+		if i == t.current {
+			fg, bg = t.selFg, t.selBg
+		}
+
+		ind := charSpace
+		if t.provider.HasChildren(it.node) {
+			ind = treeCollapsed
+			if it.expanded {
+				ind = treeExpanded
+			}
+		}
+		x += cv.DrawText(x, y, t.size.x-x, string(ind)+" ", t.fg, t.bg)
+		x += cv.DrawText(x, y, t.size.x-x, t.provider.Label(it.node), fg, bg)
+		cv.Fill(x, y, t.size.x-x, 1, charSpace, t.fg, t.bg)
+	}
+}
+
+// treeGuides appends the indentation guides and connector drawn to the left
+// of an item.
+func treeGuides(g []rune, it *treeItem) []rune {
+	if it.depth == 0 {
+		return g
+	}
+
+	n := len(g)
+	for p := it.parent; p != nil && p.depth > 0; p = p.parent {
+		if p.last {
+			g = append(g, charSpace, charSpace)
+		} else {
+			g = append(g, charSpace, treeGuide)
+		}
+	}
+	for i, j := n, len(g)-1; i < j; i, j = i+1, j-1 {
+		g[i], g[j] = g[j], g[i]
+	}
+
+	if it.last {
+		return append(g, treeLastChild, treeLine)
+	}
+	return append(g, treeBranch, treeLine)
+}
+
+// indicatorColumn returns the column of an item's expand/collapse
+// indicator.
+func indicatorColumn(it *treeItem) int {
+	return it.depth * 2
+}
+
+// item returns the selected item, or nil if the tree is empty.
+func (t *TreeView) item() *treeItem {
+	if t.current >= len(t.visible) {
+		return nil
+	}
+	return t.visible[t.current]
+}
+
+// indexOf returns the index of an item in the visible list, or -1.
+func (t *TreeView) indexOf(it *treeItem) int {
+	for i, v := range t.visible {
+		if v == it {
+			return i
+		}
+	}
+	return -1
+}
+
+// key returns the value identifying a node, or nil if the node cannot be
+// identified.
+func (t *TreeView) key(node interface{}) interface{} {
+	if node == nil {
+		return nil
+	}
+	if k, ok := t.provider.(TreeKeyer); ok {
+		return k.Key(node)
+	}
+	if !reflect.TypeOf(node).Comparable() {
+		return nil
+	}
+	return node
+}
+
+// selectKey selects the visible item holding the node identified by key.
+func (t *TreeView) selectKey(key interface{}) {
+	for i, it := range t.visible {
+		if t.key(it.node) == key {
+			t.current = i
+			return
+		}
+	}
+}
+
+// load requests an item's children from the provider if they have not been
+// loaded yet.
+func (t *TreeView) load(it *treeItem) {
+	if !it.loaded {
+		it.children = t.loadChildren(it, t.provider.Children(it.node))
+		it.loaded = true
+	}
+}
+
+// loadChildren wraps provider nodes in tree items.
+func (t *TreeView) loadChildren(parent *treeItem, nodes []interface{}) []*treeItem {
+	depth := 0
+	if parent != nil {
+		depth = parent.depth + 1
+	}
+	items := make([]*treeItem, len(nodes))
+	for i, n := range nodes {
+		items[i] = &treeItem{
+			node:   n,
+			parent: parent,
+			depth:  depth,
+			last:   i == len(nodes)-1,
+		}
+	}
+	return items
+}
+
+// setExpanded expands or collapses an item and rebuilds the visible list.
+func (t *TreeView) setExpanded(it *treeItem, expanded bool) {
+	if expanded && !t.provider.HasChildren(it.node) {
+		return
+	}
+	if it.expanded == expanded {
+		return
+	}
+
+	sel := t.item()
+	if expanded {
+		t.load(it)
+	}
+	it.expanded = expanded
+	t.rebuild()
+
+	// Collapsing an ancestor of the selected item selects the ancestor.
+	if i := t.indexOf(sel); i >= 0 {
+		t.current = i
+	} else {
+		t.current = t.indexOf(it)
+		t.selectionChanged()
+	}
+	t.updateView()
+	t.dirty = true
+
+	if t.onExpand != nil {
+		t.onExpand(it.node, expanded)
+	}
+}
+
+// rebuild recomputes the list of visible items.
+func (t *TreeView) rebuild() {
+	t.visible = t.visible[:0]
+	var walk func(items []*treeItem)
+	walk = func(items []*treeItem) {
+		for _, it := range items {
+			t.visible = append(t.visible, it)
+			if it.expanded {
+				walk(it.children)
+			}
+		}
+	}
+	walk(t.roots)
+}
+
+// moveTo selects the visible item at index i, clamped to the list bounds.
+func (t *TreeView) moveTo(i int) {
+	if len(t.visible) == 0 {
+		return
+	}
+	i = min(max(i, 0), len(t.visible)-1)
+	if i == t.current {
+		return
+	}
+	t.current = i
+	t.updateView()
+	t.dirty = true
+	t.selectionChanged()
+}
+
+// scroll scrolls the tree by dy rows without changing the selection.
+func (t *TreeView) scroll(dy int) {
+	top := min(max(t.top+dy, 0), max(len(t.visible)-t.size.y, 0))
+	if top != t.top {
+		t.top = top
+		t.dirty = true
+	}
+}
+
+// updateView scrolls the tree so the selected item is visible.
+func (t *TreeView) updateView() {
+	switch {
+	case t.current < t.top:
+		t.top = t.current
+	case t.current >= t.top+t.size.y:
+		t.top = t.current - t.size.y + 1
+	}
+	t.top = max(t.top, 0)
+}
+
+// activate calls the activation function with the selected node.
+func (t *TreeView) activate() {
+	if t.onActivate != nil && t.item() != nil {
+		t.onActivate(t.item().node)
+	}
+}
+
+func (t *TreeView) selectionChanged() {
+	if t.onSelect != nil && t.item() != nil {
+		t.onSelect(t.item().node)
+	}
+}
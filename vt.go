@@ -0,0 +1,665 @@
+package termwin
+
+import (
+	"strconv"
+	"unicode/utf8"
+
+	runewidth "github.com/mattn/go-runewidth"
+	tb "github.com/nsf/termbox-go"
+)
+
+// States of the escape sequence parser.
+const (
+	vtGround  = iota // printing characters
+	vtEscape         // after ESC
+	vtCharset        // after ESC ( or ESC ), skipping the charset designator
+	vtCSI            // within a control sequence, after ESC [
+	vtOSC            // within an operating system command, after ESC ]
+	vtOSCEsc         // after ESC within an operating system command
+)
+
+// vtTabWidth is the distance between tab stops.
+const vtTabWidth = 8
+
+// vtMaxParam is the largest value of a control sequence parameter. Larger
+// values are clamped to it, as xterm does, so that cursor arithmetic
+// cannot overflow.
+const vtMaxParam = 65535
+
+// A vtScreen is a VT100/xterm terminal emulator's screen: a grid of cells
+// updated by writing a stream of text and escape sequences to it. It
+// supports cursor movement, erasing, insertion and deletion, SGR colors
+// and attributes, scroll regions and the alternate screen.
+type vtScreen struct {
+	size       coord       // dimensions of the screen
+	rows       [][]tb.Cell // cells of the active screen
+	primary    [][]tb.Cell // cells of the primary screen while the alternate is active
+	alt        bool        // the alternate screen is active
+	cursor     coord       // cursor position
+	saved      coord       // cursor position saved by DECSC
+	savedFg    tb.Attribute
+	savedBg    tb.Attribute
+	fg, bg     tb.Attribute // attributes of newly written cells
+	top        int          // first row of the scroll region
+	bottom     int          // last row of the scroll region
+	wrapNext   bool         // the next character wraps to the next line
+	autowrap   bool         // characters written past the last column wrap
+	showCursor bool         // the cursor is visible
+	appCursor  bool         // cursor keys send application sequences
+	state      int          // escape sequence parser state
+	seq        []byte       // bytes of the current control sequence
+	partial    []byte       // incomplete UTF-8 sequence from the last write
+	reply      []byte       // responses to be sent back to the program
+}
+
+func newVTScreen(width, height int) *vtScreen {
+	s := &vtScreen{}
+	s.reset(width, height)
+	return s
+}
+
+// reset restores the screen to its initial state.
+func (s *vtScreen) reset(width, height int) {
+	*s = vtScreen{
+		size:       coord{width, height},
+		autowrap:   true,
+		showCursor: true,
+		bottom:     height - 1,
+		reply:      s.reply,
+	}
+	s.rows = s.newRows(height)
+}
+
+// newRows returns n blank rows.
+func (s *vtScreen) newRows(n int) [][]tb.Cell {
+	rows := make([][]tb.Cell, n)
+	for i := range rows {
+		rows[i] = s.blankRow()
+	}
+	return rows
+}
+
+// blankRow returns a row of blank cells.
+func (s *vtScreen) blankRow() []tb.Cell {
+	row := make([]tb.Cell, s.size.x)
+	s.erase(row)
+	return row
+}
+
+// erase blanks cells using the current background color.
+func (s *vtScreen) erase(cells []tb.Cell) {
+	for i := range cells {
+		cells[i] = tb.Cell{Ch: charSpace, Bg: s.bg}
+	}
+}
+
+// resize changes the dimensions of the screen. Content is kept anchored to
+// the top-left corner, except that rows are scrolled off the top if needed
+// to keep the cursor on the screen.
+func (s *vtScreen) resize(width, height int) {
+	if width == s.size.x && height == s.size.y {
+		return
+	}
+	shift := max(s.cursor.y-height+1, 0)
+	s.rows = resizeRows(s.rows, shift, width, height)
+	if s.primary != nil {
+		s.primary = resizeRows(s.primary, 0, width, height)
+	}
+	s.size = coord{width, height}
+	s.cursor = coord{min(s.cursor.x, max(width-1, 0)), max(s.cursor.y-shift, 0)}
+	s.saved = coord{min(s.saved.x, max(width-1, 0)), min(s.saved.y, max(height-1, 0))}
+	s.top, s.bottom = 0, height-1
+	s.wrapNext = false
+}
+
+// resizeRows copies rows into a grid of new dimensions, skipping the
+// first shift rows.
+func resizeRows(rows [][]tb.Cell, shift, width, height int) [][]tb.Cell {
+	out := make([][]tb.Cell, height)
+	for y := range out {
+		row := make([]tb.Cell, width)
+		for x := range row {
+			row[x] = tb.Cell{Ch: charSpace}
+		}
+		if y+shift < len(rows) {
+			copy(row, rows[y+shift])
+		}
+		out[y] = row
+	}
+	return out
+}
+
+// Write processes a stream of text and escape sequences written by the
+// program running in the terminal. UTF-8 sequences split across writes are
+// reassembled.
+func (s *vtScreen) Write(p []byte) (int, error) {
+	buf := p
+	if len(s.partial) > 0 {
+		buf = append(s.partial, p...)
+		s.partial = nil
+	}
+	for len(buf) > 0 {
+		if buf[0] < utf8.RuneSelf {
+			s.put(rune(buf[0]))
+			buf = buf[1:]
+			continue
+		}
+		if !utf8.FullRune(buf) {
+			s.partial = append([]byte(nil), buf...)
+			break
+		}
+		r, n := utf8.DecodeRune(buf)
+		s.put(r)
+		buf = buf[n:]
+	}
+	return len(p), nil
+}
+
+// put processes one rune of the output stream.
+func (s *vtScreen) put(r rune) {
+	switch s.state {
+	case vtEscape:
+		s.escape(r)
+		return
+	case vtCharset:
+		s.state = vtGround
+		return
+	case vtCSI:
+		switch {
+		case r >= 0x40 && r <= 0x7e:
+			s.state = vtGround
+			s.csi(r)
+		case r == 0x1b:
+			s.state = vtEscape
+		case r < 0x20:
+			s.control(r)
+		default:
+			s.seq = append(s.seq, byte(r))
+		}
+		return
+	case vtOSC:
+		switch r {
+		case 0x07:
+			s.state = vtGround
+		case 0x1b:
+			s.state = vtOSCEsc
+		}
+		return
+	case vtOSCEsc:
+		// ST ends the command; any other sequence starts a new one.
+		if r == '\\' {
+			s.state = vtGround
+		} else {
+			s.state = vtEscape
+			s.put(r)
+		}
+		return
+	}
+
+	if r < 0x20 || r == 0x7f {
+		s.control(r)
+		return
+	}
+	s.print(r)
+}
+
+// control performs a C0 control function.
+func (s *vtScreen) control(r rune) {
+	switch r {
+	case 0x1b:
+		s.state = vtEscape
+	case '\b':
+		s.cursor.x = max(s.cursor.x-1, 0)
+		s.wrapNext = false
+	case '\t':
+		s.cursor.x = min((s.cursor.x/vtTabWidth+1)*vtTabWidth, s.size.x-1)
+		s.wrapNext = false
+	case '\n', '\v', '\f':
+		s.lineFeed()
+	case '\r':
+		s.cursor.x = 0
+		s.wrapNext = false
+	}
+}
+
+// escape performs the function of an escape sequence.
+func (s *vtScreen) escape(r rune) {
+	s.state = vtGround
+	switch r {
+	case '[':
+		s.state, s.seq = vtCSI, s.seq[:0]
+	case ']':
+		s.state = vtOSC
+	case '(', ')', '*', '+':
+		s.state = vtCharset
+	case '7':
+		s.saveCursor()
+	case '8':
+		s.restoreCursor()
+	case 'D':
+		s.lineFeed()
+	case 'E':
+		s.lineFeed()
+		s.cursor.x = 0
+	case 'M':
+		s.reverseIndex()
+	case 'c':
+		s.reset(s.size.x, s.size.y)
+	}
+}
+
+// csi performs the function of a control sequence with the given final
+// byte.
+func (s *vtScreen) csi(final rune) {
+	private := len(s.seq) > 0 && (s.seq[0] == '?' || s.seq[0] == '>' || s.seq[0] == '=')
+	params := s.seq
+	if private {
+		params = params[1:]
+	}
+	p := parseParams(params)
+	arg := func(i, def int) int {
+		if i < len(p) && p[i] > 0 {
+			return p[i]
+		}
+		return def
+	}
+
+	if private {
+		switch final {
+		case 'h', 'l':
+			for _, m := range p {
+				s.setPrivateMode(m, final == 'h')
+			}
+		}
+		return
+	}
+
+	switch final {
+	case 'A':
+		s.moveTo(s.cursor.x, max(s.cursor.y-arg(0, 1), s.scrollTop()))
+	case 'B':
+		s.moveTo(s.cursor.x, min(s.cursor.y+arg(0, 1), s.scrollBottom()))
+	case 'C':
+		s.moveTo(s.cursor.x+arg(0, 1), s.cursor.y)
+	case 'D':
+		s.moveTo(s.cursor.x-arg(0, 1), s.cursor.y)
+	case 'E':
+		s.moveTo(0, s.cursor.y+arg(0, 1))
+	case 'F':
+		s.moveTo(0, s.cursor.y-arg(0, 1))
+	case 'G', '`':
+		s.moveTo(arg(0, 1)-1, s.cursor.y)
+	case 'H', 'f':
+		s.moveTo(arg(1, 1)-1, arg(0, 1)-1)
+	case 'd':
+		s.moveTo(s.cursor.x, arg(0, 1)-1)
+	case 'J':
+		s.eraseDisplay(arg(0, 0))
+	case 'K':
+		s.eraseLine(arg(0, 0))
+	case 'L':
+		s.insertLines(arg(0, 1))
+	case 'M':
+		s.deleteLines(arg(0, 1))
+	case '@':
+		s.insertChars(arg(0, 1))
+	case 'P':
+		s.deleteChars(arg(0, 1))
+	case 'X':
+		s.eraseChars(arg(0, 1))
+	case 'S':
+		s.scrollUp(arg(0, 1))
+	case 'T':
+		s.scrollDown(arg(0, 1))
+	case 'm':
+		s.fg, s.bg = applySGR(p, s.fg, s.bg)
+	case 'r':
+		top, bottom := arg(0, 1)-1, arg(1, s.size.y)-1
+		if top < bottom && bottom < s.size.y {
+			s.top, s.bottom = top, bottom
+			s.moveTo(0, 0)
+		}
+	case 's':
+		s.saveCursor()
+	case 'u':
+		s.restoreCursor()
+	case 'n':
+		if arg(0, 0) == 6 {
+			s.reply = append(s.reply, "\x1b["+strconv.Itoa(s.cursor.y+1)+";"+strconv.Itoa(s.cursor.x+1)+"R"...)
+		}
+	case 'c':
+		s.reply = append(s.reply, "\x1b[?1;2c"...)
+	}
+}
+
+// setPrivateMode sets or resets a DEC private mode.
+func (s *vtScreen) setPrivateMode(mode int, on bool) {
+	switch mode {
+	case 1:
+		s.appCursor = on
+	case 7:
+		s.autowrap = on
+	case 25:
+		s.showCursor = on
+	case 47, 1047:
+		s.setAltScreen(on)
+	case 1049:
+		if on {
+			s.saveCursor()
+			s.setAltScreen(true)
+		} else {
+			s.setAltScreen(false)
+			s.restoreCursor()
+		}
+	}
+}
+
+// setAltScreen switches between the primary and the alternate screen. The
+// alternate screen is cleared when it is activated.
+func (s *vtScreen) setAltScreen(on bool) {
+	if on == s.alt {
+		return
+	}
+	s.alt = on
+	if on {
+		s.primary = s.rows
+		s.rows = s.newRows(s.size.y)
+	} else {
+		s.rows = s.primary
+		s.primary = nil
+	}
+}
+
+// parseParams parses the semicolon-separated numeric parameters of a
+// control sequence. Missing parameters are zero, and values are clamped
+// to vtMaxParam.
+func parseParams(b []byte) []int {
+	var p []int
+	n := 0
+	for _, c := range b {
+		switch {
+		case c >= '0' && c <= '9':
+			n = min(n*10+int(c-'0'), vtMaxParam)
+		case c == ';' || c == ':':
+			p = append(p, n)
+			n = 0
+		}
+	}
+	return append(p, n)
+}
+
+// applySGR applies the parameters of an SGR (select graphic rendition)
+// control sequence to a pair of termbox foreground and background
+// attributes. Indexed colors beyond the first 16 and direct RGB colors are
+// not supported by termbox's normal output mode and are ignored.
+func applySGR(p []int, fg, bg tb.Attribute) (tb.Attribute, tb.Attribute) {
+	for i := 0; i < len(p); i++ {
+		switch n := p[i]; {
+		case n == 0:
+			fg, bg = tb.ColorDefault, tb.ColorDefault
+		case n == 1:
+			fg |= tb.AttrBold
+		case n == 4:
+			fg |= tb.AttrUnderline
+		case n == 7:
+			fg |= tb.AttrReverse
+		case n == 22:
+			fg &^= tb.AttrBold
+		case n == 24:
+			fg &^= tb.AttrUnderline
+		case n == 27:
+			fg &^= tb.AttrReverse
+		case n >= 30 && n <= 37:
+			fg = fg&^0xff | tb.Attribute(n-30+1)
+		case n == 39:
+			fg &^= 0xff
+		case n >= 40 && n <= 47:
+			bg = bg&^0xff | tb.Attribute(n-40+1)
+		case n == 49:
+			bg &^= 0xff
+		case n >= 90 && n <= 97:
+			fg = fg&^0xff | tb.Attribute(n-90+1) | tb.AttrBold
+		case n >= 100 && n <= 107:
+			bg = bg&^0xff | tb.Attribute(n-100+1)
+		case (n == 38 || n == 48) && i+1 < len(p):
+			var color tb.Attribute
+			switch p[i+1] {
+			case 5:
+				if i+2 < len(p) && p[i+2] < 16 {
+					color = tb.Attribute(p[i+2]%8 + 1)
+				}
+				i += 2
+			case 2:
+				i += 4
+			}
+			if color == 0 {
+				break
+			}
+			if n == 38 {
+				fg = fg&^0xff | color
+			} else {
+				bg = bg&^0xff | color
+			}
+		}
+	}
+	return fg, bg
+}
+
+// print writes a printable character at the cursor and advances it.
+func (s *vtScreen) print(r rune) {
+	w := runewidth.RuneWidth(r)
+	if w == 0 || s.empty() {
+		return
+	}
+	if s.wrapNext || (w == 2 && s.cursor.x == s.size.x-1) {
+		if s.autowrap {
+			s.lineFeed()
+			s.cursor.x = 0
+		}
+		s.wrapNext = false
+	}
+
+	row := s.rows[s.cursor.y]
+	row[s.cursor.x] = tb.Cell{Ch: r, Fg: s.fg, Bg: s.bg}
+	if w == 2 && s.cursor.x+1 < s.size.x {
+		row[s.cursor.x+1] = tb.Cell{Ch: charSpace, Fg: s.fg, Bg: s.bg}
+	}
+	if s.cursor.x+w >= s.size.x {
+		s.wrapNext = true
+	} else {
+		s.cursor.x += w
+	}
+}
+
+// empty returns true if the screen has no cells, in which case there is
+// no cursor row to write to or erase.
+func (s *vtScreen) empty() bool {
+	return s.size.x == 0 || s.size.y == 0
+}
+
+// scrollTop returns the highest row the cursor can reach by moving up.
+func (s *vtScreen) scrollTop() int {
+	if s.cursor.y >= s.top {
+		return s.top
+	}
+	return 0
+}
+
+// scrollBottom returns the lowest row the cursor can reach by moving down.
+func (s *vtScreen) scrollBottom() int {
+	if s.cursor.y <= s.bottom {
+		return s.bottom
+	}
+	return s.size.y - 1
+}
+
+// moveTo moves the cursor, keeping it on the screen.
+func (s *vtScreen) moveTo(x, y int) {
+	s.cursor = coord{min(max(x, 0), max(s.size.x-1, 0)), min(max(y, 0), max(s.size.y-1, 0))}
+	s.wrapNext = false
+}
+
+func (s *vtScreen) saveCursor() {
+	s.saved, s.savedFg, s.savedBg = s.cursor, s.fg, s.bg
+}
+
+func (s *vtScreen) restoreCursor() {
+	s.fg, s.bg = s.savedFg, s.savedBg
+	s.moveTo(s.saved.x, s.saved.y)
+}
+
+// lineFeed moves the cursor down one row, scrolling the scroll region if
+// the cursor is on its last row.
+func (s *vtScreen) lineFeed() {
+	switch {
+	case s.cursor.y == s.bottom:
+		s.scrollUp(1)
+	case s.cursor.y < s.size.y-1:
+		s.cursor.y++
+	}
+	s.wrapNext = false
+}
+
+// reverseIndex moves the cursor up one row, scrolling the scroll region if
+// the cursor is on its first row.
+func (s *vtScreen) reverseIndex() {
+	switch {
+	case s.cursor.y == s.top:
+		s.scrollDown(1)
+	case s.cursor.y > 0:
+		s.cursor.y--
+	}
+	s.wrapNext = false
+}
+
+// scrollUp scrolls the rows of the scroll region up by n rows.
+func (s *vtScreen) scrollUp(n int) {
+	s.deleteRows(s.top, n)
+}
+
+// scrollDown scrolls the rows of the scroll region down by n rows.
+func (s *vtScreen) scrollDown(n int) {
+	s.insertRows(s.top, n)
+}
+
+// insertRows inserts n blank rows at row y, pushing the rows below it
+// toward the bottom of the scroll region.
+func (s *vtScreen) insertRows(y, n int) {
+	if y < s.top || y > s.bottom {
+		return
+	}
+	n = min(n, s.bottom-y+1)
+	region := s.rows[y : s.bottom+1]
+	copy(region[n:], region[:len(region)-n])
+	for i := 0; i < n; i++ {
+		region[i] = s.blankRow()
+	}
+}
+
+// deleteRows deletes n rows at row y, pulling the rows below it up from
+// the bottom of the scroll region.
+func (s *vtScreen) deleteRows(y, n int) {
+	if y < s.top || y > s.bottom {
+		return
+	}
+	n = min(n, s.bottom-y+1)
+	region := s.rows[y : s.bottom+1]
+	copy(region, region[n:])
+	for i := len(region) - n; i < len(region); i++ {
+		region[i] = s.blankRow()
+	}
+}
+
+func (s *vtScreen) insertLines(n int) {
+	s.insertRows(s.cursor.y, n)
+	s.cursor.x = 0
+}
+
+func (s *vtScreen) deleteLines(n int) {
+	s.deleteRows(s.cursor.y, n)
+	s.cursor.x = 0
+}
+
+// insertChars inserts n blank cells at the cursor, shifting the rest of
+// the row right.
+func (s *vtScreen) insertChars(n int) {
+	if s.empty() {
+		return
+	}
+	row := s.rows[s.cursor.y][s.cursor.x:]
+	n = min(n, len(row))
+	copy(row[n:], row[:len(row)-n])
+	s.erase(row[:n])
+}
+
+// deleteChars deletes n cells at the cursor, shifting the rest of the row
+// left.
+func (s *vtScreen) deleteChars(n int) {
+	if s.empty() {
+		return
+	}
+	row := s.rows[s.cursor.y][s.cursor.x:]
+	n = min(n, len(row))
+	copy(row, row[n:])
+	s.erase(row[len(row)-n:])
+}
+
+// eraseChars erases n cells starting at the cursor without moving the rest
+// of the row.
+func (s *vtScreen) eraseChars(n int) {
+	if s.empty() {
+		return
+	}
+	row := s.rows[s.cursor.y]
+	s.erase(row[s.cursor.x : s.cursor.x+min(n, s.size.x-s.cursor.x)])
+}
+
+// eraseDisplay erases below the cursor (mode 0), above it (mode 1) or the
+// whole screen (modes 2 and 3).
+func (s *vtScreen) eraseDisplay(mode int) {
+	if s.empty() {
+		return
+	}
+	x, y := s.cursor.x, s.cursor.y
+	switch mode {
+	case 0:
+		s.erase(s.rows[y][x:])
+		for _, row := range s.rows[y+1:] {
+			s.erase(row)
+		}
+	case 1:
+		for _, row := range s.rows[:y] {
+			s.erase(row)
+		}
+		s.erase(s.rows[y][:min(x+1, s.size.x)])
+	case 2, 3:
+		for _, row := range s.rows {
+			s.erase(row)
+		}
+	}
+}
+
+// eraseLine erases the cursor row to the right of the cursor (mode 0), to
+// its left (mode 1) or entirely (mode 2).
+func (s *vtScreen) eraseLine(mode int) {
+	if s.empty() {
+		return
+	}
+	row := s.rows[s.cursor.y]
+	switch mode {
+	case 0:
+		s.erase(row[s.cursor.x:])
+	case 1:
+		s.erase(row[:min(s.cursor.x+1, s.size.x)])
+	case 2:
+		s.erase(row)
+	}
+}
+
+// takeReply returns and clears the responses waiting to be sent to the
+// program.
+func (s *vtScreen) takeReply() []byte {
+	r := s.reply
+	s.reply = nil
+	return r
+}
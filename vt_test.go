@@ -0,0 +1,130 @@
+package termwin
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// vtLines returns the text of a vtScreen's rows with trailing blanks
+// removed.
+func vtLines(s *vtScreen) []string {
+	lines := make([]string, len(s.rows))
+	for y, row := range s.rows {
+		var b strings.Builder
+		for _, c := range row {
+			b.WriteRune(c.Ch)
+		}
+		lines[y] = strings.TrimRight(b.String(), " ")
+	}
+	return lines
+}
+
+func TestParseParams(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []int
+	}{
+		{"", []int{0}},
+		{"5", []int{5}},
+		{"1;22", []int{1, 22}},
+		{";3", []int{0, 3}},
+		{"2;", []int{2, 0}},
+		{"38:5:196", []int{38, 5, 196}},
+		{"99999999999999999999", []int{vtMaxParam}},
+	}
+	for _, test := range tests {
+		if got := parseParams([]byte(test.in)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseParams(%q) = %v, want %v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestVTScreenWrite(t *testing.T) {
+	tests := []struct {
+		w, h   int
+		in     string
+		want   []string
+		cursor coord
+	}{
+		{8, 2, "hello", []string{"hello", ""}, coord{5, 0}},
+		{8, 2, "ab\r\ncd", []string{"ab", "cd"}, coord{2, 1}},
+		{4, 2, "abcdef", []string{"abcd", "ef"}, coord{2, 1}},
+		{4, 2, "abcd", []string{"abcd", ""}, coord{3, 0}},
+		{8, 2, "a\tb", []string{"a      b", ""}, coord{7, 0}},
+		{4, 2, "a\r\nb\r\nc", []string{"b", "c"}, coord{1, 1}},
+		{8, 3, "\x1b[2;3Hx", []string{"", "  x", ""}, coord{3, 1}},
+		{8, 3, "\x1b[99999999;99999999Hx", []string{"", "", "       x"}, coord{7, 2}},
+		{8, 1, "abcdef\x1b[3D\x1b[K", []string{"abc"}, coord{3, 0}},
+		{8, 1, "abcdef\x1b[3D\x1b[1K", []string{"    ef"}, coord{3, 0}},
+		{8, 1, "abcdef\x1b[2K", []string{""}, coord{6, 0}},
+		{8, 1, "abcdef\x1b[1;3H\x1b[2P", []string{"abef"}, coord{2, 0}},
+		{8, 1, "abcdef\x1b[1;3H\x1b[2@", []string{"ab  cdef"}, coord{2, 0}},
+		{8, 1, "abcdef\x1b[1;3H\x1b[2X", []string{"ab  ef"}, coord{2, 0}},
+		{8, 1, "abcdef\x1b[1;3H\x1b[99999999X", []string{"ab"}, coord{2, 0}},
+		{4, 3, "aaa\r\nbbb\r\nccc\x1b[2;2H\x1b[J", []string{"aaa", "b", ""}, coord{1, 1}},
+		{4, 3, "aaa\r\nbbb\r\nccc\x1b[2;2H\x1b[1J", []string{"", "  b", "ccc"}, coord{1, 1}},
+		{4, 3, "aaa\r\nbbb\r\nccc\x1b[2J", []string{"", "", ""}, coord{3, 2}},
+		{4, 3, "a\r\nb\r\nc\x1b[H\x1b[L", []string{"", "a", "b"}, coord{0, 0}},
+		{4, 3, "a\r\nb\r\nc\x1b[H\x1b[M", []string{"b", "c", ""}, coord{0, 0}},
+		{4, 3, "a\r\nb\r\nc\x1b[1;2r\x1b[2;1H\n", []string{"b", "", "c"}, coord{0, 1}},
+		{8, 1, "\x1b]0;title\x07ok", []string{"ok"}, coord{2, 0}},
+		{8, 1, "\x1b]0;title\x1b\\ok", []string{"ok"}, coord{2, 0}},
+		{8, 1, "\x1b]0;title\x1b[2Cok", []string{"  ok"}, coord{4, 0}},
+		{8, 1, "\xe6\x97\xa5\xe6\x9c\xac", []string{"日 本"}, coord{4, 0}},
+		{8, 0, "\x1b[K\x1b[J\x1b[1J\x1b[X\x1b[@\x1b[P\x1b[Labc\r\n\x1bM", []string{}, coord{0, 0}},
+		{0, 2, "\x1b[K\x1b[J\x1b[1J\x1b[X\x1b[@\x1b[P\x1b[Labc\r\n", []string{"", ""}, coord{0, 1}},
+	}
+
+	for i, test := range tests {
+		s := newVTScreen(test.w, test.h)
+		s.Write([]byte(test.in))
+		if got := vtLines(s); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%d: got %q, want %q", i, got, test.want)
+		}
+		if s.cursor != test.cursor {
+			t.Errorf("%d: cursor %v, want %v", i, s.cursor, test.cursor)
+		}
+	}
+}
+
+func TestVTScreenSplitWrite(t *testing.T) {
+	s := newVTScreen(8, 1)
+	in := "a\xe6\x97\xa5\x1b[1;31mb"
+	for i := 0; i < len(in); i++ {
+		s.Write([]byte{in[i]})
+	}
+	if got := vtLines(s)[0]; got != "a日 b" {
+		t.Errorf("got %q, want %q", got, "a日 b")
+	}
+}
+
+func TestVTScreenResize(t *testing.T) {
+	tests := []struct {
+		w, h   int
+		want   []string
+		cursor coord
+	}{
+		{5, 3, []string{"a", "b", "cd"}, coord{2, 2}},
+		{5, 2, []string{"b", "cd"}, coord{2, 1}},
+		{1, 3, []string{"a", "b", "c"}, coord{0, 2}},
+		{5, 4, []string{"a", "b", "cd", ""}, coord{2, 2}},
+		{5, 0, []string{}, coord{2, 0}},
+		{0, 3, []string{"", "", ""}, coord{0, 2}},
+	}
+
+	for i, test := range tests {
+		s := newVTScreen(5, 3)
+		s.Write([]byte("a\r\nb\r\ncd"))
+		s.resize(test.w, test.h)
+		if got := vtLines(s); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%d: got %q, want %q", i, got, test.want)
+		}
+		if s.cursor != test.cursor {
+			t.Errorf("%d: cursor %v, want %v", i, s.cursor, test.cursor)
+		}
+
+		// Further output must not fail on any size.
+		s.Write([]byte("\x1b[K\x1b[J\x1b[X\x1b[@\x1b[Px\r\n"))
+	}
+}
@@ -5,6 +5,224 @@ import termbox "github.com/nsf/termbox-go"
 // A Window is an instance of a termwin control.
 type Window interface {
 	onKey(ev termbox.Event) error
-	onDraw()
+	onMouse(ev termbox.Event) error
+	onDraw(cv *Canvas)
 	getCursor() (x, y int, show bool)
+	bounds() rect
+	dirtyRect() rect
+	invalidate()
+	move(x, y int)
+	resize(width, height int)
+}
+
+// A modalState records a modal window along with the window that had the
+// focus before the modal window was shown.
+type modalState struct {
+	w         Window
+	prevFocus Window
+}
+
+// A popup is a modal window that is dismissed when the user clicks outside
+// it, instead of ignoring the click.
+type popup interface {
+	Window
+	dismiss(ev termbox.Event) error
+}
+
+// A window implementing unfocusable may decline the keyboard focus when it
+// is clicked.
+type unfocusable interface {
+	canFocus() bool
+}
+
+// focusable returns true if a window accepts the keyboard focus.
+func focusable(w Window) bool {
+	u, ok := w.(unfocusable)
+	return !ok || u.canFocus()
+}
+
+// A container is a window that hosts child windows and passes keyboard
+// input to one of them.
+type container interface {
+	focusedChild() Window
+}
+
+// A window implementing owned is a transient window, such as a completion
+// popup, opened on behalf of another window. While its owner is the top
+// modal window, it receives mouse events as if it were part of the owner.
+type owned interface {
+	owner() Window
+}
+
+// ownedBy returns true if a window is owned by another.
+func ownedBy(w, owner Window) bool {
+	o, ok := w.(owned)
+	return ok && o.owner() == owner
+}
+
+// A window implementing keyClaimer handles some keys that menu bar
+// accelerators would otherwise take, such as an editor's Alt+letter
+// commands. The keys it claims go to the window rather than the menu bar.
+type keyClaimer interface {
+	claimsKey(ev termbox.Event) bool
+}
+
+// focusClaimsKey returns true if the focused window, or the focused child
+// of a focused container, claims a key event.
+func focusClaimsKey(ev termbox.Event) bool {
+	for f := c.focus; f != nil; {
+		if k, ok := f.(keyClaimer); ok && k.claimsKey(ev) {
+			return true
+		}
+		ct, ok := f.(container)
+		if !ok {
+			return false
+		}
+		f = ct.focusedChild()
+	}
+	return false
+}
+
+// isFocused returns true if a window has the keyboard focus, either
+// directly or as the focused child of a container that has the focus.
+func isFocused(w Window) bool {
+	for f := c.focus; f != nil; {
+		if f == w {
+			return true
+		}
+		ct, ok := f.(container)
+		if !ok {
+			return false
+		}
+		f = ct.focusedChild()
+	}
+	return false
+}
+
+func addWindow(w Window) {
+	if c.focus == nil && focusable(w) {
+		c.focus = w
+	}
+	c.windows = append(c.windows, w)
+}
+
+// damage marks an area of the screen as exposed, so it is cleared and the
+// windows overlapping it are redrawn on the next flush.
+func damage(r rect) {
+	c.damage = union(c.damage, r)
+}
+
+// windowIndex returns the position of a window in the stack, or -1.
+func windowIndex(w Window) int {
+	for i, ww := range c.windows {
+		if ww == w {
+			return i
+		}
+	}
+	return -1
+}
+
+// topModal returns the modal window at the top of the modal stack, or nil.
+func topModal() Window {
+	if len(c.modal) == 0 {
+		return nil
+	}
+	return c.modal[len(c.modal)-1].w
+}
+
+// RemoveWindow removes a window from the screen. The area it occupied is
+// redrawn by the windows beneath it. If the window had the focus, the
+// focus moves to the top-most remaining window that accepts it.
+func RemoveWindow(w Window) {
+	i := windowIndex(w)
+	if i < 0 {
+		return
+	}
+	c.windows = append(c.windows[:i], c.windows[i+1:]...)
+	damage(w.bounds())
+
+	if c.capture == w {
+		c.capture = nil
+	}
+	if c.menuBar == w {
+		c.menuBar = nil
+	}
+	if c.focus == w {
+		c.focus = nil
+		for i := len(c.windows) - 1; i >= 0 && c.focus == nil; i-- {
+			if focusable(c.windows[i]) {
+				c.focus = c.windows[i]
+			}
+		}
+	}
+}
+
+// MoveWindow moves a window to a new screen position.
+func MoveWindow(w Window, x, y int) {
+	w.move(x, y)
+}
+
+// ResizeWindow changes the screen dimensions of a window. Windows that size
+// themselves to fit their contents, such as buttons and dialogs, ignore the
+// requested size.
+func ResizeWindow(w Window, width, height int) {
+	w.resize(width, height)
+}
+
+// Raise moves a window to the top of the window stack, so it is drawn over
+// all other windows.
+func Raise(w Window) {
+	i := windowIndex(w)
+	if i < 0 || i == len(c.windows)-1 {
+		return
+	}
+	c.windows = append(c.windows[:i], c.windows[i+1:]...)
+	c.windows = append(c.windows, w)
+	w.invalidate()
+}
+
+// Lower moves a window to the bottom of the window stack, so all other
+// windows are drawn over it.
+func Lower(w Window) {
+	i := windowIndex(w)
+	if i <= 0 {
+		return
+	}
+	copy(c.windows[1:i+1], c.windows[:i])
+	c.windows[0] = w
+	damage(w.bounds())
+}
+
+// ShowModal shows a window on top of all other windows and gives it the
+// focus. Until the window is dismissed with EndModal, it receives all
+// keyboard input and mouse events outside it are ignored.
+func ShowModal(w Window) {
+	if windowIndex(w) < 0 {
+		c.windows = append(c.windows, w)
+	} else {
+		Raise(w)
+	}
+	w.invalidate()
+	c.modal = append(c.modal, modalState{w, c.focus})
+	c.focus = w
+}
+
+// EndModal removes a modal window shown with ShowModal and returns the focus
+// to the window that had it before the modal window was shown.
+func EndModal(w Window) {
+	for i := len(c.modal) - 1; i >= 0; i-- {
+		if c.modal[i].w != w {
+			continue
+		}
+		prev := c.modal[i].prevFocus
+		c.modal = append(c.modal[:i], c.modal[i+1:]...)
+		RemoveWindow(w)
+		if windowIndex(prev) >= 0 {
+			c.focus = prev
+		}
+		if m := topModal(); m != nil {
+			c.focus = m
+		}
+		return
+	}
 }